@@ -0,0 +1,186 @@
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when told to. Timers and tickers fire
+// during Advance, in the order they are due.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFake returns a fake clock stopped at start.
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.newWaiter(d, 0)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return fakeTicker{f.newWaiter(d, d)}
+}
+
+// Advance moves the clock forward, firing every timer and ticker that comes
+// due on the way. A ticker fires once per elapsed period, but like a real
+// ticker drops ticks nobody received.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	target := f.now.Add(d)
+	for {
+		next := f.nextDue(target)
+		if next == nil {
+			break
+		}
+
+		f.now = next.when
+		next.fire(f.now)
+	}
+	f.now = target
+}
+
+// Waiters returns how many timers and tickers are currently armed.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// nextDue returns the earliest armed waiter due at or before target.
+func (f *Fake) nextDue(target time.Time) *fakeWaiter {
+	var next *fakeWaiter
+	for _, w := range f.waiters {
+		if w.when.After(target) {
+			continue
+		}
+		if next == nil || w.when.Before(next.when) {
+			next = w
+		}
+	}
+	return next
+}
+
+func (f *Fake) newWaiter(d, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{
+		fake:   f,
+		c:      make(chan time.Time, 1),
+		period: period,
+	}
+	w.arm(d)
+	return w
+}
+
+func (f *Fake) remove(w *fakeWaiter) bool {
+	i := slices.Index(f.waiters, w)
+	if i < 0 {
+		return false
+	}
+	f.waiters = slices.Delete(f.waiters, i, i+1)
+	return true
+}
+
+// fakeWaiter backs both the fake Timer and Ticker, a zero period is a timer.
+type fakeWaiter struct {
+	fake   *Fake
+	c      chan time.Time
+	when   time.Time
+	period time.Duration
+}
+
+// arm schedules the waiter, the fake clock lock must be held. A timer with a
+// non-positive duration fires straight away, as with the time package.
+func (w *fakeWaiter) arm(d time.Duration) {
+	w.when = w.fake.now.Add(d)
+	if w.period == 0 && d <= 0 {
+		w.send(w.fake.now)
+		return
+	}
+	w.fake.waiters = append(w.fake.waiters, w)
+}
+
+// fire delivers a tick and reschedules tickers, the fake clock lock must be held.
+func (w *fakeWaiter) fire(now time.Time) {
+	w.send(now)
+	if w.period > 0 {
+		w.when = now.Add(w.period)
+		return
+	}
+	w.fake.remove(w)
+}
+
+func (w *fakeWaiter) send(now time.Time) {
+	select {
+	case w.c <- now:
+	default:
+	}
+}
+
+// drain drops an undelivered tick, matching the time package since Go 1.23
+// where Stop and Reset guarantee no stale value is received afterwards.
+func (w *fakeWaiter) drain() {
+	select {
+	case <-w.c:
+	default:
+	}
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.fake.mu.Lock()
+	defer w.fake.mu.Unlock()
+
+	w.drain()
+	return w.fake.remove(w)
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.fake.mu.Lock()
+	defer w.fake.mu.Unlock()
+
+	w.drain()
+	active := w.fake.remove(w)
+	w.arm(d)
+	return active
+}
+
+// fakeTicker adapts the waiter to the Ticker method set.
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	t.fakeWaiter.fake.mu.Lock()
+	t.period = d
+	t.fakeWaiter.fake.mu.Unlock()
+	t.fakeWaiter.Reset(d)
+}
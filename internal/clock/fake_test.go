@@ -0,0 +1,114 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeTimer(t *testing.T) {
+	f := NewFake(epoch)
+	timer := f.NewTimer(10 * time.Second)
+
+	f.Advance(9 * time.Second)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("timer fired early")
+	}
+
+	f.Advance(time.Second)
+	at, ok := fired(timer.C())
+	if !ok {
+		t.Fatal("timer did not fire")
+	}
+	if want := epoch.Add(10 * time.Second); !at.Equal(want) {
+		t.Errorf("fired at %v, want %v", at, want)
+	}
+	if f.Waiters() != 0 {
+		t.Errorf("Waiters = %d after firing, want 0", f.Waiters())
+	}
+}
+
+func TestFakeTimerZero(t *testing.T) {
+	f := NewFake(epoch)
+	timer := f.NewTimer(0)
+
+	if _, ok := fired(timer.C()); !ok {
+		t.Fatal("zero timer did not fire straight away")
+	}
+}
+
+func TestFakeTimerStopReset(t *testing.T) {
+	f := NewFake(epoch)
+	timer := f.NewTimer(time.Second)
+
+	if !timer.Stop() {
+		t.Error("Stop on an armed timer returned false")
+	}
+	f.Advance(time.Minute)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("stopped timer fired")
+	}
+
+	if timer.Reset(5 * time.Second) {
+		t.Error("Reset on a stopped timer returned true")
+	}
+	f.Advance(5 * time.Second)
+	if _, ok := fired(timer.C()); !ok {
+		t.Fatal("reset timer did not fire")
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	f := NewFake(epoch)
+	ticker := f.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for i := range 3 {
+		f.Advance(time.Second)
+		if _, ok := fired(ticker.C()); !ok {
+			t.Fatalf("tick %d missing", i)
+		}
+	}
+
+	// Ticks nobody receives are dropped, not queued
+	f.Advance(5 * time.Second)
+	if _, ok := fired(ticker.C()); !ok {
+		t.Fatal("tick missing after a long advance")
+	}
+	if _, ok := fired(ticker.C()); ok {
+		t.Fatal("ticker queued more than one tick")
+	}
+
+	ticker.Stop()
+	f.Advance(time.Minute)
+	if _, ok := fired(ticker.C()); ok {
+		t.Fatal("stopped ticker fired")
+	}
+}
+
+func TestFakeAdvanceOrder(t *testing.T) {
+	f := NewFake(epoch)
+	late := f.NewTimer(2 * time.Second)
+	early := f.NewTimer(time.Second)
+
+	f.Advance(3 * time.Second)
+
+	e, _ := fired(early.C())
+	l, _ := fired(late.C())
+	if !e.Before(l) {
+		t.Errorf("early fired at %v, late at %v", e, l)
+	}
+	if !f.Now().Equal(epoch.Add(3 * time.Second)) {
+		t.Errorf("Now = %v, want %v", f.Now(), epoch.Add(3*time.Second))
+	}
+}
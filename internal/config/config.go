@@ -0,0 +1,490 @@
+// Package config loads the server configuration from a YAML file, the
+// environment and command line flags, in increasing order of precedence.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/shared"
+)
+
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Metrics   Metrics   `yaml:"metrics"`
+	Log       Log       `yaml:"log"`
+	Tracing   Tracing   `yaml:"tracing"`
+	YouTube   YouTube   `yaml:"youtube"`
+	Lobby     Lobby     `yaml:"lobby"`
+	Policy    Policy    `yaml:"policy"`
+	Session   Session   `yaml:"session"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Admin     Admin     `yaml:"admin"`
+}
+
+type Server struct {
+	ListenAddr string `yaml:"listen_addr"`
+	Port       int    `yaml:"port"`
+	// DrainDelay is how long readiness fails before the server shuts down,
+	// so load balancers stop sending requests first.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+	// ClientIPHeader is the header naming the client address, one of
+	// shared.ClientIPModes. It is only read from TrustedProxies.
+	ClientIPHeader string `yaml:"client_ip_header"`
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in
+	// front of the server.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TrustedPrefixes returns the parsed trusted proxies, skipping invalid ones
+// which Validate reports.
+func (s Server) TrustedPrefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, proxy := range s.TrustedProxies {
+		if parsed, err := shared.ParsePrefixes([]string{proxy}); err == nil {
+			prefixes = append(prefixes, parsed...)
+		}
+	}
+	return prefixes
+}
+
+type Metrics struct {
+	// ListenAddr is the host:port serving /metrics, metrics are off when empty.
+	ListenAddr string `yaml:"listen_addr"`
+}
+
+type Log struct {
+	Level string `yaml:"level"`
+}
+
+type Tracing struct {
+	// Exporter is where spans are sent, one of TracingExporters.
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP collector URL, the standard
+	// OTEL_EXPORTER_OTLP_* environment is used when it is empty.
+	Endpoint    string  `yaml:"endpoint"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Tracing exporters.
+const (
+	TracingNone   = "none"
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+)
+
+var TracingExporters = []string{TracingNone, TracingOTLP, TracingStdout}
+
+type YouTube struct {
+	APIKey       string        `yaml:"api_key"`
+	UseScrape    bool          `yaml:"use_scrape"`
+	FetchTimeout time.Duration `yaml:"fetch_timeout"`
+	// Region is the country the server looks videos up from, region blocks
+	// reported by the Data API are judged against it.
+	Region string `yaml:"region"`
+}
+
+type Lobby struct {
+	MaxLobbies       int           `yaml:"max_lobbies"`
+	IdleTimeout      time.Duration `yaml:"idle_timeout"`
+	VoteDuration     time.Duration `yaml:"vote_duration"`
+	VoteMuteDuration time.Duration `yaml:"vote_mute_duration"`
+	MuteVoteCooldown time.Duration `yaml:"mute_vote_cooldown"`
+}
+
+// Policy is the content policy new lobbies start with, owners can change it
+// per lobby afterwards.
+type Policy struct {
+	AllowChannels       []string      `yaml:"allow_channels"`
+	DenyChannels        []string      `yaml:"deny_channels"`
+	BlockedKeywords     []string      `yaml:"blocked_keywords"`
+	RejectRegionBlocked bool          `yaml:"reject_region_blocked"`
+	RejectEmbedDisabled bool          `yaml:"reject_embed_disabled"`
+	RejectLive          bool          `yaml:"reject_live"`
+	MinDuration         time.Duration `yaml:"min_duration"`
+	MaxDuration         time.Duration `yaml:"max_duration"`
+}
+
+type Session struct {
+	// Key signs the session cookies, a random one is generated at startup
+	// when it is empty.
+	Key string `yaml:"key"`
+	// MaxAge is how long a session lasts, enforced on the server as well as
+	// in the cookie.
+	MaxAge          time.Duration `yaml:"max_age"`
+	RotateInterval  time.Duration `yaml:"rotate_interval"`
+	UserTimeout     time.Duration `yaml:"user_timeout"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+	// IPPolicy is how many users may share a client address, one of
+	// dj.IPPolicies.
+	IPPolicy string `yaml:"ip_policy"`
+}
+
+// RateLimit throttles the routes that create state or call out to YouTube.
+type RateLimit struct {
+	Create      RouteLimit `yaml:"create"`
+	Join        RouteLimit `yaml:"join"`
+	Add         RouteLimit `yaml:"add"`
+	Search      RouteLimit `yaml:"search"`
+	Vote        RouteLimit `yaml:"vote"`
+	React       RouteLimit `yaml:"react"`
+	PlayerError RouteLimit `yaml:"player_error"`
+	AdminLogin  RouteLimit `yaml:"admin_login"`
+}
+
+// Rate limited routes.
+const (
+	RouteCreate      = "create"
+	RouteJoin        = "join"
+	RouteAdd         = "add"
+	RouteSearch      = "search"
+	RouteVote        = "vote"
+	RouteReact       = "react"
+	RoutePlayerError = "player_error"
+	RouteAdminLogin  = "admin_login"
+)
+
+// Routes returns the limits by route.
+func (r RateLimit) Routes() map[string]RouteLimit {
+	return map[string]RouteLimit{
+		RouteCreate:      r.Create,
+		RouteJoin:        r.Join,
+		RouteAdd:         r.Add,
+		RouteSearch:      r.Search,
+		RouteVote:        r.Vote,
+		RouteReact:       r.React,
+		RoutePlayerError: r.PlayerError,
+		RouteAdminLogin:  r.AdminLogin,
+	}
+}
+
+// RouteLimit limits a route per client address and per user. The user limit
+// only applies to requests from someone in a lobby.
+type RouteLimit struct {
+	PerIP   Limit `yaml:"per_ip"`
+	PerUser Limit `yaml:"per_user"`
+}
+
+// Limit is a token bucket holding up to Burst requests, refilled with
+// Requests every Period. It is off when Requests is 0.
+type Limit struct {
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+	Burst    int           `yaml:"burst"`
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0
+}
+
+type Admin struct {
+	// Token authenticates the admin endpoints, which are off when it is empty.
+	Token string `yaml:"token"`
+}
+
+// MinAdminTokenLength keeps admin tokens from being guessable.
+const MinAdminTokenLength = 16
+
+// MinSessionKeyLength is the shortest session signing key accepted.
+const MinSessionKeyLength = 32
+
+const (
+	DefaultPort          = 8080
+	DefaultLogLevel      = "info"
+	DefaultSessionMaxAge = dj.SessionLifetime
+	DefaultFetchTimeout  = 10 * time.Second
+	DefaultRegion        = "US"
+)
+
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+var regionRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+
+var slogLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// SlogLevel returns the configured level, info if it is not valid.
+func (l Log) SlogLevel() slog.Level {
+	if level, ok := slogLevels[l.Level]; ok {
+		return level
+	}
+	return slog.LevelInfo
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() *Config {
+	policy := dj.DefaultContentPolicy()
+
+	return &Config{
+		Server: Server{
+			Port:           DefaultPort,
+			ClientIPHeader: shared.ClientIPNone,
+		},
+		Log: Log{
+			Level: DefaultLogLevel,
+		},
+		Tracing: Tracing{
+			Exporter:    TracingNone,
+			SampleRatio: 1,
+		},
+		YouTube: YouTube{
+			UseScrape:    true,
+			FetchTimeout: DefaultFetchTimeout,
+			Region:       DefaultRegion,
+		},
+		Lobby: Lobby{
+			MaxLobbies:       dj.MaxLobbies,
+			IdleTimeout:      dj.LobbyIdleTimeout,
+			VoteDuration:     dj.VoteDuration,
+			VoteMuteDuration: dj.VoteMuteDuration,
+			MuteVoteCooldown: dj.MuteVoteCooldown,
+		},
+		Policy: Policy{
+			RejectRegionBlocked: policy.RejectRegionBlocked,
+			RejectEmbedDisabled: policy.RejectEmbedDisabled,
+			RejectLive:          policy.RejectLive,
+			MinDuration:         policy.MinDuration,
+			MaxDuration:         policy.MaxDuration,
+		},
+		Session: Session{
+			MaxAge:          DefaultSessionMaxAge,
+			RotateInterval:  dj.SessionRotateInterval,
+			UserTimeout:     dj.UserIdleTimeout,
+			CleanupInterval: dj.UserCleanupInterval,
+			IPPolicy:        dj.IPPolicyPerIP,
+		},
+		RateLimit: RateLimit{
+			Create: RouteLimit{
+				PerIP: Limit{Requests: 5, Period: time.Minute, Burst: 3},
+			},
+			Join: RouteLimit{
+				PerIP: Limit{Requests: 20, Period: time.Minute, Burst: 10},
+			},
+			Add: RouteLimit{
+				PerIP:   Limit{Requests: 30, Period: time.Minute, Burst: 10},
+				PerUser: Limit{Requests: 10, Period: time.Minute, Burst: 5},
+			},
+			Search: RouteLimit{
+				PerIP:   Limit{Requests: 40, Period: time.Minute, Burst: 10},
+				PerUser: Limit{Requests: 15, Period: time.Minute, Burst: 6},
+			},
+			Vote: RouteLimit{
+				PerIP:   Limit{Requests: 60, Period: time.Minute, Burst: 20},
+				PerUser: Limit{Requests: 20, Period: time.Minute, Burst: 10},
+			},
+			React: RouteLimit{
+				PerIP:   Limit{Requests: 120, Period: time.Minute, Burst: 30},
+				PerUser: Limit{Requests: 30, Period: time.Minute, Burst: 10},
+			},
+			PlayerError: RouteLimit{
+				PerIP:   Limit{Requests: 30, Period: time.Minute, Burst: 10},
+				PerUser: Limit{Requests: 6, Period: time.Minute, Burst: 3},
+			},
+			AdminLogin: RouteLimit{
+				PerIP: Limit{Requests: 5, Period: 15 * time.Minute, Burst: 5},
+			},
+		},
+	}
+}
+
+// ListenAddress is the host:port the server listens on.
+func (c *Config) ListenAddress() string {
+	return net.JoinHostPort(c.Server.ListenAddr, fmt.Sprint(c.Server.Port))
+}
+
+// ManagerSettings returns the settings for the lobby manager.
+func (c *Config) ManagerSettings() dj.Settings {
+	return dj.Settings{
+		MaxLobbies:          c.Lobby.MaxLobbies,
+		LobbyIdleTimeout:    c.Lobby.IdleTimeout,
+		UserIdleTimeout:     c.Session.UserTimeout,
+		UserCleanupInterval: c.Session.CleanupInterval,
+		VoteDuration:        c.Lobby.VoteDuration,
+		VoteMuteDuration:    c.Lobby.VoteMuteDuration,
+		MuteVoteCooldown:    c.Lobby.MuteVoteCooldown,
+
+		SessionLifetime:       c.Session.MaxAge,
+		SessionRotateInterval: c.Session.RotateInterval,
+		IPPolicy:              c.Session.IPPolicy,
+
+		ContentPolicy: &dj.ContentPolicy{
+			AllowChannels:       c.Policy.AllowChannels,
+			DenyChannels:        c.Policy.DenyChannels,
+			BlockedKeywords:     c.Policy.BlockedKeywords,
+			RejectRegionBlocked: c.Policy.RejectRegionBlocked,
+			RejectEmbedDisabled: c.Policy.RejectEmbedDisabled,
+			RejectLive:          c.Policy.RejectLive,
+			MinDuration:         c.Policy.MinDuration,
+			MaxDuration:         c.Policy.MaxDuration,
+		},
+	}
+}
+
+func positive(errs []error, name string, d time.Duration) []error {
+	if d <= 0 {
+		errs = append(errs, fmt.Errorf("%s must be positive, got %v", name, d))
+	}
+	return errs
+}
+
+func validLimit(errs []error, name string, l Limit) []error {
+	if l.Requests < 0 {
+		return append(errs, fmt.Errorf("%s.requests must not be negative, got %d", name, l.Requests))
+	}
+	if l.Enabled() {
+		errs = positive(errs, name+".period", l.Period)
+		if l.Burst < 1 {
+			errs = append(errs, fmt.Errorf("%s.burst must be at least 1, got %d", name, l.Burst))
+		}
+	}
+	return errs
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if c.Server.ListenAddr != "" && net.ParseIP(c.Server.ListenAddr) == nil {
+		errs = append(errs, fmt.Errorf("server.listen_addr must be an IP address, got %q", c.Server.ListenAddr))
+	}
+
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, fmt.Errorf("server.drain_delay must not be negative, got %v", c.Server.DrainDelay))
+	}
+
+	if !slices.Contains(shared.ClientIPModes, c.Server.ClientIPHeader) {
+		errs = append(errs, fmt.Errorf("server.client_ip_header must be one of %s, got %q", strings.Join(shared.ClientIPModes, ", "), c.Server.ClientIPHeader))
+	}
+	if _, err := shared.ParsePrefixes(c.Server.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("server.trusted_proxies: %w", err))
+	}
+	if c.Server.ClientIPHeader != shared.ClientIPNone && len(c.Server.TrustedProxies) == 0 {
+		errs = append(errs, fmt.Errorf("server.trusted_proxies is required when server.client_ip_header is %q", c.Server.ClientIPHeader))
+	}
+
+	if c.Metrics.ListenAddr != "" {
+		if _, port, err := net.SplitHostPort(c.Metrics.ListenAddr); err != nil || port == "" {
+			errs = append(errs, fmt.Errorf("metrics.listen_addr must be a host:port, got %q", c.Metrics.ListenAddr))
+		}
+	}
+
+	if !slices.Contains(LogLevels, c.Log.Level) {
+		errs = append(errs, fmt.Errorf("log.level must be one of %s, got %q", strings.Join(LogLevels, ", "), c.Log.Level))
+	}
+
+	if !slices.Contains(TracingExporters, c.Tracing.Exporter) {
+		errs = append(errs, fmt.Errorf("tracing.exporter must be one of %s, got %q", strings.Join(TracingExporters, ", "), c.Tracing.Exporter))
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint must be an http(s) URL, got %q", c.Tracing.Endpoint))
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio))
+	}
+
+	if c.YouTube.APIKey == "" && !c.YouTube.UseScrape {
+		errs = append(errs, errors.New("youtube.api_key is required when youtube.use_scrape is off"))
+	}
+	errs = positive(errs, "youtube.fetch_timeout", c.YouTube.FetchTimeout)
+	if !regionRegex.MatchString(c.YouTube.Region) {
+		errs = append(errs, fmt.Errorf("youtube.region must be a two letter country code, got %q", c.YouTube.Region))
+	}
+
+	if c.Lobby.MaxLobbies < 1 {
+		errs = append(errs, fmt.Errorf("lobby.max_lobbies must be at least 1, got %d", c.Lobby.MaxLobbies))
+	}
+	errs = positive(errs, "lobby.idle_timeout", c.Lobby.IdleTimeout)
+	errs = positive(errs, "lobby.vote_duration", c.Lobby.VoteDuration)
+	errs = positive(errs, "lobby.vote_mute_duration", c.Lobby.VoteMuteDuration)
+	errs = positive(errs, "lobby.mute_vote_cooldown", c.Lobby.MuteVoteCooldown)
+
+	maxPolicy := dj.MaxPolicyDurationMinutes * time.Minute
+	if c.Policy.MinDuration < 0 || c.Policy.MinDuration > maxPolicy {
+		errs = append(errs, fmt.Errorf("policy.min_duration must be between 0 and %v, got %v", maxPolicy, c.Policy.MinDuration))
+	}
+	if c.Policy.MaxDuration < 0 || c.Policy.MaxDuration > maxPolicy {
+		errs = append(errs, fmt.Errorf("policy.max_duration must be between 0 and %v, got %v", maxPolicy, c.Policy.MaxDuration))
+	}
+	if c.Policy.MaxDuration > 0 && c.Policy.MinDuration > c.Policy.MaxDuration {
+		errs = append(errs, errors.New("policy.min_duration is longer than policy.max_duration"))
+	}
+
+	errs = positive(errs, "session.max_age", c.Session.MaxAge)
+	errs = positive(errs, "session.user_timeout", c.Session.UserTimeout)
+	errs = positive(errs, "session.cleanup_interval", c.Session.CleanupInterval)
+	errs = positive(errs, "session.rotate_interval", c.Session.RotateInterval)
+	if c.Session.Key != "" && len(c.Session.Key) < MinSessionKeyLength {
+		errs = append(errs, fmt.Errorf("session.key must be at least %d characters", MinSessionKeyLength))
+	}
+	if !slices.Contains(dj.IPPolicies, c.Session.IPPolicy) {
+		errs = append(errs, fmt.Errorf("session.ip_policy must be one of %s, got %q", strings.Join(dj.IPPolicies, ", "), c.Session.IPPolicy))
+	}
+	if c.Session.MaxAge > 0 && c.Session.MaxAge < time.Second {
+		errs = append(errs, fmt.Errorf("session.max_age must be at least a second, got %v", c.Session.MaxAge))
+	}
+
+	routes := c.RateLimit.Routes()
+	for _, route := range slices.Sorted(maps.Keys(routes)) {
+		limits := routes[route]
+		errs = validLimit(errs, "rate_limit."+route+".per_ip", limits.PerIP)
+		errs = validLimit(errs, "rate_limit."+route+".per_user", limits.PerUser)
+	}
+
+	if c.Admin.Token != "" && len(c.Admin.Token) < MinAdminTokenLength {
+		errs = append(errs, fmt.Errorf("admin.token must be at least %d characters", MinAdminTokenLength))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the config with secrets blanked out.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.YouTube.APIKey != "" {
+		redacted.YouTube.APIKey = Redacted
+	}
+	if redacted.Admin.Token != "" {
+		redacted.Admin.Token = Redacted
+	}
+	if redacted.Session.Key != "" {
+		redacted.Session.Key = Redacted
+	}
+	return &redacted
+}
+
+// Redacted stands in for secret values in printed configs and diffs.
+const Redacted = "REDACTED"
+
+// Write prints the configuration as YAML, with secrets redacted.
+func (c *Config) Write(w io.Writer) error {
+	redacted := c.Redacted()
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(redacted); err != nil {
+		return err
+	}
+	return enc.Close()
+}
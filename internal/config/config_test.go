@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) LookupEnv {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, body string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "testdj.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, opts, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if opts.File != "" || opts.PrintConfig {
+		t.Errorf("opts = %+v, want zero", opts)
+	}
+	if cfg.ListenAddress() != ":8080" {
+		t.Errorf("ListenAddress = %q, want :8080", cfg.ListenAddress())
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  port: 7000
+  listen_addr: 127.0.0.1
+log:
+  level: warn
+lobby:
+  max_lobbies: 5
+  idle_timeout: 30m
+`)
+
+	cfg, _, err := Load(
+		[]string{"-config", path, "-max-lobbies", "7"},
+		env(map[string]string{EnvPort: "7100", EnvLogLevel: "DEBUG"}),
+	)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// flag over file
+	if cfg.Lobby.MaxLobbies != 7 {
+		t.Errorf("MaxLobbies = %d, want 7 from the flag", cfg.Lobby.MaxLobbies)
+	}
+	// env over file
+	if cfg.Server.Port != 7100 || cfg.Log.Level != "debug" {
+		t.Errorf("Port = %d, Level = %q, want 7100 and debug from the env", cfg.Server.Port, cfg.Log.Level)
+	}
+	// file over default
+	if cfg.Server.ListenAddr != "127.0.0.1" || cfg.Lobby.IdleTimeout != 30*time.Minute {
+		t.Errorf("ListenAddr = %q, IdleTimeout = %v, want the file values", cfg.Server.ListenAddr, cfg.Lobby.IdleTimeout)
+	}
+	// untouched default
+	if cfg.Session.MaxAge != DefaultSessionMaxAge {
+		t.Errorf("MaxAge = %v, want the default", cfg.Session.MaxAge)
+	}
+
+	// flag over env
+	cfg, _, err = Load([]string{"-port", "7200"}, env(map[string]string{EnvPort: "7100"}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != 7200 {
+		t.Errorf("Port = %d, want 7200 from the flag", cfg.Server.Port)
+	}
+}
+
+func TestLoadFileFromEnv(t *testing.T) {
+	path := writeFile(t, "server:\n  port: 7000\n")
+
+	cfg, opts, err := Load(nil, env(map[string]string{EnvConfigFile: path}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if opts.File != path || cfg.Server.Port != 7000 {
+		t.Errorf("File = %q, Port = %d, want %q and 7000", opts.File, cfg.Server.Port, path)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"unknown key", "server:\n  prot: 1\n", nil, nil, "prot"},
+		{"bad duration", "lobby:\n  idle_timeout: soon\n", nil, nil, "soon"},
+		{"bad port env", "", nil, map[string]string{EnvPort: "http"}, EnvPort},
+		{"scrape env off", "", nil, map[string]string{EnvUseScrape: "maybe"}, "youtube.api_key"},
+		{"port range", "", []string{"-port", "70000"}, nil, "server.port"},
+		{"log level", "", []string{"-log-level", "loud"}, nil, "log.level"},
+		{"no fetch path", "", []string{"-use-scrape=false"}, nil, "youtube.api_key"},
+		{"region", "", []string{"-region", "usa"}, nil, "youtube.region"},
+		{"zero timeout", "", []string{"-user-timeout", "0s"}, nil, "session.user_timeout"},
+		{"tracing exporter", "", []string{"-tracing-exporter", "jaeger"}, nil, "tracing.exporter"},
+		{"tracing endpoint", "", []string{"-tracing-endpoint", "collector:4318"}, nil, "tracing.endpoint"},
+		{"drain delay", "", []string{"-drain-delay", "-5s"}, nil, "server.drain_delay"},
+		{"rate limit burst", "rate_limit:\n  add:\n    per_user: {requests: 5, period: 1m, burst: 0}\n", nil, nil, "rate_limit.add.per_user.burst"},
+		{"client ip header", "", []string{"-client-ip-header", "x-real-ip"}, nil, "server.client_ip_header"},
+		{"untrusted header", "", nil, map[string]string{EnvClientIP: "cloudflare"}, "server.trusted_proxies is required"},
+		{"bad proxy", "", []string{"-trusted-proxies", "10.0.0.0/8,proxy.local"}, nil, "proxy.local"},
+		{"session key", "", nil, map[string]string{EnvSessionKey: "short"}, "session.key"},
+		{"ip policy", "", nil, map[string]string{EnvIPPolicy: "per_device"}, "session.ip_policy"},
+		{"metrics addr", "", nil, map[string]string{EnvMetrics: "9090"}, "metrics.listen_addr"},
+		{"unknown flag", "", []string{"-nope"}, nil, "nope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+
+			_, _, err := Load(args, env(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteRedacts(t *testing.T) {
+	cfg := Default()
+	cfg.YouTube.APIKey = "secret-key"
+
+	var buf bytes.Buffer
+	if err := cfg.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "secret-key") {
+		t.Error("API key printed in the clear")
+	}
+	if cfg.YouTube.APIKey != "secret-key" {
+		t.Error("Write modified the config")
+	}
+
+	// The printed config loads back to the same values
+	path := writeFile(t, buf.String())
+	loaded, _, err := Load([]string{"-config", path}, env(nil))
+	if err != nil {
+		t.Fatalf("Load printed config: %v", err)
+	}
+	if loaded.Lobby != cfg.Lobby || loaded.Session != cfg.Session {
+		t.Errorf("round trip changed the config: %+v", loaded)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := Default()
+	old.YouTube.APIKey = "old-key"
+
+	next := Default()
+	next.YouTube.APIKey = "new-key"
+	next.Log.Level = "debug"
+	next.Lobby.MaxLobbies = 3
+	next.Policy.DenyChannels = []string{"spam"}
+	next.Server.Port = 9000
+	next.Session.Key = "a-session-key-that-is-long-enough"
+
+	changes := Diff(old, next)
+
+	got := map[string]Change{}
+	for _, c := range changes {
+		got[c.Key] = c
+	}
+
+	want := map[string]Change{
+		"server.port":          {"server.port", "8080", "9000"},
+		"log.level":            {"log.level", "info", "debug"},
+		"lobby.max_lobbies":    {"lobby.max_lobbies", "100", "3"},
+		"policy.deny_channels": {"policy.deny_channels", "[]", "[spam]"},
+		"youtube.api_key":      {"youtube.api_key", Redacted, Redacted},
+		"session.key":          {"session.key", "", Redacted},
+	}
+	if len(got) != len(want) {
+		t.Errorf("changes = %+v, want %d", changes, len(want))
+	}
+	for key, w := range want {
+		if got[key] != w {
+			t.Errorf("%s = %+v, want %+v", key, got[key], w)
+		}
+	}
+
+	if !got["server.port"].NeedsRestart() || !got["session.key"].NeedsRestart() || got["log.level"].NeedsRestart() {
+		t.Error("only server settings and the session key should need a restart")
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Errorf("Diff of the same config = %+v, want none", changes)
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// Change is a setting that differs between two configs, keyed by its YAML
+// path such as "lobby.max_lobbies". Secret values are redacted.
+type Change struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// NeedsRestart reports whether the setting is only read at startup.
+func (c Change) NeedsRestart() bool {
+	for _, prefix := range restartPrefixes {
+		if strings.HasPrefix(c.Key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// The sections and settings that are only read at startup.
+var restartPrefixes = []string{"server.", "metrics.", "tracing.", "session.key"}
+
+func (c Change) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("old", c.Old), slog.String("new", c.New))
+}
+
+// Diff lists the settings that changed from old to new.
+func Diff(old, new *Config) []Change {
+	// Secrets are compared separately so their values never reach the diff
+	o, n := *old, *new
+	o.YouTube.APIKey, n.YouTube.APIKey = "", ""
+	o.Admin.Token, n.Admin.Token = "", ""
+	o.Session.Key, n.Session.Key = "", ""
+
+	var changes []Change
+	diffStruct("", reflect.ValueOf(o), reflect.ValueOf(n), &changes)
+
+	if old.YouTube.APIKey != new.YouTube.APIKey {
+		changes = append(changes, secretChange("youtube.api_key", old.YouTube.APIKey, new.YouTube.APIKey))
+	}
+	if old.Admin.Token != new.Admin.Token {
+		changes = append(changes, secretChange("admin.token", old.Admin.Token, new.Admin.Token))
+	}
+	if old.Session.Key != new.Session.Key {
+		changes = append(changes, secretChange("session.key", old.Session.Key, new.Session.Key))
+	}
+
+	return changes
+}
+
+func secretChange(key, old, new string) Change {
+	show := func(v string) string {
+		if v == "" {
+			return ""
+		}
+		return Redacted
+	}
+	return Change{Key: key, Old: show(old), New: show(new)}
+}
+
+func diffStruct(prefix string, old, new reflect.Value, changes *[]Change) {
+	for i := range old.NumField() {
+		field := old.Type().Field(i)
+		key := prefix + field.Tag.Get("yaml")
+
+		o, n := old.Field(i), new.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() == old.Type().PkgPath() {
+			diffStruct(key+".", o, n, changes)
+			continue
+		}
+
+		if !reflect.DeepEqual(o.Interface(), n.Interface()) {
+			*changes = append(*changes, Change{
+				Key: key,
+				Old: fmt.Sprint(o.Interface()),
+				New: fmt.Sprint(n.Interface()),
+			})
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/shared"
+)
+
+// Environment variables, they override the config file.
+const (
+	EnvConfigFile = "CONFIG_FILE"
+	EnvListenAddr = "LISTEN_ADDR"
+	EnvPort       = "PORT"
+	EnvClientIP   = "CLIENT_IP_HEADER"
+	EnvProxies    = "TRUSTED_PROXIES"
+	EnvMetrics    = "METRICS_ADDR"
+	EnvLogLevel   = "LOG_LEVEL"
+	EnvTracing    = "TRACING_EXPORTER"
+	EnvTracingURL = "TRACING_ENDPOINT"
+	EnvAPIKey     = "YT_API_KEY"
+	EnvUseScrape  = "USE_SCRAPE"
+	EnvAdminToken = "ADMIN_TOKEN"
+	EnvSessionKey = "SESSION_KEY"
+	EnvIPPolicy   = "IP_POLICY"
+)
+
+// Options are the command line switches that are not configuration values.
+type Options struct {
+	// File is the config file that was read, empty if none was.
+	File string
+	// PrintConfig asks for the resolved configuration to be printed
+	// instead of starting the server.
+	PrintConfig bool
+}
+
+// LookupEnv matches os.LookupEnv, so tests can supply their own environment.
+type LookupEnv func(key string) (string, bool)
+
+// Load resolves the configuration from the defaults, then the config file,
+// then the environment, then the command line flags in args (without the
+// program name). The result is validated.
+func Load(args []string, lookupEnv LookupEnv) (*Config, Options, error) {
+	var opts Options
+
+	// The config file is named by a flag or the environment, so the flags
+	// are parsed once to find it before being applied over the file values
+	probe := flag.NewFlagSet("probe", flag.ContinueOnError)
+	probe.SetOutput(io.Discard)
+	bindFlags(probe, Default(), &opts)
+	_ = probe.Parse(args) // bad flags are reported by the second parse
+
+	if opts.File == "" {
+		opts.File, _ = lookupEnv(EnvConfigFile)
+	}
+
+	cfg := Default()
+	if opts.File != "" {
+		if err := loadFile(cfg, opts.File); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	if err := applyEnv(cfg, lookupEnv); err != nil {
+		return nil, opts, err
+	}
+
+	fs := flag.NewFlagSet("testdj", flag.ContinueOnError)
+	bindFlags(fs, cfg, &opts)
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, opts, nil
+}
+
+func bindFlags(fs *flag.FlagSet, cfg *Config, opts *Options) {
+	fs.StringVar(&opts.File, "config", opts.File, "path to a YAML config file (env "+EnvConfigFile+")")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the resolved configuration and exit")
+
+	fs.StringVar(&cfg.Server.ListenAddr, "listen-addr", cfg.Server.ListenAddr, "address to listen on (env "+EnvListenAddr+")")
+	fs.IntVar(&cfg.Server.Port, "port", cfg.Server.Port, "port to listen on (env "+EnvPort+")")
+	fs.DurationVar(&cfg.Server.DrainDelay, "drain-delay", cfg.Server.DrainDelay, "how long to fail readiness before shutting down")
+	fs.StringVar(&cfg.Server.ClientIPHeader, "client-ip-header", cfg.Server.ClientIPHeader, "header naming the client address behind a trusted proxy: "+strings.Join(shared.ClientIPModes, ", ")+" (env "+EnvClientIP+")")
+	fs.Var((*listFlag)(&cfg.Server.TrustedProxies), "trusted-proxies", "comma separated addresses or CIDR ranges of trusted proxies (env "+EnvProxies+")")
+	fs.StringVar(&cfg.Metrics.ListenAddr, "metrics-addr", cfg.Metrics.ListenAddr, "host:port to serve /metrics on, off when empty (env "+EnvMetrics+")")
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "one of "+strings.Join(LogLevels, ", ")+" (env "+EnvLogLevel+")")
+	fs.StringVar(&cfg.Tracing.Exporter, "tracing-exporter", cfg.Tracing.Exporter, "where to send traces: "+strings.Join(TracingExporters, ", ")+" (env "+EnvTracing+")")
+	fs.StringVar(&cfg.Tracing.Endpoint, "tracing-endpoint", cfg.Tracing.Endpoint, "OTLP/HTTP collector URL (env "+EnvTracingURL+")")
+	fs.BoolVar(&cfg.YouTube.UseScrape, "use-scrape", cfg.YouTube.UseScrape, "look up videos by scraping as well as the Data API (env "+EnvUseScrape+")")
+	fs.DurationVar(&cfg.YouTube.FetchTimeout, "fetch-timeout", cfg.YouTube.FetchTimeout, "limit for a single video lookup")
+	fs.StringVar(&cfg.YouTube.Region, "region", cfg.YouTube.Region, "country code the server looks videos up from")
+	fs.IntVar(&cfg.Lobby.MaxLobbies, "max-lobbies", cfg.Lobby.MaxLobbies, "maximum number of open lobbies")
+	fs.DurationVar(&cfg.Lobby.IdleTimeout, "lobby-idle-timeout", cfg.Lobby.IdleTimeout, "how long a lobby lives without activity")
+	fs.DurationVar(&cfg.Session.MaxAge, "session-max-age", cfg.Session.MaxAge, "lifetime of the session cookie")
+	fs.DurationVar(&cfg.Session.RotateInterval, "session-rotate-interval", cfg.Session.RotateInterval, "how often session tokens are replaced")
+	fs.StringVar(&cfg.Session.IPPolicy, "ip-policy", cfg.Session.IPPolicy, "how many users may share an address: "+strings.Join(dj.IPPolicies, ", ")+" (env "+EnvIPPolicy+")")
+	fs.DurationVar(&cfg.Session.UserTimeout, "user-timeout", cfg.Session.UserTimeout, "how long a user without a heartbeat stays in a lobby")
+}
+
+// listFlag is a comma separated list flag, replacing the configured list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = splitList(v)
+	return nil
+}
+
+func splitList(v string) []string {
+	var list []string
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func applyEnv(cfg *Config, lookupEnv LookupEnv) error {
+	if v, ok := lookupEnv(EnvListenAddr); ok {
+		cfg.Server.ListenAddr = v
+	}
+
+	if v, ok := lookupEnv(EnvPort); ok && v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvPort, err)
+		}
+		cfg.Server.Port = port
+	}
+
+	if v, ok := lookupEnv(EnvClientIP); ok && v != "" {
+		cfg.Server.ClientIPHeader = strings.ToLower(v)
+	}
+
+	if v, ok := lookupEnv(EnvProxies); ok {
+		cfg.Server.TrustedProxies = splitList(v)
+	}
+
+	if v, ok := lookupEnv(EnvMetrics); ok {
+		cfg.Metrics.ListenAddr = v
+	}
+
+	if v, ok := lookupEnv(EnvLogLevel); ok && v != "" {
+		cfg.Log.Level = strings.ToLower(v)
+	}
+
+	if v, ok := lookupEnv(EnvTracing); ok && v != "" {
+		cfg.Tracing.Exporter = strings.ToLower(v)
+	}
+
+	if v, ok := lookupEnv(EnvTracingURL); ok {
+		cfg.Tracing.Endpoint = v
+	}
+
+	if v, ok := lookupEnv(EnvAPIKey); ok {
+		cfg.YouTube.APIKey = strings.TrimSpace(v)
+	}
+
+	if v, ok := lookupEnv(EnvAdminToken); ok {
+		cfg.Admin.Token = strings.TrimSpace(v)
+	}
+
+	if v, ok := lookupEnv(EnvSessionKey); ok {
+		cfg.Session.Key = strings.TrimSpace(v)
+	}
+
+	if v, ok := lookupEnv(EnvIPPolicy); ok && v != "" {
+		cfg.Session.IPPolicy = strings.ToLower(v)
+	}
+
+	// Anything but a true value turns scraping off, as it always has
+	if v, ok := lookupEnv(EnvUseScrape); ok && v != "" {
+		useScrape, err := strconv.ParseBool(strings.TrimSpace(v))
+		cfg.YouTube.UseScrape = err == nil && useScrape
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package dj
+
+import (
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/btnmasher/testdj/internal/shared"
+)
+
+// Lobby access modes, what joining takes besides the lobby code.
+const (
+	// AccessOpen lets anyone with the code join.
+	AccessOpen = "open"
+	// AccessPassword asks for the lobby password, an invite works as well.
+	AccessPassword = "password"
+	// AccessInvite only lets in those with an invite from the owner.
+	AccessInvite = "invite"
+)
+
+var AccessModes = []string{AccessOpen, AccessPassword, AccessInvite}
+
+var AccessDisplayName = map[string]string{
+	AccessOpen:     "Anyone with the code",
+	AccessPassword: "Password or invite",
+	AccessInvite:   "Invite only",
+}
+
+// Password limits, bcrypt ignores anything past 72 bytes.
+const (
+	MinPasswordLength = 4
+	MaxPasswordLength = 72
+)
+
+// ValidPassword reports whether password can be set on a lobby.
+func ValidPassword(password string) bool {
+	return len(password) >= MinPasswordLength && len(password) <= MaxPasswordLength
+}
+
+// Invite limits.
+const (
+	InviteIDLength        = 9
+	MaxInvites            = 20
+	DefaultInviteLifetime = 24 * time.Hour
+	MaxInviteLifetime     = 7 * 24 * time.Hour
+)
+
+var (
+	ErrPasswordRequired = errors.New("password required")
+	ErrWrongPassword    = errors.New("wrong password")
+	ErrInviteRequired   = errors.New("invite required")
+	ErrInvalidInvite    = errors.New("invalid or revoked invite")
+	ErrInviteExpired    = errors.New("invite expired")
+	ErrInvalidPassword  = errors.New("invalid password")
+	ErrTooManyInvites   = errors.New("too many invites")
+)
+
+// Invite lets whoever holds its token join the lobby until it expires or the
+// owner revokes it.
+type Invite struct {
+	ID        string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// access is who may join the lobby, guarded by the lobby lock.
+type access struct {
+	mode         string
+	passwordHash []byte
+	invites      map[string]*Invite
+}
+
+// Access returns the access mode of the lobby.
+func (l *Lobby) Access() string {
+	l.Lock()
+	defer l.Unlock()
+	return l.access.mode
+}
+
+// SetAccess changes who may join the lobby. A password is needed to switch
+// to the password mode, after that an empty one keeps the current password.
+// Users already in the lobby stay and outstanding invites keep working.
+func (l *Lobby) SetAccess(mode, password string) error {
+	if !slices.Contains(AccessModes, mode) {
+		mode = AccessOpen
+	}
+
+	var hash []byte
+	if mode == AccessPassword && password != "" {
+		if !ValidPassword(password) {
+			return ErrInvalidPassword
+		}
+		var err error
+		if hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost); err != nil {
+			return err
+		}
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	switch {
+	case mode != AccessPassword:
+		l.access.passwordHash = nil
+	case hash != nil:
+		l.access.passwordHash = hash
+	case l.access.passwordHash == nil:
+		return ErrInvalidPassword
+	}
+	l.access.mode = mode
+
+	l.log.With("func", "SetAccess").Info("Lobby access changed", slog.String("mode", mode))
+
+	if l.listed.Load() {
+		l.Manager.notifyDirectory()
+	}
+	return nil
+}
+
+// CheckAccess reports why someone with the password or invite token given,
+// either may be empty, cannot join the lobby, or nil if they can.
+func (l *Lobby) CheckAccess(password, invite string) error {
+	l.Lock()
+	mode, hash := l.access.mode, l.access.passwordHash
+	l.Unlock()
+
+	if mode == AccessOpen {
+		return nil
+	}
+
+	if invite != "" {
+		return l.checkInvite(invite)
+	}
+
+	if mode == AccessInvite {
+		return ErrInviteRequired
+	}
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+func (l *Lobby) checkInvite(token string) error {
+	id, expires, ok := shared.VerifyInviteToken(l.Manager.sessionKey, l.ID, token)
+	if !ok {
+		return ErrInvalidInvite
+	}
+	if !l.clock.Now().Before(expires) {
+		return ErrInviteExpired
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.access.invites[id]; !ok {
+		return ErrInvalidInvite
+	}
+	return nil
+}
+
+// CreateInvite mints an invite lasting lifetime, capped at MaxInviteLifetime,
+// and returns it with its token.
+func (l *Lobby) CreateInvite(lifetime time.Duration) (*Invite, string, error) {
+	if lifetime <= 0 {
+		lifetime = DefaultInviteLifetime
+	}
+	lifetime = min(lifetime, MaxInviteLifetime)
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.clock.Now()
+	l.pruneInvites(now)
+	if len(l.access.invites) >= MaxInvites {
+		return nil, "", ErrTooManyInvites
+	}
+
+	invite := &Invite{
+		ID:        l.Manager.newID(InviteIDLength),
+		CreatedAt: now,
+		ExpiresAt: now.Add(lifetime).Truncate(time.Second),
+	}
+	l.access.invites[invite.ID] = invite
+
+	l.log.With("func", "CreateInvite").Debug("Invite created",
+		slog.String("inviteID", invite.ID),
+		slog.Time("expiresAt", invite.ExpiresAt),
+	)
+	return invite, l.InviteToken(invite), nil
+}
+
+// InviteToken returns the token of an invite, it can be handed out again for
+// as long as the invite is outstanding.
+func (l *Lobby) InviteToken(invite *Invite) string {
+	return shared.SignInviteToken(l.Manager.sessionKey, l.ID, invite.ID, invite.ExpiresAt)
+}
+
+// Invites returns the outstanding invites, soonest to expire first.
+func (l *Lobby) Invites() []*Invite {
+	l.Lock()
+	defer l.Unlock()
+
+	l.pruneInvites(l.clock.Now())
+	invites := slices.Collect(maps.Values(l.access.invites))
+	slices.SortFunc(invites, func(a, b *Invite) int {
+		return a.ExpiresAt.Compare(b.ExpiresAt)
+	})
+	return invites
+}
+
+// RevokeInvite stops an invite from letting anyone else in, reporting
+// whether it was outstanding.
+func (l *Lobby) RevokeInvite(id string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if _, ok := l.access.invites[id]; !ok {
+		return false
+	}
+	delete(l.access.invites, id)
+
+	l.log.With("func", "RevokeInvite").Debug("Invite revoked", slog.String("inviteID", id))
+	return true
+}
+
+// RevokeInvites revokes every outstanding invite and returns how many there were.
+func (l *Lobby) RevokeInvites() int {
+	l.Lock()
+	defer l.Unlock()
+
+	count := len(l.access.invites)
+	clear(l.access.invites)
+
+	l.log.With("func", "RevokeInvites").Debug("Invites revoked", slog.Int("count", count))
+	return count
+}
+
+// pruneInvites drops expired invites, the caller holds the lobby lock.
+func (l *Lobby) pruneInvites(now time.Time) {
+	for id, invite := range l.access.invites {
+		if !now.Before(invite.ExpiresAt) {
+			delete(l.access.invites, id)
+		}
+	}
+}
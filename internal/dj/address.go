@@ -0,0 +1,55 @@
+package dj
+
+// IP policies, how many users may share a client address.
+const (
+	// IPPolicyOff lets any number of users share an address.
+	IPPolicyOff = "off"
+	// IPPolicyPerIP allows one user per address on the server, a new user
+	// on the address replaces the old one.
+	IPPolicyPerIP = "per_ip"
+	// IPPolicyPerLobby allows one user per address in each lobby.
+	IPPolicyPerLobby = "per_lobby"
+)
+
+var IPPolicies = []string{IPPolicyOff, IPPolicyPerIP, IPPolicyPerLobby}
+
+// SharingAddress returns the users the IP policy treats as the same client
+// as someone on ip in lobby: everyone on ip for per_ip, those in lobby for
+// per_lobby and nobody when the policy is off. Lobby may be nil.
+func (m *LobbyManager) SharingAddress(ip string, lobby *Lobby) []*User {
+	var users []*User
+	switch m.Settings().IPPolicy {
+	case IPPolicyPerIP:
+		for user := range m.Users.Values() {
+			if user.IP == ip {
+				users = append(users, user)
+			}
+		}
+	case IPPolicyPerLobby:
+		if lobby == nil {
+			return nil
+		}
+		for user := range lobby.Users.Values() {
+			if user.IP == ip {
+				users = append(users, user)
+			}
+		}
+	}
+	return users
+}
+
+// addressIsIdentity reports whether an address stands for one person, in
+// which case mutes and ownership follow the address across sessions.
+func (m *LobbyManager) addressIsIdentity() bool {
+	return m.Settings().IPPolicy != IPPolicyOff
+}
+
+// MuteKey is what the user's mutes and mute vote cooldowns are kept under:
+// the address so rejoining does not lift them, or the user when addresses
+// are shared.
+func (l *Lobby) MuteKey(user *User) string {
+	if l.Manager.addressIsIdentity() {
+		return user.IP
+	}
+	return "user:" + user.ID
+}
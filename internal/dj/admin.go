@@ -0,0 +1,56 @@
+package dj
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Announce shows a message to every user in every lobby.
+func (m *LobbyManager) Announce(message string) int {
+	m.log.With("func", "Announce").
+		Info("Broadcasting announcement", slog.String("message", message))
+
+	toast := formatToast(message, ToastInfo)
+	count := 0
+	for l := range m.Lobbies.Values() {
+		l.Broadcast(UpdateToast, toast)
+		count++
+	}
+	return count
+}
+
+// KickUser removes the user from the lobby and ends their session. It
+// reports whether the user was in the lobby.
+func (m *LobbyManager) KickUser(l *Lobby, userID string) bool {
+	user, ok := l.Users.Get(userID)
+	if !ok {
+		return false
+	}
+
+	m.log.With("func", "KickUser").
+		Info("Kicking user", l.Log(), user.Log())
+
+	l.removeUser(user, UserKicked)
+	m.EndSession(user)
+	m.Users.Delete(user.ID)
+	return true
+}
+
+// ClearMutes lifts every mute and mute vote cooldown in the lobby, returning
+// the number of mutes lifted.
+func (l *Lobby) ClearMutes() int {
+	l.Lock()
+	count := l.Mutes.Length()
+	l.Mutes.Clear()
+	l.MuteCooldowns.Clear()
+	for user := range l.Users.Values() {
+		user.MutedUntil = time.Time{}
+	}
+	l.Unlock()
+
+	l.log.With("func", "ClearMutes").
+		Info("Cleared mutes", slog.Int("count", count))
+
+	l.Broadcast(UpdateUsers, formatUsersUpdate(l.Users.ValuesSlice()))
+	return count
+}
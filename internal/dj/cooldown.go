@@ -0,0 +1,92 @@
+package dj
+
+import (
+	"time"
+
+	"github.com/btnmasher/safemap"
+)
+
+const (
+	CooldownScopeGlobal    = "global"
+	CooldownScopeSubmitter = "submitter"
+)
+
+var CooldownScopeDisplayName = map[string]string{
+	CooldownScopeGlobal:    "Anyone",
+	CooldownScopeSubmitter: "Same submitter",
+}
+
+const DefaultReplayCooldown = 1 * time.Hour
+
+// ReplayCooldown guards against the same video being queued again too soon
+// after it was played. With the global scope a video is blocked for everyone,
+// with the submitter scope only the user who submitted it is blocked.
+// A zero Window disables the cooldown.
+type ReplayCooldown struct {
+	Window time.Duration
+	Scope  string
+
+	playedAt safemap.SafeMap[string, time.Time]
+}
+
+func NewReplayCooldown(window time.Duration, scope string) *ReplayCooldown {
+	if _, ok := CooldownScopeDisplayName[scope]; !ok {
+		scope = CooldownScopeGlobal
+	}
+
+	return &ReplayCooldown{
+		Window:   max(window, 0),
+		Scope:    scope,
+		playedAt: safemap.NewMutexMap[string, time.Time](),
+	}
+}
+
+func (c *ReplayCooldown) key(videoId, submitterId string) string {
+	if c.Scope == CooldownScopeSubmitter {
+		return submitterId + "/" + videoId
+	}
+	return videoId
+}
+
+// Record marks the video as played by the submitter at the given time.
+func (c *ReplayCooldown) Record(videoId, submitterId string, at time.Time) {
+	if c.Window <= 0 {
+		return
+	}
+	c.playedAt.Set(c.key(videoId, submitterId), at)
+}
+
+// Remaining returns how long until the submitter may queue the video again.
+func (c *ReplayCooldown) Remaining(videoId, submitterId string, now time.Time) (time.Duration, bool) {
+	if c.Window <= 0 {
+		return 0, false
+	}
+
+	at, ok := c.playedAt.Get(c.key(videoId, submitterId))
+	if !ok {
+		return 0, false
+	}
+
+	remaining := at.Add(c.Window).Sub(now)
+	return remaining, remaining > 0
+}
+
+// Prune drops entries whose cooldown has elapsed and returns their keys.
+func (c *ReplayCooldown) Prune(now time.Time) []string {
+	expired := make([]string, 0)
+	for key, at := range c.playedAt.All() {
+		if now.Sub(at) >= c.Window {
+			expired = append(expired, key)
+		}
+	}
+
+	for _, key := range expired {
+		c.playedAt.Delete(key)
+	}
+
+	return expired
+}
+
+func (c *ReplayCooldown) Length() int {
+	return c.playedAt.Length()
+}
@@ -0,0 +1,105 @@
+package dj
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReplayCooldownScopes(t *testing.T) {
+	tests := []struct {
+		scope        string
+		othersWait   bool
+		unknownScope bool
+	}{
+		{scope: CooldownScopeGlobal, othersWait: true},
+		{scope: CooldownScopeSubmitter, othersWait: false},
+		{scope: "bogus", othersWait: true, unknownScope: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			m, fake := newTestManager(t, 1)
+			l, users := newTestLobby(m, LobbyModeLinear, "a", "b")
+			l.ReplayCooldown = NewReplayCooldown(time.Hour, tt.scope)
+			if tt.unknownScope && l.ReplayCooldown.Scope != CooldownScopeGlobal {
+				t.Fatalf("Scope = %q, want %q", l.ReplayCooldown.Scope, CooldownScopeGlobal)
+			}
+
+			l.AddVideo(context.Background(), testVideo("first", users[0]))
+			l.AddVideo(context.Background(), testVideo("second", users[0]))
+			if _, blocked := l.ReplayCooldown.Remaining("first", users[0].ID, fake.Now()); blocked {
+				t.Fatal("video on cooldown while it is still playing")
+			}
+
+			// The cooldown starts when the video stops playing
+			l.AdvancePlaylist()
+			fake.Advance(10 * time.Minute)
+
+			remaining, blocked := l.ReplayCooldown.Remaining("first", users[0].ID, fake.Now())
+			if !blocked || remaining != 50*time.Minute {
+				t.Errorf("submitter: Remaining = %v, %v, want 50m, true", remaining, blocked)
+			}
+			if _, blocked := l.ReplayCooldown.Remaining("first", users[1].ID, fake.Now()); blocked != tt.othersWait {
+				t.Errorf("other user blocked = %v, want %v", blocked, tt.othersWait)
+			}
+
+			if _, blocked := l.ReplayCooldown.Remaining("first", users[0].ID, epoch.Add(time.Hour)); blocked {
+				t.Error("still blocked once the window has passed")
+			}
+		})
+	}
+}
+
+func TestReplayCooldownDisabled(t *testing.T) {
+	c := NewReplayCooldown(0, CooldownScopeGlobal)
+	c.Record("video", "user", epoch)
+
+	if c.Length() != 0 {
+		t.Errorf("Length = %d, want nothing recorded", c.Length())
+	}
+	if _, blocked := c.Remaining("video", "user", epoch); blocked {
+		t.Error("blocked with a zero window")
+	}
+
+	if c := NewReplayCooldown(-time.Minute, CooldownScopeGlobal); c.Window != 0 {
+		t.Errorf("Window = %v, want negative windows clamped to 0", c.Window)
+	}
+}
+
+func TestReplayCooldownPrune(t *testing.T) {
+	_, fake := newTestManager(t, 1)
+	c := NewReplayCooldown(time.Hour, CooldownScopeSubmitter)
+
+	c.Record("old", "alice", fake.Now())
+	fake.Advance(30 * time.Minute)
+	c.Record("new", "alice", fake.Now())
+	c.Record("old", "bob", fake.Now())
+
+	if expired := c.Prune(fake.Now()); len(expired) != 0 {
+		t.Errorf("pruned %q before any window passed", expired)
+	}
+
+	fake.Advance(30 * time.Minute)
+	expired := c.Prune(fake.Now())
+	if !slices.Equal(expired, []string{"alice/old"}) {
+		t.Errorf("pruned %q, want [alice/old]", expired)
+	}
+	if c.Length() != 2 {
+		t.Errorf("Length = %d, want 2", c.Length())
+	}
+	if _, blocked := c.Remaining("old", "bob", fake.Now()); !blocked {
+		t.Error("bob's entry was pruned with alice's")
+	}
+
+	fake.Advance(30 * time.Minute)
+	expired = c.Prune(fake.Now())
+	slices.Sort(expired)
+	if !slices.Equal(expired, []string{"alice/new", "bob/old"}) {
+		t.Errorf("pruned %q, want [alice/new bob/old]", expired)
+	}
+	if c.Length() != 0 {
+		t.Errorf("Length = %d, want 0", c.Length())
+	}
+}
@@ -0,0 +1,164 @@
+package dj
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// UpdateDirectory is sent on the directory stream when the public listing
+// may have changed.
+const UpdateDirectory = "directory_update"
+
+// Listing limits.
+const (
+	MaxListingDescriptionLength = 280
+	MaxListingTags              = 5
+	MaxListingTagLength         = 20
+)
+
+// Listing is how a lobby shows up in the public directory, under its title.
+type Listing struct {
+	Public      bool     `json:"public"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+func (p Listing) Log() slog.Attr {
+	return slog.Group("listing",
+		slog.Bool("Public", p.Public),
+		slog.Any("Tags", p.Tags),
+	)
+}
+
+// DirectoryEntry is a public lobby as listed in the directory.
+type DirectoryEntry struct {
+	ID          string   `json:"id"`
+	Slug        string   `json:"slug,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Users       int      `json:"users"`
+	Mode        string   `json:"mode"`
+	Access      string   `json:"access"`
+	NowPlaying  string   `json:"nowPlaying,omitempty"`
+}
+
+// Listing returns how the lobby is listed in the directory.
+func (l *Lobby) Listing() Listing {
+	l.Lock()
+	defer l.Unlock()
+
+	p := l.listing
+	p.Tags = slices.Clone(p.Tags)
+	return p
+}
+
+// SetListing replaces how the lobby is listed, public lobbies show up in the
+// directory unless only invites let people in.
+func (l *Lobby) SetListing(p Listing) {
+	l.Lock()
+	l.listing = p
+	l.Unlock()
+
+	wasListed := l.listed.Swap(p.Public)
+
+	l.log.With("func", "SetListing").
+		Debug("Listing updated", p.Log())
+
+	if wasListed || p.Public {
+		l.Manager.notifyDirectory()
+	}
+}
+
+// directoryEntry returns the lobby as listed in the directory, if it is.
+func (l *Lobby) directoryEntry() (DirectoryEntry, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if !l.listing.Public || l.access.mode == AccessInvite {
+		return DirectoryEntry{}, false
+	}
+
+	entry := DirectoryEntry{
+		ID:          l.ID,
+		Slug:        l.Slug,
+		Title:       cmp.Or(l.Title, l.ID),
+		Description: l.listing.Description,
+		Tags:        slices.Clone(l.listing.Tags),
+		Users:       l.Users.Length(),
+		Mode:        l.Mode,
+		Access:      l.access.mode,
+	}
+	if l.CurrentVideo != nil {
+		entry.NowPlaying = l.CurrentVideo.Title
+	}
+	return entry, true
+}
+
+// Directory returns the public lobbies, busiest first. With a tag only the
+// lobbies carrying it are returned.
+func (m *LobbyManager) Directory(tag string) []DirectoryEntry {
+	tag = strings.ToLower(tag)
+
+	entries := make([]DirectoryEntry, 0)
+	for l := range m.Lobbies.Values() {
+		if !l.listed.Load() {
+			continue
+		}
+		entry, ok := l.directoryEntry()
+		if !ok || (tag != "" && !slices.Contains(entry.Tags, tag)) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	slices.SortFunc(entries, func(a, b DirectoryEntry) int {
+		return cmp.Or(
+			cmp.Compare(b.Users, a.Users),
+			strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)),
+			strings.Compare(a.ID, b.ID),
+		)
+	})
+	return entries
+}
+
+// WatchDirectory returns a channel signalled when the directory may have
+// changed, and a func to stop watching. Signals are dropped while one is
+// pending, so a slow reader sees one signal for many changes.
+func (m *LobbyManager) WatchDirectory() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	m.directoryMu.Lock()
+	m.directoryWatchers[ch] = struct{}{}
+	m.directoryMu.Unlock()
+
+	return ch, func() {
+		m.directoryMu.Lock()
+		delete(m.directoryWatchers, ch)
+		m.directoryMu.Unlock()
+	}
+}
+
+func (m *LobbyManager) notifyDirectory() {
+	m.directoryMu.Lock()
+	defer m.directoryMu.Unlock()
+
+	for ch := range m.directoryWatchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// directoryEvent reports whether a lobby event changes what the directory
+// shows for it.
+func directoryEvent(event string) bool {
+	switch event {
+	case UpdateUsers, UpdateVideo, UpdateTitle, UpdateLobbyExpired:
+		return true
+	}
+	return false
+}
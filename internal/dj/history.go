@@ -0,0 +1,113 @@
+package dj
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// MaxPlayLogEntries bounds the play log, the oldest records are dropped first.
+const MaxPlayLogEntries = 1000
+
+// HistoryDisplayLimit is how many plays the lobby history tab renders.
+const HistoryDisplayLimit = 100
+
+const (
+	VoteOutcomeNone   = "none"
+	VoteOutcomePassed = "passed"
+	VoteOutcomeFailed = "failed"
+)
+
+// PlayRecord is a single entry in the lobby play log. The play log is
+// append-only and keeps one record per play, so replays of the same video
+// show up as separate entries. It is independent of the ReplayCooldown.
+type PlayRecord struct {
+	VideoID       string
+	Title         string
+	Channel       string
+	ThumbnailURL  string
+	SubmitterID   string
+	SubmitterName string
+	Duration      time.Duration
+	StartedAt     time.Time
+	EndedAt       time.Time
+	Skipped       bool
+	Failed        bool
+	PlayerError   int
+	VoteOutcome   string
+	Reactions     map[string]int
+}
+
+func newPlayRecord(v *Video, startedAt time.Time) *PlayRecord {
+	return &PlayRecord{
+		VideoID:       v.ID,
+		Title:         v.Title,
+		Channel:       v.Channel,
+		ThumbnailURL:  v.ThumbnailURL,
+		SubmitterID:   v.SubmitterID,
+		SubmitterName: v.SubmitterName,
+		Duration:      v.Duration,
+		StartedAt:     startedAt,
+		VoteOutcome:   VoteOutcomeNone,
+	}
+}
+
+// finish stamps the end of the play with the final state of the video.
+func (p *PlayRecord) finish(v *Video, endedAt time.Time) {
+	p.EndedAt = endedAt
+	p.Skipped = v.WasSkipped
+	p.Failed = v.Failed
+	p.PlayerError = v.PlayerError
+	p.Reactions = maps.Clone(v.Reactions)
+
+	if v.WasVoted {
+		if v.WasSkipped {
+			p.VoteOutcome = VoteOutcomePassed
+		} else {
+			p.VoteOutcome = VoteOutcomeFailed
+		}
+	}
+}
+
+// InProgress reports whether the record belongs to the video currently playing.
+func (p *PlayRecord) InProgress() bool {
+	return p.EndedAt.IsZero()
+}
+
+func (l *Lobby) appendPlayRecord(p *PlayRecord) {
+	if over := len(l.PlayLog) + 1 - MaxPlayLogEntries; over > 0 {
+		l.PlayLog = slices.Delete(l.PlayLog, 0, over)
+	}
+	l.PlayLog = append(l.PlayLog, p)
+}
+
+// RecentPlays returns the finished plays, most recent first, up to limit
+// entries. The caller must hold the lobby lock.
+func (l *Lobby) RecentPlays(limit int) []*PlayRecord {
+	plays := make([]*PlayRecord, 0, min(limit, len(l.PlayLog)))
+	for _, p := range slices.Backward(l.PlayLog) {
+		if len(plays) >= limit {
+			break
+		}
+		if p.InProgress() {
+			continue
+		}
+		plays = append(plays, p)
+	}
+	return plays
+}
+
+// PlayHistory returns a snapshot of the play log in the order videos were
+// played. The caller must hold the lobby lock.
+func (l *Lobby) PlayHistory() []PlayRecord {
+	records := make([]PlayRecord, 0, len(l.PlayLog))
+	for _, p := range l.PlayLog {
+		record := *p
+		record.Reactions = maps.Clone(p.Reactions)
+		if p == l.currentPlay && l.CurrentVideo != nil {
+			record.Reactions = maps.Clone(l.CurrentVideo.Reactions)
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -6,14 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"math/rand"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btnmasher/safemap"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
-	"github.com/btnmasher/testdj/internal/shared"
+	"github.com/btnmasher/testdj/internal/clock"
+	"github.com/btnmasher/testdj/internal/metrics"
 	"github.com/btnmasher/testdj/internal/sse"
 )
 
@@ -30,6 +34,11 @@ const (
 	LobbyModeLinear     = "linear"
 )
 
+// LobbyIdleTimeout is how long a lobby lives without activity, by default.
+const LobbyIdleTimeout = 1 * time.Hour
+
+var tracer = otel.Tracer("github.com/btnmasher/testdj/internal/dj")
+
 var ModeDisplayName = map[string]string{
 	LobbyModeShuffle:    "Shuffle",
 	LobbyModeRoundRobin: "Round Robin",
@@ -51,31 +60,47 @@ func formatUsersUpdate(users []*User) string {
 
 type Lobby struct {
 	sync.Mutex
-	ID                string
-	Mode              string
-	CreatorIP         string
-	LobbyQueueLimit   int
-	UserQueueLimit    int
-	CreatedAt         time.Time
-	VideoStart        time.Time
-	ExpiresAt         time.Time
-	Users             safemap.SafeMap[string, *User]
-	UsersBySession    safemap.SafeMap[string, *User]
-	MutesByIP         safemap.SafeMap[string, time.Time]
-	MuteCooldownsByIP safemap.SafeMap[string, time.Time]
-	Videos            []*Video
-	RoundRobinQueue   []string
-	CurrentVideo      *Video
-	PlayedVideos      safemap.SafeMap[string, *Video]
-	VoteSkip          VoteSkipStatus
-	VoteMute          VoteMuteStatus
-
-	nextTimer          *time.Timer
-	voteSkipTimer      *time.Timer
-	voteMuteTimer      *time.Timer
-	expiryTimer        *time.Timer
-	muteExpiryTicker   *time.Ticker
-	videoCleanupTicker *time.Ticker
+	ID               string
+	Title            string
+	Slug             string
+	Mode             string
+	CreatorIP        string
+	CreatorID        string
+	LobbyQueueLimit  int
+	UserQueueLimit   int
+	CreatedAt        time.Time
+	VideoStart       time.Time
+	ExpiresAt        time.Time
+	Users            safemap.SafeMap[string, *User]
+	Mutes            safemap.SafeMap[string, time.Time] // by MuteKey
+	MuteCooldowns    safemap.SafeMap[string, time.Time] // by MuteKey
+	Videos           []*Video
+	RoundRobinQueue  []string
+	CurrentVideo     *Video
+	ReplayCooldown   *ReplayCooldown
+	Policy           ContentPolicy
+	PlayerErrorShare float64
+	access           access
+	listing          Listing
+	listed           atomic.Bool // mirrors listing.Public, read without the lock
+	PlayLog          []*PlayRecord
+	VoteSkip         VoteSkipStatus
+	VoteMute         VoteMuteStatus
+
+	pendingReactions   map[string]int
+	playerErrors       map[int]map[string]bool
+	playerErrorQuorate bool // reports on the current video reached quorum
+	currentPlay        *PlayRecord
+
+	nextTimer           clock.Timer
+	voteSkipTimer       clock.Timer
+	voteMuteTimer       clock.Timer
+	expiryTimer         clock.Timer
+	muteExpiryTicker    clock.Ticker
+	videoCleanupTicker  clock.Ticker
+	reactionFlushTicker clock.Ticker
+	clock               clock.Clock
+	rand                Rand
 
 	log     *slog.Logger
 	Manager *LobbyManager
@@ -89,65 +114,144 @@ type User struct {
 	Variant       int         `json:"variant"`
 	IP            string      `json:"-"`
 	LobbyID       string      `json:"-"`
-	SessionID     string      `json:"-"`
 	MutedUntil    time.Time   `json:"-"`
-	LastActivity  time.Time   `json:"-"`
 	PendingLogout time.Time   `json:"-"`
 	SSE           *sse.Client `json:"-"`
+
+	// lastActivity is written by requests and read by CleanupUsers, it
+	// holds unix nanoseconds.
+	lastActivity atomic.Int64
+	session      session
+}
+
+// LastActivity returns when the user was last seen.
+func (u *User) LastActivity() time.Time {
+	return time.Unix(0, u.lastActivity.Load()).UTC()
+}
+
+// MarkActive records that the user was seen at the given time.
+func (u *User) MarkActive(at time.Time) {
+	u.lastActivity.Store(at.UnixNano())
+}
+
+// BackdateActivity moves the user's last activity back to the given time,
+// so they time out sooner. Later activity is left alone.
+func (u *User) BackdateActivity(at time.Time) {
+	for {
+		last := u.lastActivity.Load()
+		if at.UnixNano() >= last || u.lastActivity.CompareAndSwap(last, at.UnixNano()) {
+			return
+		}
+	}
 }
 
 type Video struct {
-	ID            string
-	URL           string
-	Title         string
-	SubmitterID   string
-	SubmitterName string
-	WasVoted      bool
-	WasSkipped    bool
-	Duration      time.Duration
-	LastPlayed    time.Time
+	ID            string         `json:"id"`
+	URL           string         `json:"url"`
+	Title         string         `json:"title"`
+	Channel       string         `json:"channel,omitempty"`
+	ChannelID     string         `json:"channelId,omitempty"`
+	ThumbnailURL  string         `json:"thumbnailUrl,omitempty"`
+	PublishedAt   time.Time      `json:"publishedAt,omitzero"`
+	ViewCount     int64          `json:"viewCount,omitempty"`
+	SubmitterID   string         `json:"submitterId"`
+	SubmitterName string         `json:"submitterName"`
+	WasVoted      bool           `json:"wasVoted"`
+	WasSkipped    bool           `json:"wasSkipped"`
+	Failed        bool           `json:"failed"`
+	PlayerError   int            `json:"playerError,omitempty"`
+	Duration      time.Duration  `json:"duration"`
+	Reactions     map[string]int `json:"reactions,omitempty"`
+}
+
+// SearchResult is a video found by searching that can be picked to add.
+type SearchResult struct {
+	ID        string
+	Title     string
+	Channel   string
+	Duration  time.Duration
+	Thumbnail string
 }
 
 var LobbyExpired = errors.New("lobby expired")
 var UserTimeout = errors.New("user timeout")
+var UserKicked = errors.New("user kicked")
 
 const (
-	LobbyIDLength   = 7
-	UserIDLength    = 9
-	SessionIDLength = 12
+	LobbyIDLength = 7
+	UserIDLength  = 9
 )
 
-func (m *LobbyManager) NewUser(name, ip string) *User {
+// NewUser registers a user with a new session, returning it with the
+// session cookie value for the client.
+func (m *LobbyManager) NewUser(name, ip string) (*User, string) {
+	now := m.clock.Now()
 	user := &User{
-		ID:           shared.GenerateID(UserIDLength),
-		SessionID:    shared.GenerateID(SessionIDLength),
-		Name:         name,
-		IP:           ip,
-		LastActivity: time.Now(),
-		Color:        rand.Intn(12),
-		Variant:      rand.Intn(10),
-	}
-	m.UsersByIP.Set(user.IP, user)
-	m.UsersBySessionID.Set(user.SessionID, user)
+		ID:      m.newID(UserIDLength),
+		Name:    name,
+		IP:      ip,
+		Color:   m.rand.Intn(12),
+		Variant: m.rand.Intn(10),
+	}
+	user.MarkActive(now)
+	user.session.expiresAt = now.Add(m.Settings().SessionLifetime)
+	m.Users.Set(user.ID, user)
+
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	return user, m.issueSession(user)
+}
 
-	return user
+// LobbyOptions are the settings chosen by the creator of a lobby.
+type LobbyOptions struct {
+	Mode           string
+	UserQueueLimit int
+	CreatorIP      string
+	CreatorID      string
+	ReplayCooldown time.Duration
+	CooldownScope  string
+
+	// PlayerErrorShare is the share of connected users, between 0 and 1, that
+	// must report a player error to skip the video. Zero uses the default.
+	PlayerErrorShare float64
+
+	// Title is checked with ValidTitle beforehand.
+	Title string
+
+	// Access is one of AccessModes, Password is required for AccessPassword
+	// and checked with ValidPassword beforehand.
+	Access   string
+	Password string
 }
 
-func (m *LobbyManager) NewLobby(mode string, maxQueue int, creatorIP string) *Lobby {
-	now := time.Now()
-	id := shared.GenerateID(LobbyIDLength)
+func (m *LobbyManager) NewLobby(opts LobbyOptions) *Lobby {
+	now := m.clock.Now()
+	settings := m.Settings()
+	idle := settings.LobbyIdleTimeout
+	id := m.newLobbyID()
 	log := m.log.With("service", "lobby", "LobbyID", id)
 
+	errorShare := opts.PlayerErrorShare
+	if errorShare <= 0 || errorShare > 1 {
+		errorShare = DefaultPlayerErrorShare
+	}
+
 	l := &Lobby{
-		ID:                id,
-		Mode:              mode,
-		UserQueueLimit:    maxQueue,
-		Users:             safemap.NewMutexMap[string, *User](),
-		UsersBySession:    safemap.NewMutexMap[string, *User](),
-		MutesByIP:         safemap.NewMutexMap[string, time.Time](),
-		Videos:            []*Video{},
-		PlayedVideos:      safemap.NewMutexMap[string, *Video](),
-		MuteCooldownsByIP: safemap.NewMutexMap[string, time.Time](),
+		ID:               id,
+		Title:            opts.Title,
+		Mode:             opts.Mode,
+		UserQueueLimit:   opts.UserQueueLimit,
+		Users:            safemap.NewMutexMap[string, *User](),
+		Mutes:            safemap.NewMutexMap[string, time.Time](),
+		Videos:           []*Video{},
+		pendingReactions: make(map[string]int),
+		playerErrors:     make(map[int]map[string]bool),
+		ReplayCooldown:   NewReplayCooldown(opts.ReplayCooldown, opts.CooldownScope),
+		Policy:           settings.lobbyPolicy(),
+		PlayerErrorShare: errorShare,
+		access:           access{mode: AccessOpen, invites: make(map[string]*Invite)},
+		PlayLog:          make([]*PlayRecord, 0),
+		MuteCooldowns:    safemap.NewMutexMap[string, time.Time](),
 		VoteSkip: VoteSkipStatus{
 			YesVotes: safemap.NewMutexMap[string, bool](),
 			NoVotes:  safemap.NewMutexMap[string, bool](),
@@ -156,16 +260,29 @@ func (m *LobbyManager) NewLobby(mode string, maxQueue int, creatorIP string) *Lo
 			YesVotes: safemap.NewMutexMap[string, bool](),
 			NoVotes:  safemap.NewMutexMap[string, bool](),
 		},
-		CreatorIP:          creatorIP,
-		CreatedAt:          now,
-		ExpiresAt:          now.Add(1 * time.Hour),
-		nextTimer:          time.NewTimer(0),
-		voteSkipTimer:      time.NewTimer(0),
-		voteMuteTimer:      time.NewTimer(0),
-		expiryTimer:        time.NewTimer(1 * time.Hour),
-		muteExpiryTicker:   time.NewTicker(5 * time.Second),
-		videoCleanupTicker: time.NewTicker(1 * time.Minute),
-		log:                log,
+		CreatorIP:           opts.CreatorIP,
+		CreatorID:           opts.CreatorID,
+		CreatedAt:           now,
+		ExpiresAt:           now.Add(idle),
+		nextTimer:           m.clock.NewTimer(0),
+		voteSkipTimer:       m.clock.NewTimer(0),
+		voteMuteTimer:       m.clock.NewTimer(0),
+		expiryTimer:         m.clock.NewTimer(idle),
+		muteExpiryTicker:    m.clock.NewTicker(5 * time.Second),
+		videoCleanupTicker:  m.clock.NewTicker(1 * time.Minute),
+		reactionFlushTicker: m.clock.NewTicker(ReactionFlushInterval),
+		Manager:             m,
+		clock:               m.clock,
+		rand:                m.rand,
+		log:                 log,
+	}
+
+	if opts.Access != "" && opts.Access != AccessOpen {
+		if err := l.SetAccess(opts.Access, opts.Password); err != nil {
+			// Never leave a lobby meant to be private open
+			log.Error("Could not set lobby access, only invites will work", slog.String("error", err.Error()))
+			l.access.mode = AccessInvite
+		}
 	}
 
 	log.Debug("New lobby created")
@@ -174,23 +291,30 @@ func (m *LobbyManager) NewLobby(mode string, maxQueue int, creatorIP string) *Lo
 	l.Cancel = cancel
 
 	// flush out the newly initialized timer ticks
-	<-l.nextTimer.C
-	<-l.voteSkipTimer.C
-	<-l.voteMuteTimer.C
+	<-l.nextTimer.C()
+	<-l.voteSkipTimer.C()
+	<-l.voteMuteTimer.C()
 	go l.timerMinder(cancelCtx)
 
 	m.AddLobby(l)
 	return l
 }
 
+// Now returns the current time on the lobby clock.
+func (l *Lobby) Now() time.Time {
+	return l.clock.Now()
+}
+
 func (l *Lobby) Touch() {
+	idle := l.Manager.Settings().LobbyIdleTimeout
 	l.expiryTimer.Stop()
-	l.ExpiresAt = time.Now().Add(1 * time.Hour)
-	l.expiryTimer.Reset(1 * time.Hour)
+	l.ExpiresAt = l.clock.Now().Add(idle)
+	l.expiryTimer.Reset(idle)
 }
 
 func (l *Lobby) Expire() {
 	l.log.Info("Lobby Expired")
+	metrics.LobbyExpirations.Inc()
 	l.Broadcast(UpdateLobbyExpired, "")
 	for user := range l.Users.Values() {
 		if user.SSE != nil {
@@ -209,6 +333,10 @@ func (l *Lobby) Broadcast(event, data string) {
 			user.SSE.Send(event, data)
 		}
 	}
+
+	if l.listed.Load() && directoryEvent(event) {
+		l.Manager.notifyDirectory()
+	}
 }
 
 func (l *Lobby) AddUser(user *User) {
@@ -225,13 +353,12 @@ func (l *Lobby) AddUser(user *User) {
 
 	user.LobbyID = l.ID
 	l.Users.Set(user.ID, user)
-	l.UsersBySession.Set(user.SessionID, user)
 
 	l.Lock()
 	l.RoundRobinQueue = append(l.RoundRobinQueue, user.ID)
 	l.Unlock()
 
-	if mute, exists := l.MutesByIP.Get(user.IP); exists {
+	if mute, exists := l.Mutes.Get(l.MuteKey(user)); exists {
 		user.MutedUntil = mute
 	}
 
@@ -242,19 +369,28 @@ func (l *Lobby) AddUser(user *User) {
 }
 
 func (l *Lobby) RemoveUser(user *User) {
+	l.removeUser(user, UserTimeout)
+}
+
+// removeUser takes the user out of the lobby, closing their event stream
+// with cause.
+func (l *Lobby) removeUser(user *User, cause error) {
+	l.Lock()
 	for i, uid := range l.RoundRobinQueue {
 		if uid == user.ID {
 			l.RoundRobinQueue = append(l.RoundRobinQueue[:i], l.RoundRobinQueue[i+1:]...)
 			break
 		}
 	}
+	l.Unlock()
+
 	if l.Users.Delete(user.ID) {
 		l.log.With("func", "RemoveUser").
 			Debug("Removing User", user.Log())
 
 		if user.SSE != nil && user.SSE.Context.Err() == nil {
 			user.SSE.Send("redirect", "/")
-			user.SSE.Cancel(UserTimeout)
+			user.SSE.Cancel(cause)
 		}
 
 		l.Broadcast(UpdateUsers, formatUsersUpdate(l.Users.ValuesSlice()))
@@ -274,13 +410,13 @@ func (l *Lobby) CheckVideoQueued(videoId string) bool {
 	return false
 }
 
-func (l *Lobby) AddVideo(video *Video) {
-	l.Lock()
-	defer l.Unlock()
+func (l *Lobby) AddVideo(ctx context.Context, video *Video) {
+	defer l.LockTraced(ctx, "AddVideo")()
 
 	log := l.log.With("func", "AddVideo", video.Log())
 
 	l.Videos = append(l.Videos, video)
+	metrics.VideosQueued.Inc()
 	if l.CurrentVideo == nil {
 		log.Debug("Video added with none currently playing, advancing playlist")
 		l.PickNextVideo()
@@ -312,19 +448,21 @@ minderLoop:
 		select {
 		case <-ctx.Done():
 			break minderLoop
-		case <-l.expiryTimer.C:
+		case <-l.expiryTimer.C():
 			l.Expire()
 			break minderLoop
-		case <-l.nextTimer.C:
-			go l.PickNextVideo()
-		case <-l.voteSkipTimer.C:
+		case <-l.nextTimer.C():
+			go l.AdvancePlaylist()
+		case <-l.voteSkipTimer.C():
 			go l.CalcVoteSkipResult()
-		case <-l.voteMuteTimer.C:
+		case <-l.voteMuteTimer.C():
 			go l.CalcVoteMuteResult()
-		case <-l.muteExpiryTicker.C:
+		case <-l.muteExpiryTicker.C():
 			go l.CleanupMuteExpirations()
-		case <-l.videoCleanupTicker.C:
-			go l.CleanupPlayedVideos()
+		case <-l.videoCleanupTicker.C():
+			go l.CleanupReplayCooldowns()
+		case <-l.reactionFlushTicker.C():
+			go l.FlushReactions()
 		}
 	}
 
@@ -333,40 +471,41 @@ minderLoop:
 	l.voteSkipTimer.Stop()
 	l.voteMuteTimer.Stop()
 	l.videoCleanupTicker.Stop()
+	l.reactionFlushTicker.Stop()
 }
 
 func (l *Lobby) CleanupMuteExpirations() {
 	log := l.log.With("func", "CleanupMuteExpirations")
 
-	now := time.Now()
+	now := l.clock.Now()
 	cdsToDelete := make([]string, 0)
-	for ip, exp := range l.MuteCooldownsByIP.All() {
+	for key, exp := range l.MuteCooldowns.All() {
 		if now.After(exp) {
-			cdsToDelete = append(cdsToDelete, ip)
+			cdsToDelete = append(cdsToDelete, key)
 		}
 	}
 
 	mutesToDelete := make([]string, 0)
-	for ip, exp := range l.MutesByIP.All() {
+	for key, exp := range l.Mutes.All() {
 		if now.After(exp) {
-			mutesToDelete = append(mutesToDelete, ip)
+			mutesToDelete = append(mutesToDelete, key)
 		}
 	}
 
 	if len(mutesToDelete) > 0 {
-		log.Debug("Deleting expired mute cooldowns", slog.Any("IPs", cdsToDelete))
+		log.Debug("Deleting expired mute cooldowns", slog.Any("Keys", cdsToDelete))
 	}
 
-	for _, ip := range cdsToDelete {
-		l.MuteCooldownsByIP.Delete(ip)
+	for _, key := range cdsToDelete {
+		l.MuteCooldowns.Delete(key)
 	}
 
 	if len(mutesToDelete) > 0 {
-		log.Debug("Deleting expired mutes", slog.Any("IPs", mutesToDelete))
+		log.Debug("Deleting expired mutes", slog.Any("Keys", mutesToDelete))
 	}
 
-	for _, ip := range mutesToDelete {
-		l.MutesByIP.Delete(ip)
+	for _, key := range mutesToDelete {
+		l.Mutes.Delete(key)
 	}
 
 	if len(cdsToDelete) > 0 || len(mutesToDelete) > 0 {
@@ -374,26 +513,37 @@ func (l *Lobby) CleanupMuteExpirations() {
 	}
 }
 
-func (l *Lobby) CleanupPlayedVideos() {
-	log := l.log.With("func", "CleanupPlayedVideos")
+func (l *Lobby) CleanupReplayCooldowns() {
+	log := l.log.With("func", "CleanupReplayCooldowns")
 
-	now := time.Now()
-	idsToDelete := make([]string, 0)
-	for id, video := range l.PlayedVideos.All() {
-		if now.Sub(video.LastPlayed).Hours() >= 1 {
-			idsToDelete = append(idsToDelete, id)
-		}
+	if expired := l.ReplayCooldown.Prune(l.clock.Now()); len(expired) > 0 {
+		log.Debug("Deleted videos from replay cooldown list", slog.Any("Keys", expired))
 	}
+}
 
-	if len(idsToDelete) > 0 {
-		log.Debug("Deleting videos from play cooldown list", slog.Any("IDs", idsToDelete))
-	}
+// LockTraced takes the lobby lock inside a span named for the section, with
+// an event once the lock is acquired so traces tell waiting and holding apart.
+// The returned func unlocks and ends the span.
+func (l *Lobby) LockTraced(ctx context.Context, section string) (unlock func()) {
+	_, span := tracer.Start(ctx, "Lobby.Lock "+section, trace.WithAttributes(attribute.String("lobby.id", l.ID)))
 
-	for _, id := range idsToDelete {
-		l.PlayedVideos.Delete(id)
+	l.Lock()
+	span.AddEvent("lock acquired")
+
+	return func() {
+		l.Unlock()
+		span.End()
 	}
 }
 
+// AdvancePlaylist moves on to the next video, taking the lobby lock.
+func (l *Lobby) AdvancePlaylist() {
+	l.Lock()
+	defer l.Unlock()
+	l.PickNextVideo()
+}
+
+// PickNextVideo moves on to the next video, the caller must hold the lobby lock.
 func (l *Lobby) PickNextVideo() {
 	log := l.log.With("func", "PickNextVideo")
 
@@ -404,6 +554,7 @@ func (l *Lobby) PickNextVideo() {
 	// Cancel any vote skip if active
 	if l.VoteSkip.Active {
 		log.Debug("Vote skip active during video selection, cancelling")
+		metrics.VoteOutcomes.WithLabelValues(metrics.VoteSkip, metrics.VoteCancelled).Inc()
 		l.voteSkipTimer.Stop()
 		l.VoteSkip.Active = false
 		l.VoteSkip.NoVotes.Clear()
@@ -412,10 +563,19 @@ func (l *Lobby) PickNextVideo() {
 		l.VoteSkip.EndsAt = time.Time{}
 	}
 
+	// Reactions and error reports are keyed to the current video
+	clear(l.pendingReactions)
+	clear(l.playerErrors)
+	l.playerErrorQuorate = false
+
 	last := l.CurrentVideo
 	if last != nil {
-		last.LastPlayed = time.Now()
-		l.PlayedVideos.Set(last.ID, last)
+		now := l.clock.Now()
+		l.ReplayCooldown.Record(last.ID, last.SubmitterID, now)
+		if l.currentPlay != nil {
+			l.currentPlay.finish(last, now)
+			l.currentPlay = nil
+		}
 	}
 
 	if len(l.Videos) == 0 {
@@ -428,7 +588,7 @@ func (l *Lobby) PickNextVideo() {
 	var idx int
 	switch l.Mode {
 	case LobbyModeShuffle:
-		idx = rand.Intn(len(l.Videos))
+		idx = l.rand.Intn(len(l.Videos))
 	case LobbyModeRoundRobin:
 		for range l.RoundRobinQueue {
 			idx = l.getVideoFromUser(l.getNextRobin())
@@ -438,7 +598,7 @@ func (l *Lobby) PickNextVideo() {
 		}
 		if idx == -1 {
 			// no user in the round robin queue had a submitted video, pick a random one
-			idx = rand.Intn(len(l.Videos))
+			idx = l.rand.Intn(len(l.Videos))
 		}
 	}
 
@@ -449,7 +609,10 @@ func (l *Lobby) PickNextVideo() {
 
 	// Set current and signal change
 	l.CurrentVideo = next
-	l.VideoStart = time.Now()
+	l.VideoStart = l.clock.Now()
+	l.currentPlay = newPlayRecord(next, l.VideoStart)
+	l.appendPlayRecord(l.currentPlay)
+	metrics.VideosPlayed.Inc()
 
 	log.Debug("Next video selected", next.Log())
 
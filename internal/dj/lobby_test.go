@@ -0,0 +1,562 @@
+package dj
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/clock"
+	"github.com/btnmasher/testdj/internal/sse"
+)
+
+var epoch = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestManager(t *testing.T, seed int64) (*LobbyManager, *clock.Fake) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	fake := clock.NewFake(epoch)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewLobbyManager(ctx, log, ManagerOptions{Clock: fake, Rand: NewRand(seed)}), fake
+}
+
+func newTestLobby(m *LobbyManager, mode string, users ...string) (*Lobby, []*User) {
+	l := m.NewLobby(LobbyOptions{
+		Mode:           mode,
+		UserQueueLimit: 10,
+		CreatorIP:      "192.0.2.250",
+	})
+
+	joined := make([]*User, 0, len(users))
+	for i, name := range users {
+		u, _ := m.NewUser(name, fmt.Sprintf("192.0.2.%d", i+1))
+		l.AddUser(u)
+		joined = append(joined, u)
+	}
+	return l, joined
+}
+
+func testVideo(id string, submitter *User) *Video {
+	return &Video{
+		ID:            id,
+		Title:         id,
+		SubmitterID:   submitter.ID,
+		SubmitterName: submitter.Name,
+		Duration:      time.Minute,
+	}
+}
+
+// eventually polls cond until it holds, the lobby timers are handled on their
+// own goroutines so results land shortly after the fake clock moves.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func currentVideoID(l *Lobby) string {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.CurrentVideo == nil {
+		return ""
+	}
+	return l.CurrentVideo.ID
+}
+
+// playOrder queues the videos and returns the order they are played in.
+func playOrder(l *Lobby, videos ...*Video) []string {
+	for _, v := range videos {
+		l.AddVideo(context.Background(), v)
+	}
+
+	order := []string{currentVideoID(l)}
+	for range len(videos) - 1 {
+		l.AdvancePlaylist()
+		order = append(order, currentVideoID(l))
+	}
+	return order
+}
+
+func TestNewLobbyIDCollision(t *testing.T) {
+	first, _ := newTestManager(t, 7)
+	taken, _ := newTestLobby(first, "linear")
+
+	// The same seed draws the same code first
+	m, _ := newTestManager(t, 7)
+	m.Lobbies.Set(taken.ID, taken)
+
+	if l, _ := newTestLobby(m, "linear"); l.ID == taken.ID {
+		t.Errorf("lobby code %q was handed out twice", l.ID)
+	}
+}
+
+func TestPickNextVideoLinear(t *testing.T) {
+	m, _ := newTestManager(t, 1)
+	l, users := newTestLobby(m, LobbyModeLinear, "alice")
+
+	got := playOrder(l, testVideo("a", users[0]), testVideo("b", users[0]), testVideo("c", users[0]))
+	if want := []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+
+	l.AdvancePlaylist()
+	if id := currentVideoID(l); id != "" {
+		t.Errorf("CurrentVideo = %q after the queue ran out, want none", id)
+	}
+}
+
+func TestPickNextVideoShuffle(t *testing.T) {
+	order := func(seed int64) []string {
+		m, _ := newTestManager(t, seed)
+		l, users := newTestLobby(m, LobbyModeShuffle, "alice")
+
+		videos := make([]*Video, 0, 8)
+		for i := range 8 {
+			videos = append(videos, testVideo(fmt.Sprintf("v%d", i), users[0]))
+		}
+		return playOrder(l, videos...)
+	}
+
+	first, second := order(42), order(42)
+	if !slices.Equal(first, second) {
+		t.Errorf("same seed gave different orders: %v and %v", first, second)
+	}
+
+	sorted := slices.Sorted(slices.Values(first))
+	if want := []string{"v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7"}; !slices.Equal(sorted, want) {
+		t.Errorf("played %v, want each video once", first)
+	}
+}
+
+func TestPickNextVideoRoundRobin(t *testing.T) {
+	m, _ := newTestManager(t, 1)
+	l, users := newTestLobby(m, LobbyModeRoundRobin, "alice", "bob")
+	alice, bob := users[0], users[1]
+
+	got := playOrder(l, testVideo("a1", alice), testVideo("a2", alice), testVideo("b1", bob))
+	if want := []string{"a1", "b1", "a2"}; !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestAutoAdvance(t *testing.T) {
+	m, fake := newTestManager(t, 1)
+	l, users := newTestLobby(m, LobbyModeLinear, "alice")
+
+	l.AddVideo(context.Background(), testVideo("a", users[0]))
+	l.AddVideo(context.Background(), testVideo("b", users[0]))
+
+	// Videos get a short grace period past their duration
+	fake.Advance(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if id := currentVideoID(l); id != "a" {
+		t.Fatalf("CurrentVideo = %q before the grace period, want a", id)
+	}
+
+	fake.Advance(2 * time.Second)
+	eventually(t, "the next video", func() bool { return currentVideoID(l) == "b" })
+
+	history := l.PlayHistory()
+	if len(history) != 2 || history[0].InProgress() {
+		t.Errorf("history = %+v, want a finished and b playing", history)
+	}
+}
+
+func voteSkipActive(l *Lobby) bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.VoteSkip.Active
+}
+
+func TestVoteSkipTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		yes     int
+		skipped bool
+	}{
+		{"too few votes", 1, false},
+		{"passes", 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, fake := newTestManager(t, 1)
+			l, users := newTestLobby(m, LobbyModeLinear, "a", "b", "c", "d", "e")
+
+			l.AddVideo(context.Background(), testVideo("first", users[0]))
+			l.AddVideo(context.Background(), testVideo("second", users[0]))
+
+			if !l.StartVoteSkip(users[0]) {
+				t.Fatal("StartVoteSkip returned false")
+			}
+			for _, u := range users[1:tt.yes] {
+				l.RecordSkipVote(u, "yes")
+			}
+
+			fake.Advance(VoteDuration - time.Second)
+			if !voteSkipActive(l) {
+				t.Fatal("vote ended before the timeout")
+			}
+
+			fake.Advance(time.Second)
+			eventually(t, "the vote to end", func() bool { return !voteSkipActive(l) })
+
+			want := "first"
+			if tt.skipped {
+				want = "second"
+			}
+			if id := currentVideoID(l); id != want {
+				t.Errorf("CurrentVideo = %q, want %q", id, want)
+			}
+		})
+	}
+}
+
+func TestVoteMuteAndExpiry(t *testing.T) {
+	m, fake := newTestManager(t, 1)
+	l, users := newTestLobby(m, LobbyModeLinear, "a", "b", "c")
+	a, b, c := users[0], users[1], users[2]
+
+	if !l.StartVoteMute(a, c.ID) {
+		t.Fatal("StartVoteMute returned false")
+	}
+	l.RecordMuteVote(b, "yes")
+
+	if !l.MuteCooldowns.Exists(a.IP) {
+		t.Error("no mute cooldown for the initiator")
+	}
+
+	fake.Advance(VoteDuration)
+	eventually(t, "the mute", func() bool { return l.Mutes.Exists(c.IP) })
+
+	l.Lock()
+	mutedUntil := c.MutedUntil
+	l.Unlock()
+	if want := epoch.Add(VoteDuration + VoteMuteDuration); !mutedUntil.Equal(want) {
+		t.Errorf("MutedUntil = %v, want %v", mutedUntil, want)
+	}
+
+	fake.Advance(VoteMuteDuration + 5*time.Second)
+	eventually(t, "the mute to expire", func() bool {
+		return !l.Mutes.Exists(c.IP) && !l.MuteCooldowns.Exists(a.IP)
+	})
+}
+
+func TestCleanupUsers(t *testing.T) {
+	m, fake := newTestManager(t, 1)
+	l, users := newTestLobby(m, LobbyModeLinear, "idle", "active")
+	idle, active := users[0], users[1]
+
+	fake.Advance(30 * time.Second)
+	active.MarkActive(l.Now())
+
+	fake.Advance(UserIdleTimeout - 30*time.Second)
+	m.CleanupUsers()
+	if !m.Users.Exists(idle.ID) {
+		t.Fatal("user removed before the idle timeout")
+	}
+
+	fake.Advance(UserCleanupInterval)
+	eventually(t, "the idle user to be removed", func() bool {
+		return !m.Users.Exists(idle.ID) && !l.Users.Exists(idle.ID)
+	})
+
+	if !l.Users.Exists(active.ID) {
+		t.Error("active user was removed")
+	}
+}
+
+func TestLobbyExpiry(t *testing.T) {
+	m, fake := newTestManager(t, 1)
+	l, users := newTestLobby(m, LobbyModeLinear, "alice")
+
+	fake.Advance(LobbyIdleTimeout / 2)
+	l.AddVideo(context.Background(), testVideo("a", users[0]))
+
+	// Adding the video pushed the expiry back
+	fake.Advance(LobbyIdleTimeout / 2)
+	time.Sleep(10 * time.Millisecond)
+	if _, ok := m.GetLobby(l.ID); !ok {
+		t.Fatal("lobby expired despite activity")
+	}
+
+	fake.Advance(LobbyIdleTimeout / 2)
+	eventually(t, "the lobby to expire", func() bool {
+		_, ok := m.GetLobby(l.ID)
+		return !ok
+	})
+}
+
+func TestLobbyAccess(t *testing.T) {
+	m, fake := newTestManager(t, 1)
+	l, _ := newTestLobby(m, LobbyModeLinear)
+	other, _ := newTestLobby(m, LobbyModeLinear)
+
+	if err := l.CheckAccess("", ""); err != nil {
+		t.Fatalf("open lobby: %v", err)
+	}
+
+	if err := l.SetAccess(AccessPassword, ""); err != ErrInvalidPassword {
+		t.Errorf("password mode without a password: %v", err)
+	}
+	if err := l.SetAccess(AccessPassword, "hunter22"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetAccess(AccessPassword, ""); err != nil {
+		t.Errorf("keeping the password: %v", err)
+	}
+	for password, want := range map[string]error{"": ErrPasswordRequired, "hunter2": ErrWrongPassword, "hunter22": nil} {
+		if err := l.CheckAccess(password, ""); err != want {
+			t.Errorf("password %q: got %v, want %v", password, err, want)
+		}
+	}
+
+	l.SetAccess(AccessInvite, "")
+	if err := l.CheckAccess("hunter22", ""); err != ErrInviteRequired {
+		t.Errorf("password on an invite only lobby: %v", err)
+	}
+
+	short, shortToken, _ := l.CreateInvite(time.Hour)
+	_, longToken, _ := l.CreateInvite(30 * 24 * time.Hour)
+	if err := l.CheckAccess("", shortToken); err != nil {
+		t.Errorf("invite: %v", err)
+	}
+	if err := other.CheckAccess("", shortToken); err != nil {
+		t.Errorf("invite for another lobby checked on an open one: %v", err)
+	}
+	other.SetAccess(AccessInvite, "")
+	if err := other.CheckAccess("", shortToken); err != ErrInvalidInvite {
+		t.Errorf("invite used on another lobby: %v", err)
+	}
+	if err := l.CheckAccess("", shortToken[:len(shortToken)-1]+"x"); err != ErrInvalidInvite {
+		t.Errorf("tampered invite: %v", err)
+	}
+
+	if invites := l.Invites(); len(invites) != 2 || invites[0] != short || invites[1].ExpiresAt.Sub(epoch) != MaxInviteLifetime {
+		t.Errorf("invites %v, want the hour long one first and the other capped", invites)
+	}
+
+	fake.Advance(time.Hour)
+	if err := l.CheckAccess("", shortToken); err != ErrInviteExpired {
+		t.Errorf("expired invite: %v", err)
+	}
+	if invites := l.Invites(); len(invites) != 1 {
+		t.Errorf("%d invites, want the expired one dropped", len(invites))
+	}
+
+	if l.RevokeInvites() != 1 || l.CheckAccess("", longToken) != ErrInvalidInvite {
+		t.Error("revoked invite still works")
+	}
+}
+
+func TestClaimSlug(t *testing.T) {
+	m, _ := newTestManager(t, 1)
+	l, _ := newTestLobby(m, LobbyModeLinear)
+	other, _ := newTestLobby(m, LobbyModeLinear)
+
+	if err := m.ClaimSlug(l, " Friday-Jams "); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := m.GetLobby("FRIDAY-jams"); !ok || got != l || l.CurrentSlug() != "friday-jams" {
+		t.Error("lobby not found by its slug")
+	}
+	if err := m.ClaimSlug(other, "friday-jams"); err != ErrSlugTaken {
+		t.Errorf("slug held by another lobby: %v", err)
+	}
+
+	m.Lobbies.Set("coffee", other)
+	if err := m.ClaimSlug(l, "coffee"); err != ErrSlugTaken {
+		t.Errorf("slug matching another lobby code: %v", err)
+	}
+	for slug, want := range map[string]error{"ab": ErrInvalidSlug, "two--dashes": ErrInvalidSlug, "admin": ErrReservedSlug} {
+		if err := m.ClaimSlug(l, slug); err != want {
+			t.Errorf("slug %q: got %v, want %v", slug, err, want)
+		}
+	}
+	if l.CurrentSlug() != "friday-jams" {
+		t.Error("a refused slug replaced the one held")
+	}
+
+	if err := m.ClaimSlug(l, "jams"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.GetLobby("friday-jams"); ok {
+		t.Error("old slug still resolves after changing it")
+	}
+	m.RemoveLobby(l)
+	if _, ok := m.GetLobby("jams"); ok {
+		t.Error("slug still resolves after the lobby was removed")
+	}
+}
+
+func TestReportPlaybackError(t *testing.T) {
+	m, _ := newTestManager(t, 1)
+	l, users := newTestLobby(m, LobbyModeLinear, "alice", "bob", "carol")
+	alice, bob, carol := users[0], users[1], users[2]
+
+	for _, id := range []string{"a", "b", "c"} {
+		l.AddVideo(context.Background(), testVideo(id, alice))
+	}
+
+	if recorded, _ := l.ReportPlaybackError(alice, "a", 5); recorded {
+		t.Error("a non-fatal error was recorded")
+	}
+	if recorded, _ := l.ReportPlaybackError(alice, "b", PlayerErrorNotFound); recorded {
+		t.Error("an error for another video was recorded")
+	}
+
+	// Alone, or twice over, one user is not a quorum while others are around
+	for range 2 {
+		if recorded, quorum := l.ReportPlaybackError(alice, "a", PlayerErrorNotFound); !recorded || quorum {
+			t.Fatalf("first reporter: recorded %v, quorum %v", recorded, quorum)
+		}
+	}
+	if _, quorum := l.ReportPlaybackError(bob, "a", PlayerErrorNotFound); !quorum {
+		t.Fatal("two reporters did not reach quorum")
+	}
+	if _, quorum := l.ReportPlaybackError(carol, "a", PlayerErrorNotFound); quorum {
+		t.Error("quorum was reported twice for one video")
+	}
+
+	// Unconfirmed reports skip the video without keeping it out
+	if !l.FailVideo("a", PlayerErrorNotFound, false) || currentVideoID(l) != "b" {
+		t.Fatal("video was not skipped")
+	}
+	if l.CheckVideoFailed("a") {
+		t.Error("unconfirmed video was marked failed")
+	}
+	if l.FailVideo("a", PlayerErrorNotFound, true) {
+		t.Error("failed a video that is no longer playing")
+	}
+
+	l.ReportPlaybackError(alice, "b", PlayerErrorEmbedDisabled)
+	if _, quorum := l.ReportPlaybackError(bob, "b", PlayerErrorEmbedDisabled); !quorum {
+		t.Fatal("quorum not reached on the next video")
+	}
+	l.FailVideo("b", PlayerErrorEmbedDisabled, true)
+	if !l.CheckVideoFailed("b") {
+		t.Error("confirmed video was not marked failed")
+	}
+
+	history := l.PlayHistory()
+	if len(history) != 3 || !history[0].Skipped || history[0].Failed || !history[1].Failed {
+		t.Errorf("history = %+v, want a skipped and b failed", history)
+	}
+
+	// On their own a single report does it
+	solo, soloUsers := newTestLobby(m, LobbyModeLinear, "dave")
+	solo.AddVideo(context.Background(), testVideo("d", soloUsers[0]))
+	if _, quorum := solo.ReportPlaybackError(soloUsers[0], "d", PlayerErrorNotFound); !quorum {
+		t.Error("the only user could not report a broken video")
+	}
+}
+
+// recordEvents gives the user an event stream that keeps what it is sent, the
+// returned func lists the data of every event of the given type so far.
+func recordEvents(u *User) func(event string) []string {
+	rec := httptest.NewRecorder()
+	client := &sse.Client{
+		ID:      u.ID,
+		Writer:  rec,
+		Flusher: http.NewResponseController(rec),
+		Context: context.Background(),
+		Log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	u.SSE = client
+
+	return func(event string) []string {
+		client.Lock()
+		defer client.Unlock()
+
+		var data []string
+		for msg := range strings.SplitSeq(rec.Body.String(), "\n\n") {
+			if rest, ok := strings.CutPrefix(msg, "event: "+event+"\ndata: "); ok {
+				data = append(data, rest)
+			}
+		}
+		return data
+	}
+}
+
+func TestReactions(t *testing.T) {
+	m, _ := newTestManager(t, 1)
+	l, users := newTestLobby(m, LobbyModeLinear, "alice", "bob")
+	alice, bob := users[0], users[1]
+	events := recordEvents(bob)
+
+	l.AddVideo(context.Background(), testVideo("a", alice))
+	l.AddVideo(context.Background(), testVideo("b", alice))
+
+	if l.AddReaction(alice, "a", "thumbs") {
+		t.Error("unknown reaction accepted")
+	}
+	if l.AddReaction(alice, "b", ReactionFire) {
+		t.Error("reaction to a video that isn't playing accepted")
+	}
+
+	l.AddReaction(alice, "a", ReactionFire)
+	l.AddReaction(bob, "a", ReactionFire)
+	l.AddReaction(bob, "a", ReactionLaugh)
+	if got := events(UpdateReactions); len(got) != 0 {
+		t.Fatalf("reactions broadcast before the flush: %q", got)
+	}
+
+	l.FlushReactions()
+	l.FlushReactions()
+	got := events(UpdateReactions)
+	if len(got) != 1 {
+		t.Fatalf("%d reaction events, want one per flush with reactions pending", len(got))
+	}
+	var update reactionsUpdate
+	if err := json.Unmarshal([]byte(got[0]), &update); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{ReactionFire: 2, ReactionLaugh: 1}
+	if update.Reactions.Video != "a" || !maps.Equal(update.Reactions.Burst, want) || !maps.Equal(update.Reactions.Totals, want) {
+		t.Errorf("update = %+v, want a burst and totals of %v", update.Reactions, want)
+	}
+
+	// The totals keep counting, the next burst only has what came since
+	l.AddReaction(alice, "a", ReactionFire)
+	l.FlushReactions()
+	update = reactionsUpdate{}
+	json.Unmarshal([]byte(events(UpdateReactions)[1]), &update)
+	if !maps.Equal(update.Reactions.Burst, map[string]int{ReactionFire: 1}) || update.Reactions.Totals[ReactionFire] != 3 {
+		t.Errorf("second update = %+v", update.Reactions)
+	}
+
+	// A burst still pending when the video changes belongs to the old video
+	l.AddReaction(bob, "a", ReactionLove)
+	l.AdvancePlaylist()
+	l.FlushReactions()
+	if got := events(UpdateReactions); len(got) != 2 {
+		t.Errorf("unflushed burst was sent after the video changed: %q", got[2:])
+	}
+
+	history := l.PlayHistory()
+	want = map[string]int{ReactionFire: 3, ReactionLaugh: 1, ReactionLove: 1}
+	if len(history) != 2 || !maps.Equal(history[0].Reactions, want) {
+		t.Fatalf("history = %+v, want a with reactions %v", history, want)
+	}
+	if len(history[1].Reactions) != 0 {
+		t.Errorf("b started with reactions %v", history[1].Reactions)
+	}
+}
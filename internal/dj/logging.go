@@ -10,9 +10,8 @@ func (u *User) Log() slog.Attr {
 		slog.String("ID", u.ID),
 		slog.String("Name", u.Name),
 		slog.String("IP", u.IP),
-		slog.String("SessionID", u.SessionID),
 		slog.String("LobbyID", u.LobbyID),
-		slog.Duration("LastActivity", time.Now().Sub(u.LastActivity).Round(time.Second)),
+		slog.Duration("LastActivity", time.Since(u.LastActivity()).Round(time.Second)),
 	)
 }
 
@@ -20,10 +19,14 @@ func (l *Lobby) Log() slog.Attr {
 	return slog.Group("lobby",
 		slog.String("ID", l.ID),
 		slog.String("CreatorIP", l.CreatorIP),
+		slog.String("CreatorID", l.CreatorID),
 		slog.Time("CreatedAt", l.CreatedAt),
 		slog.Int("UserCount", l.Users.Length()),
 		slog.Int("VideoCount", len(l.Videos)),
-		slog.Duration("ExpiresIn", l.ExpiresAt.Sub(time.Now()).Round(time.Second)),
+		slog.Duration("ReplayCooldown", l.ReplayCooldown.Window),
+		slog.String("CooldownScope", l.ReplayCooldown.Scope),
+		slog.Float64("PlayerErrorShare", l.PlayerErrorShare),
+		slog.Duration("ExpiresIn", l.ExpiresAt.Sub(l.clock.Now()).Round(time.Second)),
 	)
 }
 
@@ -31,8 +34,22 @@ func (v *Video) Log() slog.Attr {
 	return slog.Group("video",
 		slog.String("ID", v.ID),
 		slog.String("Title", v.Title),
+		slog.String("Channel", v.Channel),
 		slog.Duration("Duration", v.Duration),
 		slog.String("SubmitterID", v.SubmitterID),
 		slog.String("SubmitterName", v.SubmitterName),
 	)
 }
+
+func (p ContentPolicy) Log() slog.Attr {
+	return slog.Group("policy",
+		slog.Any("AllowChannels", p.AllowChannels),
+		slog.Any("DenyChannels", p.DenyChannels),
+		slog.Any("BlockedKeywords", p.BlockedKeywords),
+		slog.Bool("RejectRegionBlocked", p.RejectRegionBlocked),
+		slog.Bool("RejectEmbedDisabled", p.RejectEmbedDisabled),
+		slog.Bool("RejectLive", p.RejectLive),
+		slog.Duration("MinDuration", p.MinDuration),
+		slog.Duration("MaxDuration", p.MaxDuration),
+	)
+}
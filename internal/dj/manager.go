@@ -5,48 +5,108 @@ import (
 	"log/slog"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btnmasher/safemap"
+
+	"github.com/btnmasher/testdj/internal/clock"
+	"github.com/btnmasher/testdj/internal/shared"
 )
 
-const MaxLobbies = 100
+// Defaults for the manager Settings.
+const (
+	MaxLobbies          = 100
+	UserCleanupInterval = 10 * time.Second
+	UserIdleTimeout     = 45 * time.Second
+)
 
 type LobbyManager struct {
 	sync.Mutex
-	Lobbies          safemap.SafeMap[string, *Lobby]
-	UsersByIP        safemap.SafeMap[string, *User]
-	UsersBySessionID safemap.SafeMap[string, *User]
-	MaxLobbies       int
+	Lobbies            safemap.SafeMap[string, *Lobby]
+	Users              safemap.SafeMap[string, *User]
+	UsersBySessionHash safemap.SafeMap[string, *User]
+
+	settings   Settings
+	settingsMu sync.RWMutex
+
+	sessionKey []byte
+	sessionMu  sync.Mutex
+
+	directoryWatchers map[chan struct{}]struct{}
+	directoryMu       sync.Mutex
 
-	userCleanupTicker *time.Ticker
+	slugs  map[string]*Lobby
+	slugMu sync.Mutex
+
+	userCleanupTicker clock.Ticker
+	running           atomic.Bool
+	clock             clock.Clock
+	rand              Rand
 	ctx               context.Context
 	log               *slog.Logger
 }
 
-func NewLobbyManager(ctx context.Context, log *slog.Logger) *LobbyManager {
+// ManagerOptions configures a LobbyManager. The zero value uses the default
+// settings, the wall clock and crypto/rand, tests swap out the latter two.
+type ManagerOptions struct {
+	Settings Settings
+	Clock    clock.Clock
+	Rand     Rand
+
+	// SessionKey signs the session cookies, a random key is used when it
+	// is empty.
+	SessionKey []byte
+}
+
+func NewLobbyManager(ctx context.Context, log *slog.Logger, opts ManagerOptions) *LobbyManager {
+	if opts.Clock == nil {
+		opts.Clock = clock.New()
+	}
+	if opts.Rand == nil {
+		opts.Rand = secureRand{}
+	}
+	if len(opts.SessionKey) == 0 {
+		opts.SessionKey = newSessionKey()
+	}
+	settings := opts.Settings.withDefaults()
+
 	m := &LobbyManager{
-		Lobbies:           safemap.NewMutexMap[string, *Lobby](),
-		UsersByIP:         safemap.NewMutexMap[string, *User](),
-		UsersBySessionID:  safemap.NewMutexMap[string, *User](),
-		MaxLobbies:        MaxLobbies,
-		userCleanupTicker: time.NewTicker(10 * time.Second),
-		ctx:               ctx,
-		log:               log.With("service", "LobbyManager"),
+		Lobbies:            safemap.NewMutexMap[string, *Lobby](),
+		Users:              safemap.NewMutexMap[string, *User](),
+		UsersBySessionHash: safemap.NewMutexMap[string, *User](),
+		settings:           settings,
+		sessionKey:         opts.SessionKey,
+		directoryWatchers:  make(map[chan struct{}]struct{}),
+		slugs:              make(map[string]*Lobby),
+		userCleanupTicker:  opts.Clock.NewTicker(settings.UserCleanupInterval),
+		clock:              opts.Clock,
+		rand:               opts.Rand,
+		ctx:                ctx,
+		log:                log.With("service", "LobbyManager"),
 	}
 
+	m.running.Store(true)
 	go m.timerMinder()
 
 	return m
 }
 
+// Running reports whether the manager loop is still cleaning up users, it
+// stops when the manager context ends.
+func (m *LobbyManager) Running() bool {
+	return m.running.Load()
+}
+
 func (m *LobbyManager) timerMinder() {
+	defer m.running.Store(false)
+
 minderLoop:
 	for {
 		select {
 		case <-m.ctx.Done():
 			break minderLoop
-		case <-m.userCleanupTicker.C:
+		case <-m.userCleanupTicker.C():
 			go m.CleanupUsers()
 		}
 	}
@@ -54,9 +114,41 @@ minderLoop:
 	m.userCleanupTicker.Stop()
 }
 
+func (m *LobbyManager) newID(size int) string {
+	return shared.GenerateIDFrom(m.rand.Intn, size)
+}
+
+// Now returns the current time on the manager clock.
+func (m *LobbyManager) Now() time.Time {
+	return m.clock.Now()
+}
+
+// newLobbyID picks a lobby code that no open lobby uses.
+func (m *LobbyManager) newLobbyID() string {
+	for {
+		id := m.newID(LobbyIDLength)
+		if _, claimed := m.lobbyBySlug(id); !claimed && !m.Lobbies.Exists(id) {
+			return id
+		}
+		m.log.With("func", "newLobbyID").Warn("Lobby code collision, picking another", slog.String("LobbyID", id))
+	}
+}
+
+// GetLobby finds a lobby by its code or, failing that, its slug.
 func (m *LobbyManager) GetLobby(id string) (*Lobby, bool) {
-	l, ok := m.Lobbies.Get(id)
-	return l, ok
+	if l, ok := m.Lobbies.Get(id); ok {
+		return l, true
+	}
+	return m.lobbyBySlug(id)
+}
+
+// Counts returns the number of open lobbies and the users in them.
+func (m *LobbyManager) Counts() (lobbies, users int) {
+	for l := range m.Lobbies.Values() {
+		lobbies++
+		users += l.Users.Length()
+	}
+	return lobbies, users
 }
 
 func (m *LobbyManager) AddLobby(l *Lobby) {
@@ -74,65 +166,66 @@ func (m *LobbyManager) RemoveLobby(l *Lobby) {
 	l.Cancel()
 	users := l.Users.ValuesSlice()
 	m.Lobbies.Delete(l.ID)
+	m.releaseSlug(l)
+	if l.listed.Load() {
+		m.notifyDirectory()
+	}
 	for _, user := range users {
 		if user.SSE != nil {
 			user.SSE.Send("redirect", "/")
 			user.SSE.Cancel(LobbyExpired)
 		}
-		m.UsersByIP.Get(user.ID)
-		m.UsersBySessionID.Get(user.SessionID)
+		m.EndSession(user)
+		m.Users.Delete(user.ID)
 	}
 }
 
 func (m *LobbyManager) CleanupUsers() {
-	now := time.Now()
+	now := m.clock.Now()
+	timeout := m.Settings().UserIdleTimeout
 	m.Lock()
 	defer m.Unlock()
 	log := m.log.With("func", "CleanupUsers")
 
-	for user := range slices.Values(m.UsersByIP.ValuesSlice()) {
-		if now.Sub(user.LastActivity).Seconds() > 45 {
+	for user := range slices.Values(m.Users.ValuesSlice()) {
+		if now.Sub(user.LastActivity()) > timeout {
 			log.Debug("Found timed out user, removing from lobby", user.Log())
 			for lobby := range m.Lobbies.Values() {
-				for u := range slices.Values(lobby.Users.ValuesSlice()) {
-					if u.IP == user.IP || u.ID == user.ID {
-						lobby.RemoveUser(u)
-					}
+				if lobby.Users.Exists(user.ID) {
+					lobby.RemoveUser(user)
 				}
 			}
 
-			if lobby, exists := m.Lobbies.Get(user.LobbyID); exists {
-				lobby.RemoveUser(user)
-			}
-
-			m.UsersBySessionID.Delete(user.SessionID)
-			m.UsersByIP.Delete(user.IP)
+			m.EndSession(user)
+			m.Users.Delete(user.ID)
 		}
 	}
 }
 
-func (m *LobbyManager) CleanExistingSessions(sessionId, ip string) {
+// CleanExistingSessions ends the session behind sessionToken, and those of
+// the users the IP policy counts as the same client as ip joining lobby.
+func (m *LobbyManager) CleanExistingSessions(sessionToken, ip string, lobby *Lobby) {
 	log := m.log.With("func", "CleanExistingSessions")
 
-	if sessionId != "" {
-		if u, ok := m.UsersBySessionID.Get(sessionId); ok {
+	if sessionToken != "" {
+		if u, ok := m.UserBySession(sessionToken); ok {
 			log.Debug("Found user for session ID, deleting", u.Log())
-			if lobby, exists := m.Lobbies.Get(u.LobbyID); exists {
-				lobby.RemoveUser(u)
-			}
-			m.UsersBySessionID.Delete(u.SessionID)
-			m.UsersByIP.Delete(u.IP)
+			m.dropUser(u)
 		}
 	}
 
 	if ip != "" {
-		if u, ok := m.UsersByIP.Get(ip); ok {
+		for _, u := range m.SharingAddress(ip, lobby) {
 			log.Debug("Found user for IP, deleting", u.Log())
-			if lobby, exists := m.Lobbies.Get(u.LobbyID); exists {
-				lobby.RemoveUser(u)
-			}
-			m.UsersBySessionID.Delete(u.SessionID)
-			m.UsersByIP.Delete(u.IP)
+			m.dropUser(u)
 		}
 	}
 }
+
+func (m *LobbyManager) dropUser(u *User) {
+	if lobby, exists := m.Lobbies.Get(u.LobbyID); exists {
+		lobby.RemoveUser(u)
+	}
+	m.EndSession(u)
+	m.Users.Delete(u.ID)
+}
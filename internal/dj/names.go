@@ -0,0 +1,140 @@
+package dj
+
+import (
+	"errors"
+	"html"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// UpdateTitle carries the escaped lobby title, swapped straight into the page.
+const UpdateTitle = "title_update"
+
+const MaxTitleLength = 60
+
+// Slug limits, a slug is a second code for the lobby chosen by its owner.
+const (
+	MinSlugLength = 3
+	MaxSlugLength = 32
+)
+
+// ReservedSlugs can't be claimed, they name pages or could be mistaken for them.
+var ReservedSlugs = []string{
+	"admin", "api", "create", "healthz", "invite", "join", "lobbies", "lobby",
+	"login", "logout", "metrics", "new", "readyz", "sse", "static", "testdj",
+}
+
+var (
+	ErrInvalidTitle = errors.New("invalid title")
+	ErrInvalidSlug  = errors.New("invalid slug")
+	ErrReservedSlug = errors.New("reserved slug")
+	ErrSlugTaken    = errors.New("slug taken")
+)
+
+var slugRegex = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// ValidTitle reports whether title can be set on a lobby.
+func ValidTitle(title string) bool {
+	return len(title) <= MaxTitleLength && strings.IndexFunc(title, unicode.IsControl) < 0
+}
+
+// ValidateSlug checks a lowercased slug against the format and reserved list.
+func ValidateSlug(slug string) error {
+	if len(slug) < MinSlugLength || len(slug) > MaxSlugLength || !slugRegex.MatchString(slug) {
+		return ErrInvalidSlug
+	}
+	if slices.Contains(ReservedSlugs, slug) {
+		return ErrReservedSlug
+	}
+	return nil
+}
+
+// DisplayTitle returns the title the owner gave the lobby, empty if none.
+func (l *Lobby) DisplayTitle() string {
+	l.Lock()
+	defer l.Unlock()
+	return l.Title
+}
+
+// SetTitle renames the lobby, the caller checks it with ValidTitle.
+func (l *Lobby) SetTitle(title string) {
+	l.Lock()
+	l.Title = title
+	l.Unlock()
+
+	l.log.With("func", "SetTitle").Debug("Lobby renamed", slog.String("title", title))
+
+	l.Broadcast(UpdateTitle, html.EscapeString(title))
+}
+
+// CurrentSlug returns the slug the lobby holds, empty if none.
+func (l *Lobby) CurrentSlug() string {
+	l.Lock()
+	defer l.Unlock()
+	return l.Slug
+}
+
+// ClaimSlug makes slug a second code for the lobby, releasing any slug it
+// held before. An empty slug just releases it. Slugs are kept lowercase and
+// can't match the code of another lobby.
+func (m *LobbyManager) ClaimSlug(l *Lobby, slug string) error {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if slug != "" {
+		if err := ValidateSlug(slug); err != nil {
+			return err
+		}
+	}
+
+	m.slugMu.Lock()
+	defer m.slugMu.Unlock()
+
+	if slug != "" {
+		if holder, ok := m.slugs[slug]; ok && holder != l {
+			return ErrSlugTaken
+		}
+		if other, ok := m.Lobbies.Get(slug); ok && other != l {
+			return ErrSlugTaken
+		}
+	}
+
+	l.Lock()
+	old := l.Slug
+	l.Slug = slug
+	l.Unlock()
+
+	if old != "" {
+		delete(m.slugs, old)
+	}
+	if slug != "" {
+		m.slugs[slug] = l
+	}
+
+	l.log.With("func", "ClaimSlug").Info("Lobby slug changed",
+		slog.String("old", old),
+		slog.String("slug", slug),
+	)
+	return nil
+}
+
+// releaseSlug frees the slug of a lobby that is going away.
+func (m *LobbyManager) releaseSlug(l *Lobby) {
+	m.slugMu.Lock()
+	defer m.slugMu.Unlock()
+
+	for slug, holder := range m.slugs {
+		if holder == l {
+			delete(m.slugs, slug)
+		}
+	}
+}
+
+func (m *LobbyManager) lobbyBySlug(slug string) (*Lobby, bool) {
+	m.slugMu.Lock()
+	defer m.slugMu.Unlock()
+
+	l, ok := m.slugs[strings.ToLower(slug)]
+	return l, ok
+}
@@ -0,0 +1,128 @@
+package dj
+
+import (
+	"log/slog"
+	"math"
+	"slices"
+)
+
+const UpdateToast = "toast"
+
+// Error codes raised by the YouTube IFrame player onError event that mean
+// the video can't be played by anyone. Code 5 (HTML5 player error) is left
+// out since it is usually specific to one browser.
+const (
+	PlayerErrorInvalidParam   = 2
+	PlayerErrorNotFound       = 100
+	PlayerErrorEmbedForbidden = 101
+	PlayerErrorEmbedDisabled  = 150
+)
+
+// DefaultPlayerErrorShare is the share of connected users that must report
+// the same player error before the current video is failed and skipped.
+const DefaultPlayerErrorShare = 0.5
+
+func IsFatalPlayerError(code int) bool {
+	switch code {
+	case PlayerErrorInvalidParam, PlayerErrorNotFound, PlayerErrorEmbedForbidden, PlayerErrorEmbedDisabled:
+		return true
+	}
+	return false
+}
+
+// ActiveUserCount returns the number of users with a live event stream, the
+// caller must hold the lobby lock.
+func (l *Lobby) ActiveUserCount() int {
+	count := 0
+	for u := range l.Users.Values() {
+		if u.SSE != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// playerErrorQuorum is how many reports of one error fail the current video.
+// Reports can't be checked, so with others around one client is never enough.
+func (l *Lobby) playerErrorQuorum() int {
+	quorum := int(math.Ceil(l.PlayerErrorShare * float64(l.ActiveUserCount())))
+	if l.Users.Length() > 1 {
+		return max(2, quorum)
+	}
+	return max(1, quorum)
+}
+
+// ReportPlaybackError is called when a client's player fails to play the
+// current video. It returns false if the report was ignored, and reaches
+// quorum once enough users report the same fatal error. Quorum is reported
+// once per video, the caller then checks the video and calls FailVideo.
+func (l *Lobby) ReportPlaybackError(user *User, videoId string, code int) (recorded, quorum bool) {
+	log := l.log.With("func", "ReportPlaybackError", slog.String("VideoID", videoId), slog.Int("Code", code))
+
+	if !IsFatalPlayerError(code) {
+		log.Debug("Ignoring non-fatal player error", user.Log())
+		return false, false
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if l.CurrentVideo == nil || l.CurrentVideo.ID != videoId {
+		log.Debug("Player error does not match current video")
+		return false, false
+	}
+
+	reporters, ok := l.playerErrors[code]
+	if !ok {
+		reporters = make(map[string]bool)
+		l.playerErrors[code] = reporters
+	}
+	reporters[user.ID] = true
+
+	needed := l.playerErrorQuorum()
+	log.Debug("Recorded player error", user.Log(), slog.Int("Reports", len(reporters)), slog.Int("Quorum", needed))
+
+	if len(reporters) < needed || l.playerErrorQuorate {
+		return true, false
+	}
+
+	l.playerErrorQuorate = true
+	return true, true
+}
+
+// FailVideo skips videoId after its player errors reached quorum, if it is
+// still playing. Only when confirmed, by the server finding the video can't
+// be played either, is it marked failed and kept from being queued again.
+func (l *Lobby) FailVideo(videoId string, code int, confirmed bool) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.CurrentVideo == nil || l.CurrentVideo.ID != videoId {
+		return false
+	}
+
+	l.log.With("func", "FailVideo").Info("Skipping video after player errors",
+		l.CurrentVideo.Log(),
+		slog.Int("Code", code),
+		slog.Bool("Confirmed", confirmed),
+	)
+
+	l.CurrentVideo.Failed = confirmed
+	l.CurrentVideo.WasSkipped = !confirmed
+	l.CurrentVideo.PlayerError = code
+	l.PickNextVideo()
+
+	l.Broadcast(UpdateToast, formatToast("Video couldn't be played, skipping", ToastError))
+	return true
+}
+
+// CheckVideoFailed reports whether the video failed to play earlier in the
+// lobby, so it isn't queued again.
+func (l *Lobby) CheckVideoFailed(videoId string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	return slices.ContainsFunc(l.PlayLog, func(p *PlayRecord) bool {
+		return p.Failed && p.VideoID == videoId
+	})
+}
@@ -0,0 +1,160 @@
+package dj
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const UpdatePolicy = "policy_update"
+
+const (
+	RuleChannelAllow  = "channel_allow"
+	RuleChannelDeny   = "channel_deny"
+	RuleKeyword       = "keyword"
+	RuleRegionBlocked = "region_blocked"
+	RuleEmbedDisabled = "embed_disabled"
+	RuleLive          = "live"
+	RuleMinDuration   = "min_duration"
+	RuleMaxDuration   = "max_duration"
+)
+
+const DefaultMaxVideoDuration = 10 * time.Minute
+
+// MaxPolicyDurationMinutes bounds the duration rules an owner can set.
+const MaxPolicyDurationMinutes = 6 * 60
+
+// ContentPolicy is the set of rules a lobby applies to submitted videos.
+// Channel rules match either the channel name or the channel ID, case
+// insensitively. An empty AllowChannels list allows every channel.
+type ContentPolicy struct {
+	AllowChannels       []string      `json:"allowChannels"`
+	DenyChannels        []string      `json:"denyChannels"`
+	BlockedKeywords     []string      `json:"blockedKeywords"`
+	RejectRegionBlocked bool          `json:"rejectRegionBlocked"`
+	RejectEmbedDisabled bool          `json:"rejectEmbedDisabled"`
+	RejectLive          bool          `json:"rejectLive"`
+	MinDuration         time.Duration `json:"minDuration"`
+	MaxDuration         time.Duration `json:"maxDuration"`
+}
+
+func DefaultContentPolicy() ContentPolicy {
+	return ContentPolicy{
+		RejectRegionBlocked: true,
+		RejectEmbedDisabled: true,
+		RejectLive:          true,
+		MaxDuration:         DefaultMaxVideoDuration,
+	}
+}
+
+// PolicyCandidate is what a policy is evaluated against: the metadata of a
+// video that is about to be queued.
+type PolicyCandidate struct {
+	Title         string
+	Channel       string
+	ChannelID     string
+	Duration      time.Duration
+	Live          bool
+	EmbedDisabled bool
+	RegionBlocked bool
+}
+
+// PolicyViolation is returned when a candidate breaks a rule, Reason is
+// suitable to show to the submitter.
+type PolicyViolation struct {
+	Rule   string
+	Reason string
+}
+
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("content policy %s: %s", v.Rule, v.Reason)
+}
+
+func violation(rule, format string, args ...any) *PolicyViolation {
+	return &PolicyViolation{Rule: rule, Reason: fmt.Sprintf(format, args...)}
+}
+
+func matchesChannel(list []string, c PolicyCandidate) bool {
+	return slices.ContainsFunc(list, func(entry string) bool {
+		return strings.EqualFold(entry, c.Channel) || (c.ChannelID != "" && strings.EqualFold(entry, c.ChannelID))
+	})
+}
+
+// Evaluate checks the candidate against every rule and returns the first
+// *PolicyViolation found, or nil if the video is allowed.
+func (p *ContentPolicy) Evaluate(c PolicyCandidate) *PolicyViolation {
+	if c.Live && p.RejectLive {
+		return violation(RuleLive, "Live streams are not allowed")
+	}
+
+	if c.EmbedDisabled && p.RejectEmbedDisabled {
+		return violation(RuleEmbedDisabled, "This video can't be played outside of YouTube")
+	}
+
+	if c.RegionBlocked && p.RejectRegionBlocked {
+		return violation(RuleRegionBlocked, "This video is blocked in the server's region")
+	}
+
+	if matchesChannel(p.DenyChannels, c) {
+		return violation(RuleChannelDeny, "Videos from %s are not allowed in this lobby", c.Channel)
+	}
+
+	if len(p.AllowChannels) > 0 && !matchesChannel(p.AllowChannels, c) {
+		return violation(RuleChannelAllow, "Only videos from approved channels are allowed in this lobby")
+	}
+
+	title := strings.ToLower(c.Title)
+	for _, keyword := range p.BlockedKeywords {
+		if keyword != "" && strings.Contains(title, strings.ToLower(keyword)) {
+			return violation(RuleKeyword, "The title contains a blocked word")
+		}
+	}
+
+	if p.MinDuration > 0 && c.Duration < p.MinDuration {
+		return violation(RuleMinDuration, "Videos shorter than %v are not allowed", p.MinDuration)
+	}
+
+	if p.MaxDuration > 0 && c.Duration > p.MaxDuration {
+		return violation(RuleMaxDuration, "Videos longer than %v are not allowed", p.MaxDuration)
+	}
+
+	return nil
+}
+
+// ContentPolicy returns a copy of the lobby content policy.
+func (l *Lobby) ContentPolicy() ContentPolicy {
+	l.Lock()
+	defer l.Unlock()
+
+	p := l.Policy
+	p.AllowChannels = slices.Clone(p.AllowChannels)
+	p.DenyChannels = slices.Clone(p.DenyChannels)
+	p.BlockedKeywords = slices.Clone(p.BlockedKeywords)
+	return p
+}
+
+// SetContentPolicy replaces the lobby content policy. Videos already queued
+// are not re-evaluated.
+func (l *Lobby) SetContentPolicy(p ContentPolicy) {
+	l.Lock()
+	l.Policy = p
+	l.Unlock()
+
+	l.log.With("func", "SetContentPolicy").
+		Debug("Content policy updated", p.Log())
+
+	l.Broadcast(UpdatePolicy, "")
+}
+
+// IsOwner reports whether the user created the lobby. Ownership follows the
+// creator's address unless addresses are shared, then only their session.
+func (l *Lobby) IsOwner(user *User) bool {
+	if user == nil {
+		return false
+	}
+	if l.Manager.addressIsIdentity() && user.IP == l.CreatorIP {
+		return true
+	}
+	return l.CreatorID != "" && user.ID == l.CreatorID
+}
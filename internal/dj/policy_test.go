@@ -0,0 +1,82 @@
+package dj
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContentPolicyEvaluate(t *testing.T) {
+	video := PolicyCandidate{
+		Title:     "Lofi Beats To Study To",
+		Channel:   "Chill Channel",
+		ChannelID: "UCchill",
+		Duration:  5 * time.Minute,
+	}
+
+	tests := []struct {
+		name   string
+		policy ContentPolicy
+		video  func(c *PolicyCandidate)
+		rule   string
+	}{
+		{"empty policy allows", ContentPolicy{}, nil, ""},
+		{"default allows", DefaultContentPolicy(), nil, ""},
+		{"deny by name", ContentPolicy{DenyChannels: []string{"chill channel"}}, nil, RuleChannelDeny},
+		{"deny by id", ContentPolicy{DenyChannels: []string{"ucCHILL"}}, nil, RuleChannelDeny},
+		{"deny other channel", ContentPolicy{DenyChannels: []string{"Loud Channel"}}, nil, ""},
+		{"allow by name", ContentPolicy{AllowChannels: []string{"CHILL CHANNEL"}}, nil, ""},
+		{"allow by id", ContentPolicy{AllowChannels: []string{"UCchill"}}, nil, ""},
+		{"not allowed", ContentPolicy{AllowChannels: []string{"Loud Channel"}}, nil, RuleChannelAllow},
+		{"empty id never matches", ContentPolicy{AllowChannels: []string{""}}, func(c *PolicyCandidate) { c.ChannelID = "" }, RuleChannelAllow},
+		{"deny wins over allow", ContentPolicy{AllowChannels: []string{"UCchill"}, DenyChannels: []string{"Chill Channel"}}, nil, RuleChannelDeny},
+		{"keyword", ContentPolicy{BlockedKeywords: []string{"STUDY"}}, nil, RuleKeyword},
+		{"keyword not in title", ContentPolicy{BlockedKeywords: []string{"metal"}}, nil, ""},
+		{"blank keyword ignored", ContentPolicy{BlockedKeywords: []string{""}}, nil, ""},
+		{"too short", ContentPolicy{MinDuration: 10 * time.Minute}, nil, RuleMinDuration},
+		{"min duration inclusive", ContentPolicy{MinDuration: 5 * time.Minute}, nil, ""},
+		{"too long", ContentPolicy{MaxDuration: 4 * time.Minute}, nil, RuleMaxDuration},
+		{"max duration inclusive", ContentPolicy{MaxDuration: 5 * time.Minute}, nil, ""},
+		{"no max duration", ContentPolicy{}, func(c *PolicyCandidate) { c.Duration = 5 * time.Hour }, ""},
+		{"live", DefaultContentPolicy(), func(c *PolicyCandidate) { c.Live = true }, RuleLive},
+		{"live allowed", ContentPolicy{}, func(c *PolicyCandidate) { c.Live = true }, ""},
+		{"embed disabled", DefaultContentPolicy(), func(c *PolicyCandidate) { c.EmbedDisabled = true }, RuleEmbedDisabled},
+		{"region blocked", DefaultContentPolicy(), func(c *PolicyCandidate) { c.RegionBlocked = true }, RuleRegionBlocked},
+		{
+			"playability before channels",
+			ContentPolicy{RejectEmbedDisabled: true, DenyChannels: []string{"UCchill"}},
+			func(c *PolicyCandidate) { c.EmbedDisabled = true },
+			RuleEmbedDisabled,
+		},
+		{
+			"channels before keywords",
+			ContentPolicy{AllowChannels: []string{"Loud Channel"}, BlockedKeywords: []string{"lofi"}},
+			nil,
+			RuleChannelAllow,
+		},
+		{
+			"keywords before duration",
+			ContentPolicy{BlockedKeywords: []string{"lofi"}, MaxDuration: time.Minute},
+			nil,
+			RuleKeyword,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := video
+			if tt.video != nil {
+				tt.video(&c)
+			}
+
+			v := tt.policy.Evaluate(c)
+			switch {
+			case tt.rule == "" && v != nil:
+				t.Errorf("Evaluate = %v, want allowed", v)
+			case tt.rule != "" && v == nil:
+				t.Errorf("Evaluate allowed the video, want %s", tt.rule)
+			case tt.rule != "" && v.Rule != tt.rule:
+				t.Errorf("Evaluate = %v, want %s", v, tt.rule)
+			}
+		})
+	}
+}
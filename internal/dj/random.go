@@ -0,0 +1,38 @@
+package dj
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/btnmasher/testdj/internal/shared"
+)
+
+// Rand is the source of randomness for shuffle order, IDs and dino looks.
+type Rand interface {
+	Intn(n int) int
+}
+
+// NewRand returns a Rand that repeats the same sequence for a seed, safe for
+// concurrent use.
+func NewRand(seed int64) Rand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+type lockedRand struct {
+	sync.Mutex
+	r *rand.Rand
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.Lock()
+	defer l.Unlock()
+	return l.r.Intn(n)
+}
+
+// secureRand draws from crypto/rand, so lobby codes and user IDs cannot be
+// predicted from earlier ones.
+type secureRand struct{}
+
+func (secureRand) Intn(n int) int {
+	return shared.SecureIntn(n)
+}
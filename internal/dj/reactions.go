@@ -0,0 +1,108 @@
+package dj
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"time"
+)
+
+const UpdateReactions = "reactions_update"
+
+// ReactionFlushInterval is how often pending reactions are batched into a
+// single SSE event, so a burst of clicks doesn't flood every client.
+const ReactionFlushInterval = 2 * time.Second
+
+const (
+	ReactionFire  = "fire"
+	ReactionLaugh = "laugh"
+	ReactionLove  = "love"
+	ReactionParty = "party"
+	ReactionMeh   = "meh"
+	ReactionSleep = "sleep"
+)
+
+// Reactions is the fixed, display-ordered set of reactions users can send.
+var Reactions = []string{
+	ReactionFire,
+	ReactionLaugh,
+	ReactionLove,
+	ReactionParty,
+	ReactionMeh,
+	ReactionSleep,
+}
+
+var ReactionEmoji = map[string]string{
+	ReactionFire:  "🔥",
+	ReactionLaugh: "😂",
+	ReactionLove:  "❤️",
+	ReactionParty: "🎉",
+	ReactionMeh:   "😐",
+	ReactionSleep: "😴",
+}
+
+type reactionsUpdate struct {
+	Reactions struct {
+		Video  string         `json:"video"`
+		Burst  map[string]int `json:"burst"`
+		Totals map[string]int `json:"totals"`
+	} `json:"reactions"`
+}
+
+func formatReactionsUpdate(videoId string, burst, totals map[string]int) string {
+	var payload reactionsUpdate
+	payload.Reactions.Video = videoId
+	payload.Reactions.Burst = burst
+	payload.Reactions.Totals = totals
+	data, _ := json.Marshal(payload)
+	return string(data)
+}
+
+// AddReaction records a reaction against the currently playing video. The
+// reaction is added to the video totals immediately, but only broadcast on
+// the next FlushReactions.
+func (l *Lobby) AddReaction(user *User, videoId, reaction string) bool {
+	log := l.log.With("func", "AddReaction", slog.String("Reaction", reaction))
+
+	if _, ok := ReactionEmoji[reaction]; !ok {
+		log.Debug("Unknown reaction")
+		return false
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if l.CurrentVideo == nil || l.CurrentVideo.ID != videoId {
+		log.Debug("Reaction does not match current video")
+		return false
+	}
+
+	if l.CurrentVideo.Reactions == nil {
+		l.CurrentVideo.Reactions = make(map[string]int)
+	}
+	l.CurrentVideo.Reactions[reaction]++
+	l.pendingReactions[reaction]++
+
+	log.Debug("Recorded reaction", user.Log())
+	return true
+}
+
+// FlushReactions broadcasts any reactions received since the last flush.
+func (l *Lobby) FlushReactions() {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.pendingReactions) == 0 || l.CurrentVideo == nil {
+		return
+	}
+
+	burst := l.pendingReactions
+	l.pendingReactions = make(map[string]int)
+
+	l.Broadcast(UpdateReactions, formatReactionsUpdate(l.CurrentVideo.ID, burst, maps.Clone(l.CurrentVideo.Reactions)))
+}
+
+// ReactionCount returns the total for a single reaction, safe for a nil map.
+func (v *Video) ReactionCount(reaction string) int {
+	return v.Reactions[reaction]
+}
@@ -0,0 +1,127 @@
+package dj
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/shared"
+)
+
+// Defaults for the session Settings.
+const (
+	SessionLifetime       = 8 * time.Hour
+	SessionRotateInterval = 15 * time.Minute
+)
+
+// SessionRotateGrace is how long the token replaced by a rotation keeps
+// working, so requests already in flight with it are not turned away.
+const SessionRotateGrace = time.Minute
+
+// SessionKeyLength is the size of the key generated when none is configured.
+const SessionKeyLength = 32
+
+// session is the server side state of a user's session token. The token is
+// only handed to the client, inside a signed cookie value.
+type session struct {
+	hash      string
+	issuedAt  time.Time
+	expiresAt time.Time
+
+	// previousHash is the token replaced by the last rotation, accepted
+	// until previousUntil.
+	previousHash  string
+	previousUntil time.Time
+}
+
+func newSessionKey() []byte {
+	key := make([]byte, SessionKeyLength)
+	rand.Read(key) // never returns an error, it crashes the program instead
+	return key
+}
+
+// issueSession gives the user a new token and returns the cookie value for
+// it, the caller holds sessionMu.
+func (m *LobbyManager) issueSession(user *User) string {
+	token := shared.NewSessionToken()
+	user.session.hash = shared.HashSessionToken(token)
+	user.session.issuedAt = m.clock.Now()
+	m.UsersBySessionHash.Set(user.session.hash, user)
+
+	return shared.SignSessionToken(m.sessionKey, token)
+}
+
+// UserBySession returns the user holding the session cookie value. Cookies
+// with a bad signature, tokens from an older scheme and sessions past their
+// lifetime are refused.
+func (m *LobbyManager) UserBySession(value string) (*User, bool) {
+	token, ok := shared.VerifySessionCookie(m.sessionKey, value)
+	if !ok || !shared.ValidSessionToken(token) {
+		return nil, false
+	}
+	hash := shared.HashSessionToken(token)
+
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+
+	user, ok := m.UsersBySessionHash.Get(hash)
+	if !ok {
+		return nil, false
+	}
+
+	now := m.clock.Now()
+	if !now.Before(user.session.expiresAt) {
+		m.log.With("func", "UserBySession").Debug("Session expired", user.Log())
+		return nil, false
+	}
+
+	if hash != user.session.hash {
+		if hash != user.session.previousHash || !now.Before(user.session.previousUntil) {
+			m.UsersBySessionHash.Delete(hash)
+			return nil, false
+		}
+	}
+
+	return user, true
+}
+
+// RotateSession replaces the user's token once it is older than the rotate
+// interval, returning the new cookie value. The replaced token keeps working
+// for SessionRotateGrace.
+func (m *LobbyManager) RotateSession(user *User) (string, bool) {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+
+	now := m.clock.Now()
+	if now.Sub(user.session.issuedAt) < m.Settings().SessionRotateInterval {
+		return "", false
+	}
+
+	if user.session.previousHash != "" {
+		m.UsersBySessionHash.Delete(user.session.previousHash)
+	}
+	user.session.previousHash = user.session.hash
+	user.session.previousUntil = now.Add(SessionRotateGrace)
+
+	m.log.With("func", "RotateSession").Debug("Rotating session token", user.Log())
+	return m.issueSession(user), true
+}
+
+// EndSession invalidates every token of the user.
+func (m *LobbyManager) EndSession(user *User) {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+
+	m.UsersBySessionHash.Delete(user.session.hash)
+	if user.session.previousHash != "" {
+		m.UsersBySessionHash.Delete(user.session.previousHash)
+	}
+	user.session.previousHash = ""
+}
+
+// SessionRemaining is how long the user's session has left, however long
+// the browser keeps the cookie.
+func (m *LobbyManager) SessionRemaining(user *User) time.Duration {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	return user.session.expiresAt.Sub(m.clock.Now())
+}
@@ -0,0 +1,115 @@
+package dj
+
+import (
+	"slices"
+	"time"
+)
+
+// Settings are the limits and durations a LobbyManager and its lobbies run
+// with. Zero fields take the package defaults.
+type Settings struct {
+	MaxLobbies          int
+	LobbyIdleTimeout    time.Duration
+	UserIdleTimeout     time.Duration
+	UserCleanupInterval time.Duration
+	VoteDuration        time.Duration
+	VoteMuteDuration    time.Duration
+	MuteVoteCooldown    time.Duration
+
+	// SessionLifetime is how long a session lasts from when the user joined,
+	// SessionRotateInterval how often its token is replaced.
+	SessionLifetime       time.Duration
+	SessionRotateInterval time.Duration
+
+	// IPPolicy is how many users may share an address, one of IPPolicies.
+	IPPolicy string
+
+	// ContentPolicy is the policy new lobbies start with, nil for
+	// DefaultContentPolicy.
+	ContentPolicy *ContentPolicy
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		MaxLobbies:          MaxLobbies,
+		LobbyIdleTimeout:    LobbyIdleTimeout,
+		UserIdleTimeout:     UserIdleTimeout,
+		UserCleanupInterval: UserCleanupInterval,
+		VoteDuration:        VoteDuration,
+		VoteMuteDuration:    VoteMuteDuration,
+		MuteVoteCooldown:    MuteVoteCooldown,
+
+		SessionLifetime:       SessionLifetime,
+		SessionRotateInterval: SessionRotateInterval,
+
+		IPPolicy: IPPolicyPerIP,
+	}
+}
+
+func (s Settings) withDefaults() Settings {
+	d := DefaultSettings()
+	if s.MaxLobbies <= 0 {
+		s.MaxLobbies = d.MaxLobbies
+	}
+	if s.LobbyIdleTimeout <= 0 {
+		s.LobbyIdleTimeout = d.LobbyIdleTimeout
+	}
+	if s.UserIdleTimeout <= 0 {
+		s.UserIdleTimeout = d.UserIdleTimeout
+	}
+	if s.UserCleanupInterval <= 0 {
+		s.UserCleanupInterval = d.UserCleanupInterval
+	}
+	if s.VoteDuration <= 0 {
+		s.VoteDuration = d.VoteDuration
+	}
+	if s.VoteMuteDuration <= 0 {
+		s.VoteMuteDuration = d.VoteMuteDuration
+	}
+	if s.MuteVoteCooldown <= 0 {
+		s.MuteVoteCooldown = d.MuteVoteCooldown
+	}
+	if s.SessionLifetime <= 0 {
+		s.SessionLifetime = d.SessionLifetime
+	}
+	if s.SessionRotateInterval <= 0 {
+		s.SessionRotateInterval = d.SessionRotateInterval
+	}
+	if !slices.Contains(IPPolicies, s.IPPolicy) {
+		s.IPPolicy = d.IPPolicy
+	}
+	return s
+}
+
+// lobbyPolicy returns a copy of the policy for a new lobby.
+func (s Settings) lobbyPolicy() ContentPolicy {
+	if s.ContentPolicy == nil {
+		return DefaultContentPolicy()
+	}
+
+	p := *s.ContentPolicy
+	p.AllowChannels = slices.Clone(p.AllowChannels)
+	p.DenyChannels = slices.Clone(p.DenyChannels)
+	p.BlockedKeywords = slices.Clone(p.BlockedKeywords)
+	return p
+}
+
+// Settings returns the settings the manager is running with.
+func (m *LobbyManager) Settings() Settings {
+	m.settingsMu.RLock()
+	defer m.settingsMu.RUnlock()
+	return m.settings
+}
+
+// SetSettings replaces the settings while the manager is running. Limits and
+// durations apply from their next use, the content policy only to lobbies
+// created afterwards.
+func (m *LobbyManager) SetSettings(s Settings) {
+	s = s.withDefaults()
+
+	m.settingsMu.Lock()
+	m.settings = s
+	m.settingsMu.Unlock()
+
+	m.userCleanupTicker.Reset(s.UserCleanupInterval)
+}
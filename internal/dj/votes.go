@@ -1,15 +1,21 @@
 package dj
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/btnmasher/safemap"
+
+	"github.com/btnmasher/testdj/internal/metrics"
 )
 
+// Defaults for the manager Settings.
 const (
+	VoteDuration     = 30 * time.Second
 	VoteMuteDuration = 30 * time.Minute
+	MuteVoteCooldown = 5 * time.Minute
 )
 
 type VoteSkipStatus struct {
@@ -43,10 +49,13 @@ func (l *Lobby) StartVoteSkip(user *User) bool {
 	l.VoteSkip.Active = true
 	l.VoteSkip.VideoID = l.CurrentVideo.ID
 	l.VoteSkip.YesVotes.Set(user.ID, true)
-	l.VoteSkip.EndsAt = time.Now().Add(30 * time.Second)
+	duration := l.Manager.Settings().VoteDuration
+	l.VoteSkip.EndsAt = l.clock.Now().Add(duration)
+
+	metrics.VoteStarts.WithLabelValues(metrics.VoteSkip).Inc()
 
 	l.Broadcast(UpdateVoteSkip, "")
-	l.voteSkipTimer.Reset(30 * time.Second)
+	l.voteSkipTimer.Reset(duration)
 	return true
 }
 
@@ -68,12 +77,13 @@ func (l *Lobby) StartVoteMute(user *User, targetID string) bool {
 		return false
 	}
 
-	now := time.Now()
+	now := l.clock.Now()
+	settings := l.Manager.Settings()
 
 	l.Lock()
-	if user.IP != l.CreatorIP {
+	if !l.IsOwner(user) {
 		log.Debug("Setting vote mute cooldown for user", user.Log())
-		l.MuteCooldownsByIP.Set(user.IP, now.Add(5*time.Minute))
+		l.MuteCooldowns.Set(l.MuteKey(user), now.Add(settings.MuteVoteCooldown))
 	}
 
 	l.VoteMute.Active = true
@@ -81,13 +91,14 @@ func (l *Lobby) StartVoteMute(user *User, targetID string) bool {
 	l.VoteMute.TargetName = targetUser.Name
 	l.VoteMute.Initiator = user.ID
 	l.VoteMute.YesVotes.Set(user.ID, true)
-	l.VoteMute.EndsAt = now.Add(30 * time.Second)
+	l.VoteMute.EndsAt = now.Add(settings.VoteDuration)
 	l.Unlock()
 
 	log.Debug("Starting vote mute timer")
+	metrics.VoteStarts.WithLabelValues(metrics.VoteMute).Inc()
 
 	l.Broadcast(UpdateVoteMute, "")
-	l.voteMuteTimer.Reset(30 * time.Second)
+	l.voteMuteTimer.Reset(settings.VoteDuration)
 
 	return true
 }
@@ -189,6 +200,7 @@ func (l *Lobby) CalcVoteSkipResult() bool {
 
 func (l *Lobby) EndVoteSkip(succeeded bool) {
 	l.voteSkipTimer.Stop()
+	metrics.VoteOutcomes.WithLabelValues(metrics.VoteSkip, voteOutcome(succeeded)).Inc()
 
 	if l.CurrentVideo != nil {
 		l.CurrentVideo.WasVoted = true
@@ -233,12 +245,13 @@ func (l *Lobby) CalcVoteMuteResult() bool {
 
 func (l *Lobby) EndVoteMute(succeeded bool) {
 	l.voteMuteTimer.Stop()
+	metrics.VoteOutcomes.WithLabelValues(metrics.VoteMute, voteOutcome(succeeded)).Inc()
 
 	if succeeded {
 		if u, ok := l.Users.Get(l.VoteMute.TargetID); ok {
-			exp := time.Now().Add(VoteMuteDuration)
+			exp := l.clock.Now().Add(l.Manager.Settings().VoteMuteDuration)
 			u.MutedUntil = exp
-			l.MutesByIP.Set(u.IP, exp)
+			l.Mutes.Set(l.MuteKey(u), exp)
 		}
 	}
 
@@ -259,6 +272,13 @@ func (l *Lobby) EndVoteMute(succeeded bool) {
 	}
 }
 
+func voteOutcome(succeeded bool) string {
+	if succeeded {
+		return metrics.VotePassed
+	}
+	return metrics.VoteFailed
+}
+
 const (
 	UpdateVoteSkip    = "vote_skip_update"
 	UpdateVoteSkipEnd = "vote_skip_end"
@@ -269,8 +289,12 @@ const (
 const (
 	ToastSuccess = "success"
 	ToastError   = "error"
+	ToastInfo    = "info"
 )
 
 func formatToast(message, kind string) string {
-	return fmt.Sprintf(`{"toast":{"message":"%s", "type":"%s"}}`, message, kind)
+	data, _ := json.Marshal(map[string]any{
+		"toast": map[string]string{"message": message, "type": kind},
+	})
+	return string(data)
 }
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FetchPathHealth sums up the metadata fetches made on one path since the
+// process started, for the admin console.
+type FetchPathHealth struct {
+	Path        string
+	Successes   int64
+	Failures    int64
+	LastLatency time.Duration
+	LastSuccess time.Time
+	LastFailure time.Time
+	LastError   string
+}
+
+var fetchHealth = struct {
+	sync.Mutex
+	paths map[string]*FetchPathHealth
+}{paths: map[string]*FetchPathHealth{}}
+
+func recordFetchHealth(path string, latency time.Duration, err error) {
+	fetchHealth.Lock()
+	defer fetchHealth.Unlock()
+
+	h, ok := fetchHealth.paths[path]
+	if !ok {
+		h = &FetchPathHealth{Path: path}
+		fetchHealth.paths[path] = h
+	}
+
+	h.LastLatency = latency
+	if err != nil {
+		h.Failures++
+		h.LastFailure = time.Now()
+		h.LastError = err.Error()
+	} else {
+		h.Successes++
+		h.LastSuccess = time.Now()
+	}
+}
+
+// FetchHealth returns the fetch paths that have been used, by name.
+func FetchHealth() []FetchPathHealth {
+	fetchHealth.Lock()
+	defer fetchHealth.Unlock()
+
+	out := make([]FetchPathHealth, 0, len(fetchHealth.paths))
+	for _, h := range fetchHealth.paths {
+		out = append(out, *h)
+	}
+	slices.SortFunc(out, func(a, b FetchPathHealth) int { return strings.Compare(a.Path, b.Path) })
+	return out
+}
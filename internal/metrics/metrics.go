@@ -0,0 +1,167 @@
+// Package metrics holds the Prometheus collectors for the service, served by
+// Handler on the optional metrics address.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "testdj"
+
+// Metadata fetch paths, the values of the "path" label.
+const (
+	FetchScrape  = "scrape"
+	FetchDataAPI = "data_api"
+	FetchBrowser = "browser"
+)
+
+// Vote types and outcomes, the values of the "type" and "outcome" labels.
+const (
+	VoteSkip = "skip"
+	VoteMute = "mute"
+
+	VotePassed    = "passed"
+	VoteFailed    = "failed"
+	VoteCancelled = "cancelled"
+)
+
+// Rate limit scopes, the values of the "scope" label.
+const (
+	ScopeIP   = "ip"
+	ScopeUser = "user"
+)
+
+// Registry holds every collector of the service, along with the Go runtime
+// and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	SSEConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sse_connections",
+		Help:      "Open lobby event streams.",
+	})
+
+	VideosQueued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "videos_queued_total",
+		Help:      "Videos added to a lobby playlist.",
+	})
+
+	VideosPlayed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "videos_played_total",
+		Help:      "Videos started in a lobby.",
+	})
+
+	VoteStarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vote_starts_total",
+		Help:      "Votes started, by type.",
+	}, []string{"type"})
+
+	VoteOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vote_outcomes_total",
+		Help:      "Votes ended, by type and outcome.",
+	}, []string{"type", "outcome"})
+
+	FetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "metadata_fetch_duration_seconds",
+		Help:      "Video metadata fetch latency, by path.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 25},
+	}, []string{"path"})
+
+	FetchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "metadata_fetch_errors_total",
+		Help:      "Video metadata fetches that failed, by path.",
+	}, []string{"path"})
+
+	AgeRestrictedRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "age_restricted_rejections_total",
+		Help:      "Videos refused for being age restricted.",
+	})
+
+	LobbyExpirations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lobby_expirations_total",
+		Help:      "Lobbies closed after going idle.",
+	})
+
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests refused by a rate limit, by route and scope.",
+	}, []string{"route", "scope"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		SSEConnections,
+		VideosQueued,
+		VideosPlayed,
+		VoteStarts,
+		VoteOutcomes,
+		FetchDuration,
+		FetchErrors,
+		AgeRestrictedRejections,
+		LobbyExpirations,
+		RateLimited,
+	)
+}
+
+// ObserveFetch records a metadata fetch on path that started at start.
+func ObserveFetch(path string, start time.Time, err error) {
+	latency := time.Since(start)
+	recordFetchHealth(path, latency, err)
+
+	FetchDuration.WithLabelValues(path).Observe(latency.Seconds())
+	if err != nil {
+		FetchErrors.WithLabelValues(path).Inc()
+	}
+}
+
+// Counts reports the open lobbies and the users in them.
+type Counts func() (lobbies, users int)
+
+type activeCollector struct {
+	counts  Counts
+	lobbies *prometheus.Desc
+	users   *prometheus.Desc
+}
+
+// RegisterActive adds gauges for the open lobbies and users, read from counts
+// on every scrape. It fails if they are registered already.
+func RegisterActive(counts Counts) error {
+	return Registry.Register(&activeCollector{
+		counts:  counts,
+		lobbies: prometheus.NewDesc(namespace+"_active_lobbies", "Open lobbies.", nil, nil),
+		users:   prometheus.NewDesc(namespace+"_active_users", "Users in open lobbies.", nil, nil),
+	})
+}
+
+func (c *activeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lobbies
+	ch <- c.users
+}
+
+func (c *activeCollector) Collect(ch chan<- prometheus.Metric) {
+	lobbies, users := c.counts()
+	ch <- prometheus.MustNewConstMetric(c.lobbies, prometheus.GaugeValue, float64(lobbies))
+	ch <- prometheus.MustNewConstMetric(c.users, prometheus.GaugeValue, float64(users))
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
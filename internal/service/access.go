@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lmittmann/tint"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/templates"
+)
+
+// accessProblems is what the join form says when access is refused.
+var accessProblems = map[error]string{
+	dj.ErrPasswordRequired: "This lobby needs a password.",
+	dj.ErrWrongPassword:    "That password is not right.",
+	dj.ErrInviteRequired:   "This lobby can only be joined with an invite link.",
+	dj.ErrInvalidInvite:    "That invite is not valid or was revoked.",
+	dj.ErrInviteExpired:    "That invite has expired.",
+}
+
+// RequireMember keeps lobbies that need a password or invite from being read
+// by anyone who only knows the code. Open lobbies are left alone.
+func RequireMember(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lobby, ok := r.Context().Value(ContextLobby).(*dj.Lobby)
+		if !ok || lobby.Access() == dj.AccessOpen {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if user, ok := r.Context().Value(ContextUser).(*dj.User); ok && user.LobbyID == lobby.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("HX-Redirect", "/invite/"+lobby.ID)
+		http.Redirect(w, r, "/invite/"+lobby.ID, http.StatusSeeOther)
+	})
+}
+
+// refuseJoin shows the join form again, saying why access was refused.
+func refuseJoin(w http.ResponseWriter, r *http.Request, lobby *dj.Lobby, err error) {
+	prompt := templates.JoinPrompt{
+		Title:   lobby.DisplayTitle(),
+		Access:  lobby.Access(),
+		Name:    r.FormValue("name"),
+		Invite:  r.FormValue("invite"),
+		Problem: accessProblems[err],
+	}
+
+	// Nothing was tried yet, this is just the prompt
+	status := http.StatusForbidden
+	if errors.Is(err, dj.ErrPasswordRequired) {
+		status = http.StatusOK
+		prompt.Problem = ""
+	}
+
+	setContentTypeHTML(w)
+	w.WriteHeader(status)
+	templates.JoinLobbyPage(lobby, prompt).Render(r.Context(), w)
+}
+
+func requireOwner(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter) bool {
+	if !lobby.IsOwner(user) {
+		respondWithToast("Only the lobby owner can manage access", "error", w)
+		http.Error(w, "not lobby owner", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func renderAccess(lobby *dj.Lobby, w http.ResponseWriter, r *http.Request) {
+	setContentTypeHTML(w)
+	templates.AccessPartial(lobby, lobby.Access(), lobby.Invites(), lobby.Now()).Render(r.Context(), w)
+}
+
+func HandleLobbyAccess(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(lobby, user, w) {
+		return
+	}
+	renderAccess(lobby, w, r)
+}
+
+func HandleUpdateAccess(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(lobby, user, w) {
+		return
+	}
+
+	err := lobby.SetAccess(r.FormValue("access"), r.FormValue("password"))
+	if errors.Is(err, dj.ErrInvalidPassword) {
+		respondWithToast(fmt.Sprintf("Password must be %d to %d characters", dj.MinPasswordLength, dj.MaxPasswordLength), "error", w)
+		http.Error(w, "invalid password", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		mustGetLogger(r).Error("Could not set lobby access", tint.Err(err), lobby.Log())
+		respondWithToast("Could not change access", "error", w)
+		http.Error(w, "could not change access", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithToast("Access updated", "success", w)
+	renderAccess(lobby, w, r)
+}
+
+func HandleCreateInvite(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(lobby, user, w) {
+		return
+	}
+
+	var lifetime time.Duration
+	if minutes, err := strconv.Atoi(r.FormValue("lifetime")); err == nil && minutes > 0 {
+		lifetime = time.Duration(minutes) * time.Minute
+	}
+
+	invite, token, err := lobby.CreateInvite(lifetime)
+	if err != nil {
+		respondWithToast(fmt.Sprintf("A lobby can have at most %d invites", dj.MaxInvites), "error", w)
+		http.Error(w, "too many invites", http.StatusConflict)
+		return
+	}
+
+	if wantsJSON(r) {
+		respondJSON(w, struct {
+			ID        string    `json:"id"`
+			Token     string    `json:"token"`
+			ExpiresAt time.Time `json:"expiresAt"`
+		}{invite.ID, token, invite.ExpiresAt})
+		return
+	}
+
+	respondWithToast("Invite created", "success", w)
+	renderAccess(lobby, w, r)
+}
+
+func HandleRevokeInvite(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(lobby, user, w) {
+		return
+	}
+
+	if !lobby.RevokeInvite(chi.URLParam(r, "inviteId")) {
+		respondWithToast("Invite not found", "error", w)
+		http.Error(w, "invite not found", http.StatusNotFound)
+		return
+	}
+
+	respondWithToast("Invite revoked", "success", w)
+	renderAccess(lobby, w, r)
+}
+
+func HandleRevokeInvites(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(lobby, user, w) {
+		return
+	}
+
+	count := lobby.RevokeInvites()
+
+	respondWithToast(fmt.Sprintf("Revoked %d invites", count), "success", w)
+	renderAccess(lobby, w, r)
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// createInvite has the client mint an invite to the lobby and returns its token.
+func (c *testClient) createInvite(lobbyID string) string {
+	c.app.t.Helper()
+
+	req, _ := http.NewRequest(http.MethodPost, c.app.URL+"/lobby/"+lobbyID+"/invites", strings.NewReader("lifetime=60"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		c.app.t.Fatalf("create invite: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var invite struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&invite); err != nil || invite.Token == "" {
+		c.app.t.Fatalf("create invite: status %d, %v", resp.StatusCode, err)
+	}
+	return invite.Token
+}
+
+func (c *testClient) tryJoin(lobbyID string, form url.Values) int {
+	form.Set("name", "guest")
+	return c.post("/join/"+lobbyID, form).StatusCode
+}
+
+func TestPasswordLobby(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+
+	short := alice.post("/create", url.Values{"name": {"alice"}, "access": {"password"}, "password": {"abc"}})
+	if short.StatusCode != http.StatusBadRequest {
+		t.Errorf("short password: status %d, want 400", short.StatusCode)
+	}
+	id := alice.createLobby("alice", url.Values{"access": {"password"}, "password": {"hunter22"}})
+
+	bob := app.newClient("192.0.2.2")
+	if status := bob.tryJoin(id, url.Values{}); status != http.StatusOK || bob.sessionCookie() != "" {
+		t.Errorf("no password: status %d, want the password prompt", status)
+	}
+	if status := bob.tryJoin(id, url.Values{"password": {"hunter2"}}); status != http.StatusForbidden {
+		t.Errorf("wrong password: status %d, want 403", status)
+	}
+	if status := bob.tryJoin(id, url.Values{"password": {"hunter22"}}); status != http.StatusSeeOther {
+		t.Errorf("right password: status %d, want a redirect to the lobby", status)
+	}
+
+	// The code box on the landing page goes through the same check
+	carol := app.newClient("192.0.2.3")
+	if resp := carol.post("/join", url.Values{"code": {id}, "name": {"carol"}}); resp.StatusCode != http.StatusOK || carol.sessionCookie() != "" {
+		t.Errorf("code box without a password: status %d, want the password prompt", resp.StatusCode)
+	}
+}
+
+func TestInviteLobby(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", url.Values{"access": {"invite"}})
+
+	bob := app.newClient("192.0.2.2")
+	if status := bob.tryJoin(id, url.Values{"password": {"guess"}}); status != http.StatusForbidden {
+		t.Errorf("no invite: status %d, want 403", status)
+	}
+
+	token := alice.createInvite(id)
+	if status := bob.tryJoin(id, url.Values{"invite": {token}}); status != http.StatusSeeOther {
+		t.Fatalf("invite: status %d, want a redirect to the lobby", status)
+	}
+
+	// Only the owner manages invites
+	if resp := bob.post("/lobby/"+id+"/invites", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("invite from a guest: status %d, want 403", resp.StatusCode)
+	}
+	if resp := bob.post("/lobby/"+id+"/access", url.Values{"access": {"open"}}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("access change from a guest: status %d, want 403", resp.StatusCode)
+	}
+
+	if resp := alice.post("/lobby/"+id+"/invites/revoke", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("revoke: status %d", resp.StatusCode)
+	}
+	carol := app.newClient("192.0.2.3")
+	if status := carol.tryJoin(id, url.Values{"invite": {token}}); status != http.StatusForbidden {
+		t.Errorf("revoked invite: status %d, want 403", status)
+	}
+	if resp := bob.get("/lobby/" + id + "/users"); resp.StatusCode != http.StatusOK {
+		t.Errorf("revoking invites removed a member: status %d", resp.StatusCode)
+	}
+
+	alice.post("/lobby/"+id+"/access", url.Values{"access": {"open"}})
+	if status := carol.tryJoin(id, url.Values{}); status != http.StatusSeeOther {
+		t.Errorf("reopened lobby: status %d, want a redirect to the lobby", status)
+	}
+}
+
+func TestPrivateLobbyReads(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", url.Values{"access": {"invite"}})
+
+	bob := app.newClient("192.0.2.2")
+	bob.createLobby("bob", nil)
+
+	for _, path := range []string{"/playlist", "/history", "/video", "/history/export", "/users"} {
+		if resp := bob.get("/lobby/" + id + path); resp.StatusCode != http.StatusSeeOther {
+			t.Errorf("%s by a member of another lobby: status %d, want a redirect", path, resp.StatusCode)
+		}
+		if resp := app.newClient("192.0.2.3").get("/lobby/" + id + path); resp.StatusCode != http.StatusSeeOther {
+			t.Errorf("%s without a session: status %d, want a redirect", path, resp.StatusCode)
+		}
+		if resp := alice.get("/lobby/" + id + path); resp.StatusCode != http.StatusOK {
+			t.Errorf("%s by a member: status %d", path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/btnmasher/testdj/internal/dj"
+)
+
+func (a *testApp) setIPPolicy(policy string) {
+	settings := a.Manager.Settings()
+	settings.IPPolicy = policy
+	a.Manager.SetSettings(settings)
+}
+
+func (a *testApp) userNamed(lobby *dj.Lobby, name string) *dj.User {
+	a.t.Helper()
+	for u := range lobby.Users.Values() {
+		if u.Name == name {
+			return u
+		}
+	}
+	a.t.Fatalf("no user %q in the lobby", name)
+	return nil
+}
+
+// joinRefused reports whether joining showed an error page instead of
+// sending the client to the lobby.
+func (c *testClient) joinRefused(lobbyID, name string) bool {
+	resp := c.post("/join/"+lobbyID, url.Values{"name": {name}})
+	return resp.StatusCode == http.StatusOK && c.sessionCookie() == ""
+}
+
+func TestIPPolicyOff(t *testing.T) {
+	app := newTestApp(t)
+	app.setIPPolicy(dj.IPPolicyOff)
+
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+	alice.openSSE(id)
+
+	bob := app.newClient("192.0.2.1")
+	bob.join(id, "bob")
+	bob.openSSE(id)
+
+	for _, c := range []*testClient{alice, bob} {
+		if resp := c.get("/lobby/" + id + "/users"); resp.StatusCode != http.StatusOK {
+			t.Errorf("status %d, want both users on the address kept", resp.StatusCode)
+		}
+	}
+
+	lobby := app.lobby(id)
+	aliceUser, bobUser := app.userNamed(lobby, "alice"), app.userNamed(lobby, "bob")
+	if lobby.MuteKey(aliceUser) == lobby.MuteKey(bobUser) {
+		t.Error("users sharing an address share mutes")
+	}
+	if !lobby.IsOwner(aliceUser) || lobby.IsOwner(bobUser) {
+		t.Error("ownership should follow the creator, not the address")
+	}
+}
+
+func TestIPPolicyPerLobby(t *testing.T) {
+	app := newTestApp(t)
+	app.setIPPolicy(dj.IPPolicyPerLobby)
+
+	alice := app.newClient("192.0.2.1")
+	first := alice.createLobby("alice", nil)
+	alice.openSSE(first)
+
+	carol := app.newClient("192.0.2.3")
+	second := carol.createLobby("carol", nil)
+
+	bob := app.newClient("192.0.2.1")
+	bob.join(second, "bob")
+	if resp := alice.get("/lobby/" + first + "/users"); resp.StatusCode != http.StatusOK {
+		t.Errorf("joining another lobby from the address ended the first session: status %d", resp.StatusCode)
+	}
+
+	dave := app.newClient("192.0.2.1")
+	if !dave.joinRefused(first, "dave") {
+		t.Error("a second device on the address joined the same lobby")
+	}
+}
+
+func TestIPPolicyPerIP(t *testing.T) {
+	app := newTestApp(t)
+
+	alice := app.newClient("192.0.2.1")
+	first := alice.createLobby("alice", nil)
+	alice.openSSE(first)
+
+	carol := app.newClient("192.0.2.3")
+	second := carol.createLobby("carol", nil)
+
+	bob := app.newClient("192.0.2.1")
+	if !bob.joinRefused(first, "bob") {
+		t.Error("a second device on the address joined the same lobby")
+	}
+
+	bob.join(second, "bob")
+	if !alice.refused("/lobby/" + first + "/users") {
+		t.Error("the address still has its first session after joining elsewhere")
+	}
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/btnmasher/testdj/internal/config"
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/metrics"
+	"github.com/btnmasher/testdj/internal/shared"
+	"github.com/btnmasher/testdj/internal/templates"
+)
+
+// AdminCookieName holds the admin console session token.
+const AdminCookieName = "admin_session"
+
+// adminSessions are the console sign ins. Like user sessions only the token
+// hashes are kept, and they expire on the server whatever the browser does.
+type adminSessions struct {
+	mu        sync.Mutex
+	expiresAt map[string]time.Time
+}
+
+// issue starts a session lasting lifetime and returns its token.
+func (s *adminSessions) issue(now time.Time, lifetime time.Duration) string {
+	token := shared.NewSessionToken()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, expires := range s.expiresAt {
+		if !now.Before(expires) {
+			delete(s.expiresAt, hash)
+		}
+	}
+	if s.expiresAt == nil {
+		s.expiresAt = make(map[string]time.Time)
+	}
+	s.expiresAt[shared.HashSessionToken(token)] = now.Add(lifetime)
+	return token
+}
+
+func (s *adminSessions) valid(token string, now time.Time) bool {
+	if !shared.ValidSessionToken(token) {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires, ok := s.expiresAt[shared.HashSessionToken(token)]
+	return ok && now.Before(expires)
+}
+
+func (s *adminSessions) end(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.expiresAt, shared.HashSessionToken(token))
+}
+
+// endAll signs everyone out, used when the admin token changes.
+func (s *adminSessions) endAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.expiresAt)
+}
+
+// isAdmin reports whether the request carries the admin token as a bearer
+// token, or the cookie of a live admin session.
+func (rt *Runtime) isAdmin(r *http.Request, token string) bool {
+	if given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+	}
+	if cookie, err := r.Cookie(AdminCookieName); err == nil {
+		return rt.admin.valid(cookie.Value, rt.Manager.Now())
+	}
+	return false
+}
+
+// RequireAdmin only lets admin requests through. Browsers are sent to the
+// sign in page, other clients get a 401. The admin routes answer 404 when no
+// token is configured.
+func RequireAdmin(rt *Runtime) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := rt.Config().Admin.Token
+			if token == "" {
+				http.NotFound(w, r)
+				return
+			}
+
+			if !rt.isAdmin(r, token) {
+				if r.Method == http.MethodGet && r.Header.Get("Authorization") == "" {
+					http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+					return
+				}
+				mustGetLogger(r).Warn("Admin request refused", slog.String("path", r.URL.Path))
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func HandleAdminLoginPage(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rt.Config().Admin.Token == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		setContentTypeHTML(w)
+		templates.AdminLogin(false).Render(r.Context(), w)
+	}
+}
+
+func HandleAdminLogin(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := rt.Config()
+		token := cfg.Admin.Token
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		given := r.FormValue("token")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			mustGetLogger(r).Warn("Admin sign in refused")
+			setContentTypeHTML(w)
+			w.WriteHeader(http.StatusUnauthorized)
+			templates.AdminLogin(true).Render(r.Context(), w)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     AdminCookieName,
+			Value:    rt.admin.issue(rt.Manager.Now(), cfg.Session.MaxAge),
+			Path:     "/admin",
+			HttpOnly: true,
+			Secure:   isHTTPS(r),
+			SameSite: http.SameSiteStrictMode,
+			MaxAge:   int(cfg.Session.MaxAge.Seconds()),
+		})
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	}
+}
+
+func HandleAdminLogout(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(AdminCookieName); err == nil {
+			rt.admin.end(cookie.Value)
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     AdminCookieName,
+			Value:    "",
+			Path:     "/admin",
+			HttpOnly: true,
+			Secure:   isHTTPS(r),
+			SameSite: http.SameSiteStrictMode,
+			MaxAge:   -1, // delete immediately
+			Expires:  time.Unix(0, 0),
+		})
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+	}
+}
+
+// adminDone sends the browser back to the console with a notice.
+func adminDone(w http.ResponseWriter, r *http.Request, notice string) {
+	http.Redirect(w, r, "/admin?notice="+url.QueryEscape(notice), http.StatusSeeOther)
+}
+
+// adminLobby returns the lobby named in the route, answering 404 if it is gone.
+func adminLobby(rt *Runtime, w http.ResponseWriter, r *http.Request) (*dj.Lobby, bool) {
+	lobby, ok := rt.Manager.GetLobby(chi.URLParam(r, "lobbyId"))
+	if !ok {
+		http.Error(w, "lobby not found", http.StatusNotFound)
+	}
+	return lobby, ok
+}
+
+func HandleAdminConsole(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		console := templates.AdminConsole{
+			Now:        time.Now(),
+			MaxLobbies: rt.Manager.Settings().MaxLobbies,
+			Fetch:      metrics.FetchHealth(),
+			Notice:     r.URL.Query().Get("notice"),
+		}
+
+		lobbies := rt.Manager.Lobbies.ValuesSlice()
+		slices.SortFunc(lobbies, func(a, b *dj.Lobby) int { return a.CreatedAt.Compare(b.CreatedAt) })
+		for _, lobby := range lobbies {
+			console.Lobbies = append(console.Lobbies, adminLobbyView(r, lobby))
+		}
+
+		setContentTypeHTML(w)
+		templates.AdminPage(console).Render(r.Context(), w)
+	}
+}
+
+func adminLobbyView(r *http.Request, lobby *dj.Lobby) templates.AdminLobby {
+	defer lobby.LockTraced(r.Context(), "AdminConsole")()
+
+	view := templates.AdminLobby{
+		ID:      lobby.ID,
+		Fields:  lobby.Log().Value.Group(),
+		Current: lobby.CurrentVideo,
+		Queue:   slices.Clone(lobby.Videos),
+		Mutes:   lobby.Mutes.Length(),
+	}
+
+	for user := range lobby.Users.Values() {
+		view.Users = append(view.Users, templates.AdminUser{
+			ID:           user.ID,
+			Name:         user.Name,
+			IP:           user.IP,
+			Connected:    user.SSE != nil && user.SSE.Context.Err() == nil,
+			MutedUntil:   user.MutedUntil,
+			LastActivity: user.LastActivity(),
+		})
+	}
+	slices.SortFunc(view.Users, func(a, b templates.AdminUser) int { return strings.Compare(a.Name, b.Name) })
+
+	return view
+}
+
+func HandleAdminAnnounce(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		message := strings.TrimSpace(r.FormValue("message"))
+		if message == "" || utf8.RuneCountInString(message) > templates.AnnouncementMaxLength {
+			http.Error(w, fmt.Sprintf("announcement must be 1 to %d characters", templates.AnnouncementMaxLength), http.StatusBadRequest)
+			return
+		}
+
+		count := rt.Manager.Announce(message)
+		adminDone(w, r, fmt.Sprintf("Announced to %d lobbies", count))
+	}
+}
+
+func HandleAdminExpireLobby(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lobby, ok := adminLobby(rt, w, r)
+		if !ok {
+			return
+		}
+
+		mustGetLogger(r).Info("Admin expired lobby", lobby.Log())
+		lobby.Expire()
+		adminDone(w, r, "Expired lobby "+lobby.ID)
+	}
+}
+
+func HandleAdminKickUser(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lobby, ok := adminLobby(rt, w, r)
+		if !ok {
+			return
+		}
+
+		if !rt.Manager.KickUser(lobby, chi.URLParam(r, "userId")) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		adminDone(w, r, "Kicked user from lobby "+lobby.ID)
+	}
+}
+
+func HandleAdminClearMutes(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lobby, ok := adminLobby(rt, w, r)
+		if !ok {
+			return
+		}
+
+		count := lobby.ClearMutes()
+		adminDone(w, r, fmt.Sprintf("Cleared %d mutes in lobby %s", count, lobby.ID))
+	}
+}
+
+type reloadResponse struct {
+	Changes []config.Change `json:"changes"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// HandleAdminReload reloads the configuration and responds with the changes.
+func HandleAdminReload(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		changes, err := rt.Reload()
+
+		status := http.StatusOK
+		resp := reloadResponse{Changes: changes}
+		if resp.Changes == nil {
+			resp.Changes = []config.Change{}
+		}
+
+		switch {
+		case errors.Is(err, ErrReloadDisabled):
+			status = http.StatusConflict
+			resp.Error = err.Error()
+		case err != nil:
+			status = http.StatusUnprocessableEntity
+			resp.Error = err.Error()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
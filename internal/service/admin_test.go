@@ -0,0 +1,304 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/config"
+	"github.com/btnmasher/testdj/internal/dj"
+)
+
+const testAdminToken = "test-admin-token-0123"
+
+func (a *testApp) adminPost(path, token string) (*http.Response, reloadResponse) {
+	a.t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, a.URL+path, nil)
+	if err != nil {
+		a.t.Fatalf("POST %s: %v", path, err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := a.Client().Do(req)
+	if err != nil {
+		a.t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var body reloadResponse
+	if resp.Header.Get("Content-Type") == "application/json" {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			a.t.Fatalf("decode %s: %v", path, err)
+		}
+	}
+	return resp, body
+}
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	app := newTestApp(t)
+
+	if resp, _ := app.adminPost("/admin/reload", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status %d, want 404 when no admin token is set", resp.StatusCode)
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	app := newTestApp(t)
+	rt := app.Runtime
+
+	cfg := config.Default()
+	cfg.Admin.Token = testAdminToken
+	rt.config.Store(cfg)
+	rt.LogLevel = new(slog.LevelVar)
+
+	if resp, _ := app.adminPost("/admin/reload", "wrong-token-0123456"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d, want 401", resp.StatusCode)
+	}
+
+	if resp, _ := app.adminPost("/admin/reload", testAdminToken); resp.StatusCode != http.StatusConflict {
+		t.Errorf("without a loader: status %d, want 409", resp.StatusCode)
+	}
+
+	next := config.Default()
+	next.Admin.Token = testAdminToken
+	next.Log.Level = "debug"
+	next.Lobby.MaxLobbies = 1
+	next.Server.Port = 9000
+	next.Session.Key = strings.Repeat("k", config.MinSessionKeyLength)
+	rt.Load = func() (*config.Config, error) { return next, nil }
+
+	resp, body := app.adminPost("/admin/reload", testAdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("reload: status %d %q", resp.StatusCode, body.Error)
+	}
+	if len(body.Changes) != 4 {
+		t.Errorf("changes = %+v, want log level, max lobbies, port and session key", body.Changes)
+	}
+
+	if got := rt.LogLevel.Level(); got != slog.LevelDebug {
+		t.Errorf("log level = %v, want debug", got)
+	}
+	if got := app.Manager.Settings().MaxLobbies; got != 1 {
+		t.Errorf("MaxLobbies = %d, want 1", got)
+	}
+	if got := rt.Config().Server.Port; got != config.DefaultPort {
+		t.Errorf("port = %d, want the running port kept", got)
+	}
+	if got := rt.Config().Session.Key; got != "" {
+		t.Errorf("session key = %q, want the running key kept", got)
+	}
+
+	// The new limit applies to the next lobby
+	alice := app.newClient("192.0.2.1")
+	alice.createLobby("alice", nil)
+	bob := app.newClient("192.0.2.2")
+	if resp := bob.post("/create", url.Values{"name": {"bob"}}); resp.StatusCode == http.StatusSeeOther {
+		t.Error("second lobby created past the reloaded limit")
+	}
+
+	// A failed load keeps the running config
+	rt.Load = func() (*config.Config, error) { return nil, errors.New("bad file") }
+	resp, body = app.adminPost("/admin/reload", testAdminToken)
+	if resp.StatusCode != http.StatusUnprocessableEntity || body.Error != "bad file" {
+		t.Errorf("failed reload: status %d %q, want 422", resp.StatusCode, body.Error)
+	}
+	if rt.Config() != next {
+		t.Error("failed reload replaced the config")
+	}
+}
+
+// enableAdmin sets the admin token on the running config.
+func (a *testApp) enableAdmin() {
+	cfg := *a.Runtime.Config()
+	cfg.Admin.Token = testAdminToken
+	a.Runtime.config.Store(&cfg)
+}
+
+// page fetches path and returns the status and body.
+func (c *testClient) page(path string) (int, string) {
+	c.app.t.Helper()
+
+	resp, err := c.HTTP.Get(c.app.URL + path)
+	if err != nil {
+		c.app.t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestAdminConsoleSignIn(t *testing.T) {
+	app := newTestApp(t)
+	app.enableAdmin()
+	admin := app.newClient("192.0.2.9")
+
+	if resp := admin.get("/admin"); resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/admin/login" {
+		t.Errorf("signed out console: got %d to %q, want the sign in page", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp := admin.post("/admin/announce", url.Values{"message": {"hi"}}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("signed out action: status %d, want 401", resp.StatusCode)
+	}
+
+	if resp := admin.post("/admin/login", url.Values{"token": {"wrong-token-0123456"}}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d, want 401", resp.StatusCode)
+	}
+	if resp := admin.post("/admin/login", url.Values{"token": {testAdminToken}}); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("sign in: status %d", resp.StatusCode)
+	}
+
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+
+	status, body := admin.page("/admin")
+	if status != http.StatusOK || !strings.Contains(body, id) || !strings.Contains(body, "alice") {
+		t.Errorf("console: status %d, want the lobby and its user listed", status)
+	}
+
+	admin.post("/admin/logout", nil)
+	if resp := admin.get("/admin"); resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("after sign out: status %d, want a redirect", resp.StatusCode)
+	}
+}
+
+func TestAdminActions(t *testing.T) {
+	app := newTestApp(t)
+	app.enableAdmin()
+	admin := app.newClient("192.0.2.9")
+	admin.post("/admin/login", url.Values{"token": {testAdminToken}})
+
+	alice := app.newClient("192.0.2.1")
+	bob := app.newClient("192.0.2.2")
+	id := alice.createLobby("alice", nil)
+	bob.join(id, "bob")
+	aliceEvents := alice.openSSE(id)
+	bobEvents := bob.openSSE(id)
+
+	lobby := app.lobby(id)
+
+	// Announce
+	if resp := admin.post("/admin/announce", url.Values{"message": {`Restart at "noon"`}}); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("announce: status %d", resp.StatusCode)
+	}
+	ev := aliceEvents.waitFor(dj.UpdateToast)
+	if !strings.Contains(ev.Data, `Restart at \"noon\"`) {
+		t.Errorf("announcement %s, want the message JSON escaped", ev.Data)
+	}
+
+	// Clear mutes
+	var bobUser *dj.User
+	for u := range lobby.Users.Values() {
+		if u.Name == "bob" {
+			bobUser = u
+		}
+	}
+	mutedUntil := lobby.Now().Add(time.Hour)
+	lobby.Mutes.Set(lobby.MuteKey(bobUser), mutedUntil)
+	bobUser.MutedUntil = mutedUntil
+
+	admin.post("/admin/lobbies/"+id+"/mutes/clear", nil)
+	if lobby.Mutes.Length() != 0 || !bobUser.MutedUntil.IsZero() {
+		t.Error("mutes not cleared")
+	}
+
+	// Kick
+	if resp := admin.post("/admin/lobbies/"+id+"/users/"+bobUser.ID+"/kick", nil); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("kick: status %d", resp.StatusCode)
+	}
+	bobEvents.waitFor("redirect")
+	if _, ok := lobby.Users.Get(bobUser.ID); ok {
+		t.Error("kicked user still in the lobby")
+	}
+	if resp := bob.get("/lobby/" + id + "/"); resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("kicked user's session: status %d, want a redirect", resp.StatusCode)
+	}
+	if resp := admin.post("/admin/lobbies/"+id+"/users/"+bobUser.ID+"/kick", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("kick again: status %d, want 404", resp.StatusCode)
+	}
+
+	// Expire
+	if resp := admin.post("/admin/lobbies/"+id+"/expire", nil); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expire: status %d", resp.StatusCode)
+	}
+	aliceEvents.waitFor(dj.UpdateLobbyExpired)
+	if _, ok := app.Manager.GetLobby(id); ok {
+		t.Error("expired lobby still open")
+	}
+	if resp := admin.post("/admin/lobbies/"+id+"/expire", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expire again: status %d, want 404", resp.StatusCode)
+	}
+}
+
+// adminCookie returns the client's admin session cookie, scoped to /admin.
+func (c *testClient) adminCookie() string {
+	u, _ := url.Parse(c.app.URL + "/admin/")
+	for _, cookie := range c.HTTP.Jar.Cookies(u) {
+		if cookie.Name == AdminCookieName {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// signedIn reports whether an admin session cookie gets into the console.
+func (a *testApp) signedIn(value string) bool {
+	client := a.newClient("192.0.2.10")
+	u, _ := url.Parse(a.URL + "/admin/")
+	client.HTTP.Jar.SetCookies(u, []*http.Cookie{{Name: AdminCookieName, Value: value, Path: "/admin"}})
+	return client.get("/admin").StatusCode == http.StatusOK
+}
+
+func TestAdminSessions(t *testing.T) {
+	app := newTestApp(t)
+	app.enableAdmin()
+	admin := app.newClient("192.0.2.9")
+
+	admin.post("/admin/login", url.Values{"token": {testAdminToken}})
+	first := admin.adminCookie()
+	if !app.signedIn(first) {
+		t.Fatal("admin session cookie refused")
+	}
+	if app.signedIn(testAdminToken) {
+		t.Error("the admin token itself was taken as a session")
+	}
+
+	// Signing out ends the session on the server, not just in the browser
+	admin.post("/admin/logout", nil)
+	if app.signedIn(first) {
+		t.Error("session still works after signing out")
+	}
+
+	admin.post("/admin/login", url.Values{"token": {testAdminToken}})
+	second := admin.adminCookie()
+	if second == first {
+		t.Error("signing in again reused the session token")
+	}
+	app.Clock.Advance(app.Runtime.Config().Session.MaxAge)
+	if app.signedIn(second) {
+		t.Error("session still works past its lifetime")
+	}
+
+	// A new admin token signs everyone out
+	admin.post("/admin/login", url.Values{"token": {testAdminToken}})
+	third := admin.adminCookie()
+	next := *app.Runtime.Config()
+	next.Admin.Token = testAdminToken + "-rotated"
+	app.Runtime.Load = func() (*config.Config, error) { return &next, nil }
+	if _, err := app.Runtime.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	// Even once the old token is back, the session stays ended
+	app.enableAdmin()
+	if app.signedIn(third) {
+		t.Error("session still works after the admin token changed")
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lmittmann/tint"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/metrics"
+	"github.com/btnmasher/testdj/internal/templates"
+)
+
+// directoryThrottle is the least time between directory updates sent to a
+// client, changes in between go out together.
+const directoryThrottle = 2 * time.Second
+
+var tagRegex = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+func parseListing(r *http.Request) (dj.Listing, string) {
+	listing := dj.Listing{
+		Public:      r.FormValue("public") == "true",
+		Description: strings.TrimSpace(r.FormValue("description")),
+	}
+
+	if len(listing.Description) > dj.MaxListingDescriptionLength {
+		return listing, fmt.Sprintf("Description can be at most %d characters", dj.MaxListingDescriptionLength)
+	}
+
+	tags, ok := parsePolicyList(strings.ToLower(r.FormValue("tags")))
+	if !ok || len(tags) > dj.MaxListingTags {
+		return listing, fmt.Sprintf("A lobby can have at most %d tags", dj.MaxListingTags)
+	}
+	for _, tag := range tags {
+		if len(tag) > dj.MaxListingTagLength || !tagRegex.MatchString(tag) {
+			return listing, fmt.Sprintf("Tags are up to %d lowercase letters, numbers and dashes", dj.MaxListingTagLength)
+		}
+	}
+	listing.Tags = tags
+
+	return listing, ""
+}
+
+// HandleDirectory lists the public lobbies, as a page or as JSON.
+func HandleDirectory(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(r.URL.Query().Get("tag"))
+	entries := mustGetManager(r).Directory(tag)
+
+	if wantsJSON(r) {
+		respondJSON(w, map[string][]dj.DirectoryEntry{"lobbies": entries})
+		return
+	}
+
+	setContentTypeHTML(w)
+	templates.DirectoryPage(entries, tag).Render(r.Context(), w)
+}
+
+// HandleDirectoryList renders the listing alone, refetched on updates.
+func HandleDirectoryList(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(r.URL.Query().Get("tag"))
+
+	setContentTypeHTML(w)
+	templates.DirectoryPartial(mustGetManager(r).Directory(tag), tag).Render(r.Context(), w)
+}
+
+// HandleDirectorySSE streams an update event whenever the directory may have
+// changed, at most one every directoryThrottle.
+func HandleDirectorySSE(w http.ResponseWriter, r *http.Request) {
+	logger := mustGetLogger(r).With("service", "directory-source")
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	updates, stop := mustGetManager(r).WatchDirectory()
+	defer stop()
+
+	metrics.SSEConnections.Inc()
+	defer metrics.SSEConnections.Dec()
+
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logger.Warn("Flush error", tint.Err(err))
+		return
+	}
+
+	ticker := time.NewTicker(60 * time.Second) // heartbeat
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-updates:
+			fmt.Fprintf(w, "event: %s\ndata: \n\n", dj.UpdateDirectory)
+		case t := <-ticker.C:
+			fmt.Fprintf(w, ": ping %d\n\n", t.Unix())
+		}
+
+		if err := rc.Flush(); err != nil {
+			logger.Debug("Directory stream closed", tint.Err(err))
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(directoryThrottle):
+		}
+	}
+}
+
+func HandleLobbyListing(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !lobby.IsOwner(user) {
+		respondWithToast("Only the lobby owner can list the lobby", "error", w)
+		http.Error(w, "not lobby owner", http.StatusForbidden)
+		return
+	}
+
+	setContentTypeHTML(w)
+	templates.ListingPartial(lobby, lobby.Listing()).Render(r.Context(), w)
+}
+
+func HandleUpdateListing(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !lobby.IsOwner(user) {
+		respondWithToast("Only the lobby owner can list the lobby", "error", w)
+		http.Error(w, "not lobby owner", http.StatusForbidden)
+		return
+	}
+
+	listing, problem := parseListing(r)
+	if problem == "" && listing.Public && lobby.DisplayTitle() == "" {
+		problem = "Name the lobby before listing it"
+	}
+	if problem != "" {
+		respondWithToast(problem, "error", w)
+		http.Error(w, "invalid listing", http.StatusBadRequest)
+		return
+	}
+
+	lobby.SetListing(listing)
+
+	if listing.Public && lobby.Access() == dj.AccessInvite {
+		respondWithToast("Listing saved, invite only lobbies are not shown in the directory", "success", w)
+	} else {
+		respondWithToast("Listing saved", "success", w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// slugProblems is what the owner is told when a slug can't be claimed.
+var slugProblems = map[error]string{
+	dj.ErrInvalidSlug:  fmt.Sprintf("Links are %d to %d lowercase letters, numbers and dashes", dj.MinSlugLength, dj.MaxSlugLength),
+	dj.ErrReservedSlug: "That link is reserved",
+	dj.ErrSlugTaken:    "That link is taken by another lobby",
+}
+
+func HandleLobbyName(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !lobby.IsOwner(user) {
+		respondWithToast("Only the lobby owner can rename the lobby", "error", w)
+		http.Error(w, "not lobby owner", http.StatusForbidden)
+		return
+	}
+
+	setContentTypeHTML(w)
+	templates.NamePartial(lobby, lobby.DisplayTitle(), lobby.CurrentSlug()).Render(r.Context(), w)
+}
+
+func HandleUpdateName(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !lobby.IsOwner(user) {
+		respondWithToast("Only the lobby owner can rename the lobby", "error", w)
+		http.Error(w, "not lobby owner", http.StatusForbidden)
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	if !dj.ValidTitle(title) {
+		respondWithToast(fmt.Sprintf("Name can be at most %d characters", dj.MaxTitleLength), "error", w)
+		http.Error(w, "invalid title", http.StatusBadRequest)
+		return
+	}
+	if title == "" && lobby.Listing().Public {
+		respondWithToast("Listed lobbies need a name", "error", w)
+		http.Error(w, "invalid title", http.StatusBadRequest)
+		return
+	}
+
+	if err := lobby.Manager.ClaimSlug(lobby, r.FormValue("slug")); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, dj.ErrSlugTaken) {
+			status = http.StatusConflict
+		}
+		respondWithToast(slugProblems[err], "error", w)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if title != lobby.DisplayTitle() {
+		lobby.SetTitle(title)
+	}
+
+	respondWithToast("Name saved", "success", w)
+	w.WriteHeader(http.StatusNoContent)
+}
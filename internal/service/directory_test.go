@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/btnmasher/testdj/internal/dj"
+)
+
+func (a *testApp) directory(query string) []dj.DirectoryEntry {
+	a.t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, a.URL+"/lobbies"+query, nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("directory: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Lobbies []dj.DirectoryEntry `json:"lobbies"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		a.t.Fatalf("directory: %v", err)
+	}
+	return body.Lobbies
+}
+
+func TestDirectory(t *testing.T) {
+	app := newTestApp(t)
+
+	alice := app.newClient("192.0.2.1")
+	listed := alice.createLobby("alice", url.Values{"mode": {dj.LobbyModeShuffle}})
+	bob := app.newClient("192.0.2.2")
+	bob.join(listed, "bob")
+
+	if resp := alice.post("/lobby/"+listed+"/listing", url.Values{"public": {"true"}}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("public without a name: status %d, want 400", resp.StatusCode)
+	}
+	alice.post("/lobby/"+listed+"/name", url.Values{"title": {"Friday Jams"}})
+	if resp := bob.post("/lobby/"+listed+"/listing", url.Values{"public": {"true"}}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("listing by a guest: status %d, want 403", resp.StatusCode)
+	}
+	if resp := alice.post("/lobby/"+listed+"/listing", url.Values{"public": {"true"}, "tags": {"Jazz, lo-fi"}}); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("listing: status %d", resp.StatusCode)
+	}
+
+	carol := app.newClient("192.0.2.3")
+	carol.createLobby("carol", nil)
+
+	dave := app.newClient("192.0.2.4")
+	hidden := dave.createLobby("dave", url.Values{"access": {dj.AccessInvite}, "title": {"Secret"}})
+	dave.post("/lobby/"+hidden+"/listing", url.Values{"public": {"true"}})
+
+	entries := app.directory("")
+	if len(entries) != 1 {
+		t.Fatalf("directory %+v, want only the public open lobby", entries)
+	}
+	e := entries[0]
+	if e.ID != listed || e.Title != "Friday Jams" || e.Users != 2 || e.Mode != dj.LobbyModeShuffle || len(e.Tags) != 2 || e.Tags[0] != "jazz" {
+		t.Errorf("entry %+v", e)
+	}
+
+	if len(app.directory("?tag=jazz")) != 1 || len(app.directory("?tag=metal")) != 0 {
+		t.Error("tag filter")
+	}
+
+	alice.post("/lobby/"+listed+"/listing", nil)
+	if entries := app.directory(""); len(entries) != 0 {
+		t.Errorf("directory %+v after unlisting, want it empty", entries)
+	}
+}
+
+func TestDirectoryStream(t *testing.T) {
+	app := newTestApp(t)
+
+	watcher := app.newClient("192.0.2.9")
+	events := watcher.openStream("/lobbies/sse")
+
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", url.Values{"title": {"Friday Jams"}})
+	alice.post("/lobby/"+id+"/listing", url.Values{"public": {"true"}})
+	events.waitFor(dj.UpdateDirectory)
+
+	if status, body := watcher.page("/lobbies/list"); status != http.StatusOK || !strings.Contains(body, "Friday Jams") {
+		t.Errorf("list: status %d, want the lobby listed", status)
+	}
+}
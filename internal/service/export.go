@@ -0,0 +1,222 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lmittmann/tint"
+
+	"github.com/btnmasher/testdj/internal/dj"
+)
+
+const (
+	ExportFormatJSON    = "json"
+	ExportFormatCSV     = "csv"
+	ExportFormatM3U     = "m3u"
+	ExportFormatYouTube = "youtube"
+)
+
+// YouTube's anonymous playlist URLs accept at most 50 video IDs.
+const youtubePlaylistChunk = 50
+
+type exportFormat struct {
+	ContentType string
+	Extension   string
+	Write       func(io.Writer, []dj.PlayRecord) error
+}
+
+var exportFormats = map[string]exportFormat{
+	ExportFormatJSON:    {"application/json", "json", writeHistoryJSON},
+	ExportFormatCSV:     {"text/csv; charset=utf-8", "csv", writeHistoryCSV},
+	ExportFormatM3U:     {"audio/x-mpegurl; charset=utf-8", "m3u", writeHistoryM3U},
+	ExportFormatYouTube: {"text/plain; charset=utf-8", "txt", writeHistoryYouTube},
+}
+
+type exportRecord struct {
+	VideoID         string         `json:"videoId"`
+	URL             string         `json:"url"`
+	Title           string         `json:"title"`
+	Channel         string         `json:"channel,omitempty"`
+	ThumbnailURL    string         `json:"thumbnailUrl,omitempty"`
+	DurationSeconds int            `json:"durationSeconds"`
+	SubmitterID     string         `json:"submitterId"`
+	SubmitterName   string         `json:"submitterName"`
+	StartedAt       time.Time      `json:"startedAt"`
+	EndedAt         *time.Time     `json:"endedAt"`
+	Skipped         bool           `json:"skipped"`
+	Failed          bool           `json:"failed"`
+	PlayerError     int            `json:"playerError,omitempty"`
+	VoteOutcome     string         `json:"voteOutcome"`
+	Reactions       map[string]int `json:"reactions,omitempty"`
+}
+
+func watchURL(videoId string) string {
+	return "https://www.youtube.com/watch?v=" + videoId
+}
+
+func toExportRecord(p dj.PlayRecord) exportRecord {
+	rec := exportRecord{
+		VideoID:         p.VideoID,
+		URL:             watchURL(p.VideoID),
+		Title:           html.UnescapeString(p.Title),
+		Channel:         p.Channel,
+		ThumbnailURL:    p.ThumbnailURL,
+		DurationSeconds: int(p.Duration.Seconds()),
+		SubmitterID:     p.SubmitterID,
+		SubmitterName:   p.SubmitterName,
+		StartedAt:       p.StartedAt.UTC(),
+		Skipped:         p.Skipped,
+		Failed:          p.Failed,
+		PlayerError:     p.PlayerError,
+		VoteOutcome:     p.VoteOutcome,
+		Reactions:       p.Reactions,
+	}
+	if !p.InProgress() {
+		ended := p.EndedAt.UTC()
+		rec.EndedAt = &ended
+	}
+	return rec
+}
+
+func writeHistoryJSON(w io.Writer, history []dj.PlayRecord) error {
+	records := make([]exportRecord, 0, len(history))
+	for _, p := range history {
+		records = append(records, toExportRecord(p))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// csvText neutralises text that a spreadsheet would run as a formula. Titles,
+// channels and names come from uploaders and users, so they are prefixed with
+// a quote when they start like one.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func writeHistoryCSV(w io.Writer, history []dj.PlayRecord) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{
+		"started_at", "ended_at", "video_id", "url", "title", "channel", "duration_seconds",
+		"submitter_id", "submitter_name", "skipped", "failed", "vote_outcome",
+	}
+	for _, r := range dj.Reactions {
+		header = append(header, "reaction_"+r)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, p := range history {
+		rec := toExportRecord(p)
+		var ended string
+		if rec.EndedAt != nil {
+			ended = rec.EndedAt.Format(time.RFC3339)
+		}
+
+		row := []string{
+			rec.StartedAt.Format(time.RFC3339),
+			ended,
+			rec.VideoID,
+			rec.URL,
+			csvText(rec.Title),
+			csvText(rec.Channel),
+			strconv.Itoa(rec.DurationSeconds),
+			rec.SubmitterID,
+			csvText(rec.SubmitterName),
+			strconv.FormatBool(rec.Skipped),
+			strconv.FormatBool(rec.Failed),
+			rec.VoteOutcome,
+		}
+		for _, r := range dj.Reactions {
+			row = append(row, strconv.Itoa(rec.Reactions[r]))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeHistoryM3U(w io.Writer, history []dj.PlayRecord) error {
+	if _, err := fmt.Fprintln(w, "#EXTM3U"); err != nil {
+		return err
+	}
+
+	for _, p := range history {
+		rec := toExportRecord(p)
+		// Titles are single line in the EXTINF directive
+		title := strings.NewReplacer("\r", " ", "\n", " ").Replace(rec.Title)
+		if _, err := fmt.Fprintf(w, "#EXTINF:%d,%s\n%s\n", rec.DurationSeconds, title, rec.URL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeHistoryYouTube writes one anonymous YouTube playlist URL per line,
+// in play order, split into chunks YouTube will accept.
+func writeHistoryYouTube(w io.Writer, history []dj.PlayRecord) error {
+	ids := make([]string, 0, len(history))
+	for _, p := range history {
+		ids = append(ids, p.VideoID)
+	}
+
+	for start := 0; start < len(ids); start += youtubePlaylistChunk {
+		end := min(start+youtubePlaylistChunk, len(ids))
+		if _, err := fmt.Fprintf(w, "https://www.youtube.com/watch_videos?video_ids=%s\n", strings.Join(ids[start:end], ",")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func HandleLobbyHistoryExport(w http.ResponseWriter, r *http.Request) {
+	lobby, ok := r.Context().Value(ContextLobby).(*dj.Lobby)
+	if !ok {
+		respondWithToast("Invalid Lobby", "error", w)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	name := strings.ToLower(r.URL.Query().Get("format"))
+	if name == "" {
+		name = ExportFormatJSON
+	}
+
+	format, ok := exportFormats[name]
+	if !ok {
+		http.Error(w, "unsupported export format", http.StatusBadRequest)
+		return
+	}
+
+	unlock := lobby.LockTraced(r.Context(), "HistoryExport")
+	history := lobby.PlayHistory()
+	unlock()
+
+	filename := fmt.Sprintf("testdj-%s-%s.%s", lobby.ID, lobby.Now().UTC().Format("2006-01-02"), format.Extension)
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	if err := format.Write(w, history); err != nil {
+		mustGetLogger(r).Warn("Error writing history export", slog.String("format", name), tint.Err(err))
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/dj"
+)
+
+func TestWriteHistoryCSVFormulas(t *testing.T) {
+	started := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	history := []dj.PlayRecord{{
+		VideoID:       "-abcdefghij",
+		Title:         `=HYPERLINK("http://example.com","click")`,
+		Channel:       "@channel",
+		SubmitterName: "+bob",
+		Duration:      time.Minute,
+		StartedAt:     started,
+		EndedAt:       started.Add(time.Minute),
+	}, {
+		VideoID:       "normalVid01",
+		Title:         "Song - Live",
+		Channel:       "Band",
+		SubmitterName: "alice",
+		StartedAt:     started,
+	}}
+
+	var buf bytes.Buffer
+	if err := writeHistoryCSV(&buf, history); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// video_id, title, channel and submitter_name
+	cells := func(row []string) [4]string { return [4]string{row[2], row[4], row[5], row[8]} }
+
+	want := [4]string{"-abcdefghij", `'=HYPERLINK("http://example.com","click")`, "'@channel", "'+bob"}
+	if got := cells(rows[1]); got != want {
+		t.Errorf("formula row = %q, want %q", got, want)
+	}
+	want = [4]string{"normalVid01", "Song - Live", "Band", "alice"}
+	if got := cells(rows[2]); got != want {
+		t.Errorf("plain row = %q, want %q", got, want)
+	}
+}
+
+func TestWriteHistoryJSON(t *testing.T) {
+	started := time.Date(2025, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	history := []dj.PlayRecord{{
+		VideoID:     "finishedVid",
+		Title:       "Rock &amp; Roll",
+		Duration:    90 * time.Second,
+		StartedAt:   started,
+		EndedAt:     started.Add(90 * time.Second),
+		VoteOutcome: dj.VoteOutcomeNone,
+	}, {
+		VideoID:   "playingVid1",
+		Title:     "Now Playing",
+		StartedAt: started.Add(90 * time.Second),
+	}}
+
+	var buf bytes.Buffer
+	if err := writeHistoryJSON(&buf, history); err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d records, want 2", len(records))
+	}
+
+	finished, playing := records[0], records[1]
+	if finished["endedAt"] != "2025-01-02T02:05:35Z" || finished["startedAt"] != "2025-01-02T02:04:05Z" {
+		t.Errorf("finished times = %v to %v, want UTC", finished["startedAt"], finished["endedAt"])
+	}
+	if finished["title"] != "Rock & Roll" || finished["durationSeconds"] != 90.0 || finished["url"] != "https://www.youtube.com/watch?v=finishedVid" {
+		t.Errorf("finished = %v", finished)
+	}
+	if ended, ok := playing["endedAt"]; !ok || ended != nil {
+		t.Errorf("in progress endedAt = %v (present %v), want null", ended, ok)
+	}
+}
+
+func TestWriteHistoryM3U(t *testing.T) {
+	history := []dj.PlayRecord{{
+		VideoID:  "multiLine01",
+		Title:    "First line\r\n#EXTINF:1,injected\nthird",
+		Duration: 2 * time.Minute,
+	}}
+
+	var buf bytes.Buffer
+	if err := writeHistoryM3U(&buf, history); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "#EXTM3U\n" +
+		"#EXTINF:120,First line  #EXTINF:1,injected third\n" +
+		"https://www.youtube.com/watch?v=multiLine01\n"
+	if buf.String() != want {
+		t.Errorf("playlist =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteHistoryYouTube(t *testing.T) {
+	for _, n := range []int{0, 1, youtubePlaylistChunk, youtubePlaylistChunk + 1, 2*youtubePlaylistChunk + 3} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			history := make([]dj.PlayRecord, n)
+			for i := range history {
+				history[i].VideoID = fmt.Sprintf("video%06d", i)
+			}
+
+			var buf bytes.Buffer
+			if err := writeHistoryYouTube(&buf, history); err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Fields(buf.String())
+			if want := (n + youtubePlaylistChunk - 1) / youtubePlaylistChunk; len(lines) != want {
+				t.Fatalf("%d playlist URLs, want %d", len(lines), want)
+			}
+
+			var ids []string
+			for _, line := range lines {
+				chunk, ok := strings.CutPrefix(line, "https://www.youtube.com/watch_videos?video_ids=")
+				if !ok {
+					t.Fatalf("unexpected line %q", line)
+				}
+				got := strings.Split(chunk, ",")
+				if len(got) > youtubePlaylistChunk {
+					t.Errorf("%d IDs in one URL, want at most %d", len(got), youtubePlaylistChunk)
+				}
+				ids = append(ids, got...)
+			}
+			for i, id := range ids {
+				if id != history[i].VideoID {
+					t.Fatalf("ID %d = %q, want %q in play order", i, id, history[i].VideoID)
+				}
+			}
+			if len(ids) != n {
+				t.Errorf("%d IDs exported, want %d", len(ids), n)
+			}
+		})
+	}
+}
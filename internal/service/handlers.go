@@ -2,22 +2,26 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/lmittmann/tint"
 
+	"github.com/btnmasher/testdj/internal/config"
 	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/metrics"
 	"github.com/btnmasher/testdj/internal/shared"
 	"github.com/btnmasher/testdj/internal/sse"
 	"github.com/btnmasher/testdj/internal/templates"
+	"github.com/btnmasher/testdj/internal/tracing"
 )
 
 const (
@@ -25,10 +29,15 @@ const (
 	ContextLobby   = "lobby"
 	ContextUser    = "user"
 	ContextLogger  = "logger"
+	ContextYouTube = "youtube"
+	ContextConfig  = "config"
 )
 
 const MaxNameLength = 20
 
+// MaxReplayCooldownMinutes caps the replay cooldown a lobby creator can pick.
+const MaxReplayCooldownMinutes = 24 * 60
+
 var ignoredPaths = []string{
 	"/heartbeat",
 	"/logout",
@@ -48,8 +57,27 @@ func setContentTypeHTML(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 }
 
+// wantsJSON reports whether the client asked for the JSON form of a partial
+// instead of the HTML fragment htmx swaps in.
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func respondJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+type toast struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// respondWithToast has htmx show a toast. Messages can carry video titles and
+// channel names, so the trigger is marshalled rather than formatted.
 func respondWithToast(message, kind string, w http.ResponseWriter) {
-	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"toast":{"message":"%s","type":"%s"}}`, message, kind))
+	trigger, _ := json.Marshal(map[string]toast{"toast": {message, kind}})
+	w.Header().Set("HX-Trigger", string(trigger))
 }
 
 func isHTTPS(r *http.Request) bool {
@@ -67,9 +95,15 @@ func isHTTPS(r *http.Request) bool {
 	return false
 }
 
+// InjectLogger puts the logger on the request, tagged with the trace and span
+// IDs when the request is traced.
 func InjectLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := logger
+			if attrs := tracing.LogAttrs(r.Context()); attrs != nil {
+				logger = logger.With(attrs...)
+			}
 			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ContextLogger, logger)))
 		})
 	}
@@ -83,6 +117,18 @@ func InjectManager(manager *dj.LobbyManager) func(next http.Handler) http.Handle
 	}
 }
 
+// InjectRuntime puts the current config and YouTube client on the request,
+// a reload during the request does not change what it sees.
+func InjectRuntime(rt *Runtime) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ContextConfig, rt.Config())
+			ctx = context.WithValue(ctx, ContextYouTube, rt.YouTube())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func InjectSession() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -93,7 +139,7 @@ func InjectSession() func(next http.Handler) http.Handler {
 				panic("manager not found on request context")
 			}
 
-			cookie, _ := r.Cookie("session_id")
+			cookie, _ := r.Cookie(SessionCookieName)
 			var sessionID string
 			if cookie != nil && cookie.Value != "" {
 				sessionID = cookie.Value
@@ -102,18 +148,10 @@ func InjectSession() func(next http.Handler) http.Handler {
 			var user *dj.User
 			if sessionID != "" {
 				var found bool
-				user, found = manager.UsersBySessionID.Get(sessionID)
+				user, found = manager.UserBySession(sessionID)
 				if !found {
-					http.SetCookie(w, &http.Cookie{
-						Name:     "session_id",
-						Value:    "",
-						Path:     "/",
-						HttpOnly: true,
-						Secure:   isHTTPS(r),
-						SameSite: http.SameSiteStrictMode,
-						MaxAge:   -1, // delete immediately
-						Expires:  time.Unix(0, 0),
-					})
+					logger.Debug("Refusing unknown, forged or expired session cookie")
+					clearSessionCookie(w, r)
 					handleErrorRedirect(w, r, "Session Expired")
 					return
 				}
@@ -148,22 +186,19 @@ func InjectSession() func(next http.Handler) http.Handler {
 					http.Error(w, "invalid host", http.StatusBadRequest)
 				}
 
-				// Additionally, ensure one session per IP:
-				globalUser, exists := manager.UsersByIP.Get(ip)
-				if exists && globalUser.ID != user.ID {
-					http.SetCookie(w, &http.Cookie{
-						Name:     "session_id",
-						Value:    "",
-						Path:     "/",
-						HttpOnly: true,
-						Secure:   isHTTPS(r),
-						SameSite: http.SameSiteStrictMode,
-						MaxAge:   -1, // delete immediately
-						Expires:  time.Unix(0, 0),
-					})
-
-					handleErrorRedirect(w, r, "Invalid User")
-					return
+				// Additionally, ensure the IP policy holds:
+				userLobby, _ := manager.GetLobby(user.LobbyID)
+				for _, other := range manager.SharingAddress(ip, userLobby) {
+					if other.ID != user.ID {
+						clearSessionCookie(w, r)
+
+						handleErrorRedirect(w, r, "Invalid User")
+						return
+					}
+				}
+
+				if value, rotated := manager.RotateSession(user); rotated {
+					setSessionCookie(w, r, value, manager.SessionRemaining(user))
 				}
 
 				r = r.WithContext(context.WithValue(r.Context(), ContextUser, user))
@@ -174,6 +209,49 @@ func InjectSession() func(next http.Handler) http.Handler {
 	}
 }
 
+// deviceInLobby reports whether a user the IP policy counts as the client on
+// ip, or self when given, already has the lobby open.
+func deviceInLobby(manager *dj.LobbyManager, lobby *dj.Lobby, ip string, self *dj.User) bool {
+	users := manager.SharingAddress(ip, lobby)
+	if self != nil {
+		users = append(users, self)
+	}
+	for _, u := range users {
+		if lobby.Users.Exists(u.ID) && u.SSE != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionCookieName holds the signed session token.
+const SessionCookieName = "session_id"
+
+func setSessionCookie(w http.ResponseWriter, r *http.Request, value string, maxAge time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   max(1, int(maxAge.Seconds())),
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   -1, // delete immediately
+		Expires:  time.Unix(0, 0),
+	})
+}
+
 func handleErrorRedirect(w http.ResponseWriter, r *http.Request, message string) {
 	logger := mustGetLogger(r)
 
@@ -197,7 +275,8 @@ func handleErrorRedirect(w http.ResponseWriter, r *http.Request, message string)
 
 		logger.Debug("Sending SSE Redirect error", slog.String("message", message))
 
-		fmt.Fprintf(w, "event: toast\ndata: %s\n\n", fmt.Sprintf(`{"toast":{"message":"%s","type":"error"}}`, message))
+		data, _ := json.Marshal(map[string]toast{"toast": {message, "error"}})
+		fmt.Fprintf(w, "event: toast\ndata: %s\n\n", data)
 		fmt.Fprint(w, "event: redirect\ndata: /\n\n")
 
 		err = rc.Flush()
@@ -220,7 +299,7 @@ func WithLobbyAndUser(handler func(lobby *dj.Lobby, user *dj.User, w http.Respon
 		}
 
 		user, ok := r.Context().Value(ContextUser).(*dj.User)
-		if !ok {
+		if !ok || user.LobbyID != lobby.ID {
 			http.Redirect(w, r, fmt.Sprintf("/invite/%s", lobby.ID), http.StatusSeeOther)
 			return
 		}
@@ -263,12 +342,17 @@ func HandleSSE(w http.ResponseWriter, r *http.Request) {
 		Log:     logger.With("userID", user.ID),
 	}
 
+	metrics.SSEConnections.Inc()
+	defer metrics.SSEConnections.Dec()
+
+	// Broadcasts can reach the client as soon as it is set on the user, so
+	// the headers are written under the client lock as well
 	client.Lock()
 	user.SSE = client
-	client.Unlock()
-
+	user.MarkActive(mustGetManager(r).Now())
 	w.WriteHeader(http.StatusOK)
 	err := rc.Flush()
+	client.Unlock()
 	if err != nil {
 		logger.Error("Flush error", tint.Err(err))
 	}
@@ -284,8 +368,10 @@ keepAlive:
 		case <-ctx.Done():
 			break keepAlive
 		case t := <-ticker.C:
+			client.Lock()
 			fmt.Fprintf(w, ": ping %d\n\n", t.Unix())
 			err = rc.Flush()
+			client.Unlock()
 			if err != nil {
 				logger.Error("Flush error", tint.Err(err))
 			}
@@ -316,7 +402,25 @@ func HandleCreateLobby(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if manager.Lobbies.Length() >= manager.MaxLobbies {
+	title := strings.TrimSpace(r.FormValue("title"))
+	if !dj.ValidTitle(title) {
+		respondWithToast(fmt.Sprintf("Name can be at most %d characters", dj.MaxTitleLength), "error", w)
+		http.Error(w, "invalid title", http.StatusBadRequest)
+		return
+	}
+
+	access := r.FormValue("access")
+	if !slices.Contains(dj.AccessModes, access) {
+		access = dj.AccessOpen
+	}
+	password := r.FormValue("password")
+	if access == dj.AccessPassword && !dj.ValidPassword(password) {
+		respondWithToast(fmt.Sprintf("Password must be %d to %d characters", dj.MinPasswordLength, dj.MaxPasswordLength), "error", w)
+		http.Error(w, "invalid password", http.StatusBadRequest)
+		return
+	}
+
+	if manager.Lobbies.Length() >= manager.Settings().MaxLobbies {
 		respondWithToast("Lobby Limit Exceeded", "error", w)
 		http.Error(w, "lobby limit exceeded", http.StatusServiceUnavailable)
 		return
@@ -329,15 +433,15 @@ func HandleCreateLobby(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid host", http.StatusBadRequest)
 	}
 
-	var sessionId string
-	cookie, _ := r.Cookie("session_id")
+	var sessionToken string
+	cookie, _ := r.Cookie(SessionCookieName)
 	if cookie != nil {
-		sessionId = cookie.Value
+		sessionToken = cookie.Value
 	}
 
-	manager.CleanExistingSessions(sessionId, ip)
+	manager.CleanExistingSessions(sessionToken, ip, nil)
 
-	user := manager.NewUser(name, ip)
+	user, token := manager.NewUser(name, ip)
 
 	mode := r.FormValue("mode")
 	if _, exists := dj.ModeDisplayName[strings.ToLower(mode)]; !exists {
@@ -350,18 +454,31 @@ func HandleCreateLobby(w http.ResponseWriter, r *http.Request) {
 		limit = 5
 	}
 
-	lobby := manager.NewLobby(mode, limit, ip)
+	cooldown := dj.DefaultReplayCooldown
+	if minutes, err := strconv.Atoi(r.FormValue("cooldown")); err == nil && minutes >= 0 && minutes <= MaxReplayCooldownMinutes {
+		cooldown = time.Duration(minutes) * time.Minute
+	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    user.SessionID,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   isHTTPS(r),
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   28800,
+	errorShare := dj.DefaultPlayerErrorShare
+	if percent, err := strconv.Atoi(r.FormValue("error_share")); err == nil && percent > 0 && percent <= 100 {
+		errorShare = float64(percent) / 100
+	}
+
+	lobby := manager.NewLobby(dj.LobbyOptions{
+		Title:            title,
+		Mode:             mode,
+		UserQueueLimit:   limit,
+		CreatorIP:        ip,
+		CreatorID:        user.ID,
+		ReplayCooldown:   cooldown,
+		CooldownScope:    r.FormValue("cooldown_scope"),
+		PlayerErrorShare: errorShare,
+		Access:           access,
+		Password:         password,
 	})
 
+	setSessionCookie(w, r, token, manager.SessionRemaining(user))
+
 	lobby.AddUser(user)
 
 	http.Redirect(w, r, fmt.Sprintf("/lobby/%s", lobby.ID), http.StatusSeeOther)
@@ -371,21 +488,52 @@ func mustGetLogger(r *http.Request) *slog.Logger {
 	logger, ok := r.Context().Value(ContextLogger).(*slog.Logger)
 	if !ok {
 		panic("logger not found on request context")
-		return nil
 	}
 
 	return logger
 }
 
+func mustGetManager(r *http.Request) *dj.LobbyManager {
+	manager, ok := r.Context().Value(ContextManager).(*dj.LobbyManager)
+	if !ok {
+		panic("manager not found on request context")
+	}
+
+	return manager
+}
+
+func mustGetConfig(r *http.Request) *config.Config {
+	cfg, ok := r.Context().Value(ContextConfig).(*config.Config)
+	if !ok {
+		panic("config not found on request context")
+	}
+
+	return cfg
+}
+
+func mustGetYouTube(r *http.Request) *YouTubeClient {
+	yt, ok := r.Context().Value(ContextYouTube).(*YouTubeClient)
+	if !ok {
+		panic("youtube client not found on request context")
+	}
+
+	return yt
+}
+
 func HandleInviteLink(w http.ResponseWriter, r *http.Request) {
+	var prompt templates.JoinPrompt
 	lobby, ok := r.Context().Value(ContextLobby).(*dj.Lobby)
 	if ok && lobby != nil {
+		prompt.Title = lobby.DisplayTitle()
+		prompt.Access = lobby.Access()
+		prompt.Invite = r.URL.Query().Get("token")
+
 		lobby.Lock()
 		defer lobby.Unlock()
 	}
 
 	setContentTypeHTML(w)
-	templates.JoinLobbyPage(lobby).Render(r.Context(), w)
+	templates.JoinLobbyPage(lobby, prompt).Render(r.Context(), w)
 }
 
 func HandleJoinLobby(w http.ResponseWriter, r *http.Request) {
@@ -413,7 +561,7 @@ func HandleJoinLobby(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		// Will render an invalid lobby error page
 		setContentTypeHTML(w)
-		templates.JoinLobbyPage(nil).Render(r.Context(), w)
+		templates.JoinLobbyPage(nil, templates.JoinPrompt{}).Render(r.Context(), w)
 		return
 	}
 
@@ -424,6 +572,12 @@ func HandleJoinLobby(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := lobby.CheckAccess(r.FormValue("password"), r.FormValue("invite")); err != nil {
+		logger.Info("Refusing to join private lobby", slog.String("reason", err.Error()), lobby.Log())
+		refuseJoin(w, r, lobby, err)
+		return
+	}
+
 	ip, ipErr := shared.ParseHost(r.RemoteAddr)
 	if ipErr != nil {
 		logger.Warn("Error parsing host", tint.Err(ipErr))
@@ -431,87 +585,63 @@ func HandleJoinLobby(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid host", http.StatusBadRequest)
 	}
 
-	var sessionId string
-	cookie, _ := r.Cookie("session_id")
+	var sessionToken string
+	cookie, _ := r.Cookie(SessionCookieName)
 	if cookie != nil {
-		sessionId = cookie.Value
+		sessionToken = cookie.Value
 	}
 
-	if u, exists := manager.UsersByIP.Get(ip); exists {
-		if lobby.UsersBySession.Exists(u.SessionID) && u.SSE != nil {
-			setContentTypeHTML(w)
-			templates.ErrorPage(
-				"Multiple Device Error",
-				"You are only allowed to join on one device at a time from the same address.").
-				Render(r.Context(), w)
-			return
-		}
+	if deviceInLobby(manager, lobby, ip, nil) {
+		setContentTypeHTML(w)
+		templates.ErrorPage(
+			"Multiple Device Error",
+			"You are only allowed to join on one device at a time from the same address.").
+			Render(r.Context(), w)
+		return
 	}
 
-	manager.CleanExistingSessions(sessionId, ip)
+	manager.CleanExistingSessions(sessionToken, ip, lobby)
 
-	user := manager.NewUser(name, ip)
+	user, token := manager.NewUser(name, ip)
 	lobby.AddUser(user)
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    user.SessionID,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   isHTTPS(r),
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   28800,
-	})
+	setSessionCookie(w, r, token, manager.SessionRemaining(user))
 
 	lobby.Touch()
 	http.Redirect(w, r, fmt.Sprintf("/lobby/%s", lobby.ID), http.StatusSeeOther)
 }
 
-func HandleHeartbeat(_ *dj.Lobby, user *dj.User, w http.ResponseWriter, _ *http.Request) {
-	user.LastActivity = time.Now()
-	w.WriteHeader(http.StatusNoContent)
-	return
-}
-
-func HandleLogout(_ *dj.Lobby, user *dj.User, w http.ResponseWriter, _ *http.Request) {
-	user.LastActivity = time.Now().Add(-25 * time.Second)
+func HandleHeartbeat(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, _ *http.Request) {
+	user.MarkActive(lobby.Now())
 	w.WriteHeader(http.StatusNoContent)
 	return
 }
 
-func HandleLogoutOLD(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   isHTTPS(r),
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   -1, // delete immediately
-		Expires:  time.Unix(0, 0),
-	})
-
-	defer w.WriteHeader(http.StatusOK)
-
-	manager, ok := r.Context().Value(ContextManager).(*dj.LobbyManager)
-	if !ok {
+// LeaveGrace is how long a user whose tab sent the close beacon stays in the
+// lobby. It outlasts the page heartbeat, so a reload or another open tab
+// keeps the session going.
+const LeaveGrace = 35 * time.Second
+
+// HandleLogout takes the user out of the lobby and ends their session. The
+// beacon sent when a tab closes can't tell a close from a reload or a back
+// button, so it only lets the session lapse on the next cleanup after
+// LeaveGrace, unless a heartbeat or page load comes first.
+func HandleLogout(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("reason") == "tab_close" {
+		user.BackdateActivity(lobby.Now().Add(LeaveGrace - lobby.Manager.Settings().UserIdleTimeout))
+		mustGetLogger(r).Debug("Tab closed, user leaves unless they come back", user.Log())
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	user, ok := r.Context().Value(ContextUser).(*dj.User)
-	if !ok {
-		return
-	}
+	mustGetLogger(r).Debug("User logged out, ending session", user.Log())
 
-	if user != nil {
-		if lobby, exists := manager.Lobbies.Get(user.LobbyID); exists {
-			lobby.RemoveUser(user)
-			manager.UsersBySessionID.Delete(user.SessionID)
-			manager.UsersByIP.Delete(user.IP)
-		}
-	}
+	lobby.RemoveUser(user)
+	lobby.Manager.EndSession(user)
+	lobby.Manager.Users.Delete(user.ID)
 
-	return
+	clearSessionCookie(w, r)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func HandleLobbyPage(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
@@ -523,27 +653,24 @@ func HandleLobbyPage(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *h
 
 	setContentTypeHTML(w)
 
-	lobby.Lock()
-	defer lobby.Unlock()
-
-	if u, exists := manager.UsersByIP.Get(user.IP); exists {
-		if lobby.UsersBySession.Exists(u.SessionID) && u.SSE != nil {
-			templates.ErrorPage(
-				"Multiple Device Error",
-				"You are only allowed to join on one device at a time from the same address.").
-				Render(r.Context(), w)
-			return
-		}
+	defer lobby.LockTraced(r.Context(), "LobbyPage")()
+
+	if deviceInLobby(manager, lobby, user.IP, user) {
+		templates.ErrorPage(
+			"Multiple Device Error",
+			"You are only allowed to join on one device at a time from the same address.").
+			Render(r.Context(), w)
+		return
 	}
 
 	lobby.Touch()
 
-	user.LastActivity = time.Now()
+	user.MarkActive(lobby.Now())
 	templates.LobbyPage(lobby, user).Render(r.Context(), w)
 }
 
 func HandleAddVideo(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
-	exp := user.MutedUntil.Sub(time.Now())
+	exp := user.MutedUntil.Sub(lobby.Now())
 	if exp > 0 {
 		respondWithToast(fmt.Sprintf("You are muted for the next %v.", exp.Round(time.Second)), "error", w)
 		http.Error(w, "user muted", http.StatusForbidden)
@@ -557,20 +684,22 @@ func HandleAddVideo(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *ht
 		return
 	}
 
-	var opt FetchOption
-	useScrape, set := os.LookupEnv("USE_SCRAPE")
-	if !set || useScrape == "true" { // default true to scrape
-		opt = opt.Set(UseScrapeFetch)
-	}
-
-	title, dur, err := fetchVideoMeta(r.Context(), videoId, opt.Set(UseDataAPI))
+	meta, err := mustGetYouTube(r).fetchVideoMeta(r.Context(), videoId)
 	if err != nil {
 		if errors.Is(err, ErrAgeRestircted) {
+			metrics.AgeRestrictedRejections.Inc()
 			respondWithToast("Cannot add age restricted video", "error", w)
 			http.Error(w, "cannot add age restricted video", http.StatusForbidden)
 			return
 		}
 
+		var unplayable *UnplayableError
+		if errors.As(err, &unplayable) {
+			respondWithToast(PlayabilityDisplayReason[unplayable.Playability], "error", w)
+			http.Error(w, unplayable.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
 		if logger, exists := r.Context().Value(ContextLogger).(*slog.Logger); exists {
 			logger.Error("Error fetching video metadata for video", slog.String("videoId", videoId), tint.Err(err))
 		}
@@ -579,18 +708,25 @@ func HandleAddVideo(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *ht
 		return
 	}
 
-	if dur > time.Minute*10 {
-		respondWithToast("Videos longer than 10 minutes are not allowed", "error", w)
-		http.Error(w, "video too long", http.StatusBadRequest)
+	policy := lobby.ContentPolicy()
+	if violation := policy.Evaluate(meta.PolicyCandidate()); violation != nil {
+		respondWithToast(violation.Reason, "error", w)
+		http.Error(w, violation.Error(), http.StatusForbidden)
 		return
 	}
 
-	if lobby.PlayedVideos.Exists(videoId) {
-		respondWithToast("Video already played in last hour", "error", w)
+	if remaining, blocked := lobby.ReplayCooldown.Remaining(videoId, user.ID, lobby.Now()); blocked {
+		respondWithToast(fmt.Sprintf("Video was played recently, it can be added again in %v", remaining.Round(time.Minute)), "error", w)
 		http.Error(w, "duplicate", http.StatusConflict)
 		return
 	}
 
+	if lobby.CheckVideoFailed(videoId) {
+		respondWithToast("This video failed to play earlier and can't be added again", "error", w)
+		http.Error(w, "video failed", http.StatusUnprocessableEntity)
+		return
+	}
+
 	if lobby.CheckVideoQueued(videoId) {
 		respondWithToast("Video already in queue", "error", w)
 		http.Error(w, "already queued", http.StatusConflict)
@@ -603,13 +739,18 @@ func HandleAddVideo(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *ht
 		return
 	}
 
-	lobby.AddVideo(&dj.Video{
+	lobby.AddVideo(r.Context(), &dj.Video{
 		ID:            videoId,
-		Title:         title,
-		URL:           fmt.Sprintf("https://www.youtube.com/embed/%s?autoplay=1", videoId),
+		Title:         meta.Title,
+		Channel:       meta.Channel,
+		ChannelID:     meta.ChannelID,
+		ThumbnailURL:  meta.ThumbnailURL,
+		PublishedAt:   meta.PublishedAt,
+		ViewCount:     meta.ViewCount,
+		URL:           fmt.Sprintf("https://www.youtube.com/embed/%s?autoplay=1&enablejsapi=1", videoId),
 		SubmitterID:   user.ID,
 		SubmitterName: user.Name,
-		Duration:      dur,
+		Duration:      meta.Duration,
 	})
 
 	respondWithToast("Video added!", "success", w)
@@ -617,8 +758,7 @@ func HandleAddVideo(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *ht
 }
 
 func HandleLobbyUsers(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
-	lobby.Lock()
-	defer lobby.Unlock()
+	defer lobby.LockTraced(r.Context(), "LobbyUsers")()
 
 	setContentTypeHTML(w)
 	templates.UsersPartial(lobby, user).Render(r.Context(), w)
@@ -632,8 +772,12 @@ func HandleLobbyPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lobby.Lock()
-	defer lobby.Unlock()
+	defer lobby.LockTraced(r.Context(), "LobbyPlaylist")()
+
+	if wantsJSON(r) {
+		respondJSON(w, map[string][]*dj.Video{"videos": lobby.Videos})
+		return
+	}
 
 	setContentTypeHTML(w)
 	templates.PlaylistPartial(lobby).Render(r.Context(), w)
@@ -647,8 +791,13 @@ func HandleLobbyHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lobby.Lock()
-	defer lobby.Unlock()
+	defer lobby.LockTraced(r.Context(), "LobbyHistory")()
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		writeHistoryJSON(w, lobby.PlayHistory())
+		return
+	}
 
 	setContentTypeHTML(w)
 	templates.HistoryPartial(lobby).Render(r.Context(), w)
@@ -662,16 +811,22 @@ func HandleLobbyVideo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lobby.Lock()
-	defer lobby.Unlock()
+	defer lobby.LockTraced(r.Context(), "LobbyVideo")()
+
+	if wantsJSON(r) {
+		respondJSON(w, struct {
+			Video     *dj.Video `json:"video"`
+			StartedAt time.Time `json:"startedAt,omitzero"`
+		}{lobby.CurrentVideo, lobby.VideoStart})
+		return
+	}
 
 	setContentTypeHTML(w)
 	templates.VideoPartial(lobby).Render(r.Context(), w)
 }
 
 func HandleLobbyVotes(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
-	lobby.Lock()
-	defer lobby.Unlock()
+	defer lobby.LockTraced(r.Context(), "LobbyVotes")()
 
 	setContentTypeHTML(w)
 	templates.VotesPartial(lobby, user).Render(r.Context(), w)
@@ -696,8 +851,8 @@ func HandleVoteMuteStart(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter,
 	}
 	lobby.Unlock()
 
-	if cd, ok := lobby.MuteCooldownsByIP.Get(user.IP); ok {
-		if time.Now().Before(cd) {
+	if cd, ok := lobby.MuteCooldowns.Get(lobby.MuteKey(user)); ok {
+		if lobby.Now().Before(cd) {
 			respondWithToast("You are on cooldown to start a mute vote", "error", w)
 			http.Error(w, "Cooldown active", http.StatusForbidden)
 			return
@@ -783,3 +938,57 @@ func HandleVoteSkipSubmit(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter,
 
 	w.WriteHeader(http.StatusCreated)
 }
+
+func HandleReact(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	reaction := r.FormValue("reaction")
+	if _, ok := dj.ReactionEmoji[reaction]; !ok {
+		respondWithToast("Invalid reaction", "error", w)
+		http.Error(w, "invalid reaction", http.StatusBadRequest)
+		return
+	}
+
+	if !lobby.AddReaction(user, r.FormValue("video"), reaction) {
+		http.Error(w, "video no longer playing", http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func HandlePlayerError(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	code, err := strconv.Atoi(r.FormValue("code"))
+	if err != nil {
+		http.Error(w, "invalid error code", http.StatusBadRequest)
+		return
+	}
+
+	videoId := r.FormValue("video")
+	recorded, quorum := lobby.ReportPlaybackError(user, videoId, code)
+	if !recorded {
+		// Not fatal, or the video already changed
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if quorum {
+		lobby.FailVideo(videoId, code, confirmUnplayable(r, videoId))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// confirmUnplayable looks the video up again to check the player errors
+// reported by clients. A failed lookup confirms nothing.
+func confirmUnplayable(r *http.Request, videoId string) bool {
+	meta, err := mustGetYouTube(r).fetchVideoMeta(r.Context(), videoId)
+
+	var unplayable *UnplayableError
+	if errors.As(err, &unplayable) {
+		return true
+	}
+	if err != nil {
+		mustGetLogger(r).Warn("Could not check video after player errors", slog.String("videoId", videoId), tint.Err(err))
+		return false
+	}
+	return meta.Playability == PlayabilityEmbedDisabled || meta.RegionBlocked
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/clock"
+	"github.com/btnmasher/testdj/internal/config"
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/shared"
+	"github.com/btnmasher/testdj/internal/ytfake"
+)
+
+var testEpoch = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+const testSessionKey = "test-session-key-0123456789abcdef"
+
+// How long to wait for an SSE event before failing the test.
+const eventTimeout = 2 * time.Second
+
+// testApp is the full router served over HTTP, with a fake clock driving the
+// lobby timers and a fake YouTube behind the video lookups.
+type testApp struct {
+	*httptest.Server
+	t       *testing.T
+	Runtime *Runtime
+	Manager *dj.LobbyManager
+	Clock   *clock.Fake
+	YouTube *ytfake.Server
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+
+	yt, fake := newFakeYouTube(t, UseScrapeFetch|UseDataAPI)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cfg := config.Default()
+	cfg.Server.ClientIPHeader = shared.ClientIPForwardedFor
+	cfg.Server.TrustedProxies = []string{"127.0.0.1", "::1"}
+	clk := clock.NewFake(testEpoch)
+	manager := dj.NewLobbyManager(ctx, logger, dj.ManagerOptions{
+		Settings:   cfg.ManagerSettings(),
+		Clock:      clk,
+		Rand:       dj.NewRand(1),
+		SessionKey: []byte(testSessionKey),
+	})
+
+	static := fstest.MapFS{"css/style.css": {Data: []byte("body{}")}}
+	rt := NewRuntime(cfg, yt, manager, logger)
+	srv := httptest.NewServer(NewRouter(rt, static))
+	t.Cleanup(srv.Close)
+
+	return &testApp{
+		Server:  srv,
+		t:       t,
+		Runtime: rt,
+		Manager: manager,
+		Clock:   clk,
+		YouTube: fake,
+	}
+}
+
+// ipTransport tags every request with a client address, which RealIP trusts
+// from the loopback test server, so each test client looks like a different
+// machine.
+type ipTransport struct {
+	ip   string
+	next http.RoundTripper
+}
+
+func (t *ipTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("X-Forwarded-For", t.ip)
+	return t.next.RoundTrip(r)
+}
+
+// testClient is a browser with its own cookie jar and address.
+type testClient struct {
+	app  *testApp
+	IP   string
+	HTTP *http.Client
+}
+
+func (a *testApp) newClient(ip string) *testClient {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		a.t.Fatalf("cookiejar: %v", err)
+	}
+
+	return &testClient{
+		app: a,
+		IP:  ip,
+		HTTP: &http.Client{
+			Jar:       jar,
+			Transport: &ipTransport{ip: ip, next: a.Client().Transport},
+			// Redirects are asserted on, not followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (c *testClient) do(method, path string, form url.Values) *http.Response {
+	c.app.t.Helper()
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, c.app.URL+path, body)
+	if err != nil {
+		c.app.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		c.app.t.Fatalf("%s %s: %v", method, path, err)
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func (c *testClient) get(path string) *http.Response {
+	c.app.t.Helper()
+	return c.do(http.MethodGet, path, nil)
+}
+
+func (c *testClient) post(path string, form url.Values) *http.Response {
+	c.app.t.Helper()
+	if form == nil {
+		form = url.Values{}
+	}
+	return c.do(http.MethodPost, path, form)
+}
+
+// sessionCookie returns the session cookie held in the jar, or "".
+func (c *testClient) sessionCookie() string {
+	u, _ := url.Parse(c.app.URL)
+	for _, cookie := range c.HTTP.Jar.Cookies(u) {
+		if cookie.Name == "session_id" {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func lobbyIDFromRedirect(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	loc := resp.Header.Get("Location")
+	id, ok := strings.CutPrefix(loc, "/lobby/")
+	if resp.StatusCode != http.StatusSeeOther || !ok || id == "" {
+		t.Fatalf("got %d to %q, want a redirect to a lobby", resp.StatusCode, loc)
+	}
+	return id
+}
+
+// createLobby creates a lobby as this client and returns its ID.
+func (c *testClient) createLobby(name string, opts url.Values) string {
+	c.app.t.Helper()
+
+	form := url.Values{"name": {name}}
+	for k, v := range opts {
+		form[k] = v
+	}
+	return lobbyIDFromRedirect(c.app.t, c.post("/create", form))
+}
+
+func (c *testClient) join(lobbyID, name string) {
+	c.app.t.Helper()
+	lobbyIDFromRedirect(c.app.t, c.post("/join/"+lobbyID, url.Values{"name": {name}}))
+}
+
+type sseEvent struct {
+	Name string
+	Data string
+}
+
+// sseStream is an open event source, events are read in the background.
+type sseStream struct {
+	t      *testing.T
+	events chan sseEvent
+}
+
+// openSSE connects the client's event source. The user is attached to the
+// lobby by the time it returns.
+func (c *testClient) openSSE(lobbyID string) *sseStream {
+	c.app.t.Helper()
+	return c.openStream("/sse/" + lobbyID)
+}
+
+// openStream connects to the event stream at path.
+func (c *testClient) openStream(path string) *sseStream {
+	c.app.t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.app.t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.app.URL+path, nil)
+	if err != nil {
+		c.app.t.Fatalf("sse request: %v", err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		c.app.t.Fatalf("sse connect: %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		resp.Body.Close()
+		c.app.t.Fatalf("sse connect: got %d %q", resp.StatusCode, ct)
+	}
+
+	s := &sseStream{t: c.app.t, events: make(chan sseEvent, 64)}
+	go s.read(resp.Body)
+	return s
+}
+
+func (s *sseStream) read(body io.ReadCloser) {
+	defer body.Close()
+	defer close(s.events)
+
+	var ev sseEvent
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if ev.Name != "" {
+				s.events <- ev
+			}
+			ev = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			ev.Name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// waitFor skips events until one with the name arrives.
+func (s *sseStream) waitFor(name string) sseEvent {
+	s.t.Helper()
+
+	timeout := time.After(eventTimeout)
+	for {
+		select {
+		case ev, ok := <-s.events:
+			if !ok {
+				s.t.Fatalf("stream closed waiting for %q", name)
+			}
+			if ev.Name == name {
+				return ev
+			}
+		case <-timeout:
+			s.t.Fatalf("timed out waiting for %q", name)
+		}
+	}
+}
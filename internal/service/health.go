@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lmittmann/tint"
+)
+
+// How often readiness checks that metadata can be fetched, and how long the
+// check may take.
+const (
+	FetchProbeInterval = time.Minute
+	FetchProbeTimeout  = 5 * time.Second
+)
+
+// Readiness statuses. A degraded server still serves its lobbies.
+const (
+	StatusReady    = "ready"
+	StatusDegraded = "degraded"
+	StatusNotReady = "not_ready"
+	StatusDraining = "draining"
+)
+
+// fetchProbe caches the outcome of probing the YouTube client, so readiness
+// checks do not hit YouTube on every call.
+type fetchProbe struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	path      string
+	err       error
+}
+
+func (p *fetchProbe) check(ctx context.Context, yt *YouTubeClient, logger *slog.Logger) (path string, checkedAt time.Time, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.checkedAt.IsZero() || time.Since(p.checkedAt) >= FetchProbeInterval {
+		// The outcome is shared, so one caller giving up must not cut it short
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), FetchProbeTimeout)
+		p.path, p.err = yt.probe(ctx)
+		cancel()
+		p.checkedAt = time.Now()
+
+		if p.err != nil {
+			logger.Warn("Metadata fetch probe failed", slog.String("path", p.path), tint.Err(p.err))
+		}
+	}
+
+	return p.path, p.checkedAt, p.err
+}
+
+// reset makes the next check probe again.
+func (p *fetchProbe) reset() {
+	p.mu.Lock()
+	p.checkedAt = time.Time{}
+	p.mu.Unlock()
+}
+
+type readyCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type readiness struct {
+	Status string                `json:"status"`
+	Checks map[string]readyCheck `json:"checks"`
+}
+
+// HandleHealthz answers as long as the process serves requests.
+func HandleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// HandleReadyz reports whether the server can take traffic. It answers 503
+// while draining for shutdown or once the lobby manager has stopped. Full
+// capacity or failing metadata lookups only degrade it, since the open
+// lobbies keep working.
+func HandleReadyz(rt *Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := readiness{Status: StatusReady, Checks: map[string]readyCheck{}}
+
+		running := rt.Manager.Running()
+		resp.Checks["manager"] = readyCheck{OK: running}
+		if !running {
+			resp.Status = StatusNotReady
+		}
+
+		lobbies, _ := rt.Manager.Counts()
+		maxLobbies := rt.Manager.Settings().MaxLobbies
+		resp.Checks["capacity"] = readyCheck{
+			OK:     lobbies < maxLobbies,
+			Detail: fmt.Sprintf("%d of %d lobbies", lobbies, maxLobbies),
+		}
+
+		// The probe error is logged, not served, as it can carry upstream URLs
+		path, checkedAt, err := rt.probe.check(r.Context(), rt.YouTube(), mustGetLogger(r))
+		metadata := readyCheck{OK: err == nil, Detail: fmt.Sprintf("%s checked %v ago", path, time.Since(checkedAt).Round(time.Second))}
+		if err != nil {
+			metadata.Detail = fmt.Sprintf("%s failed %v ago", path, time.Since(checkedAt).Round(time.Second))
+		}
+		resp.Checks["metadata"] = metadata
+
+		if resp.Status == StatusReady && (!resp.Checks["capacity"].OK || !metadata.OK) {
+			resp.Status = StatusDegraded
+		}
+		if rt.Draining() {
+			resp.Status = StatusDraining
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		if resp.Status == StatusNotReady || resp.Status == StatusDraining {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
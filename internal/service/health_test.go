@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/btnmasher/testdj/internal/config"
+	"github.com/btnmasher/testdj/internal/ytfake"
+)
+
+func (a *testApp) readyz() (int, readiness) {
+	a.t.Helper()
+
+	resp, err := a.Client().Get(a.URL + "/readyz")
+	if err != nil {
+		a.t.Fatalf("GET /readyz: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body readiness
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		a.t.Fatalf("decode /readyz: %v", err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestHealthz(t *testing.T) {
+	app := newTestApp(t)
+
+	resp, err := app.Client().Get(app.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	app := newTestApp(t)
+
+	status, body := app.readyz()
+	if status != http.StatusOK || body.Status != StatusReady {
+		t.Fatalf("got %d %+v, want ready", status, body)
+	}
+	for _, name := range []string{"manager", "capacity", "metadata"} {
+		if !body.Checks[name].OK {
+			t.Errorf("%s check failed: %+v", name, body.Checks[name])
+		}
+	}
+
+	// The metadata probe is cached
+	app.readyz()
+	if n := app.YouTube.Requests(ytfake.SWDataPath); n != 1 {
+		t.Errorf("sw.js_data requested %d times, want 1", n)
+	}
+
+	// A full server still serves its lobbies
+	settings := app.Manager.Settings()
+	settings.MaxLobbies = 1
+	app.Manager.SetSettings(settings)
+	app.newClient("192.0.2.1").createLobby("alice", nil)
+
+	status, body = app.readyz()
+	if status != http.StatusOK || body.Status != StatusDegraded || body.Checks["capacity"].OK {
+		t.Errorf("at capacity: got %d %+v, want degraded", status, body)
+	}
+
+	app.Runtime.Drain()
+	if status, body = app.readyz(); status != http.StatusServiceUnavailable || body.Status != StatusDraining {
+		t.Errorf("draining: got %d %q, want 503 draining", status, body.Status)
+	}
+}
+
+func TestReadyzMetadataDown(t *testing.T) {
+	app := newTestApp(t)
+	app.YouTube.Close()
+
+	status, body := app.readyz()
+	if status != http.StatusOK || body.Status != StatusDegraded || body.Checks["metadata"].OK {
+		t.Errorf("got %d %+v, want degraded on metadata", status, body)
+	}
+
+	// The Data API request carries the key, failures must not hand it out
+	yt := *app.Runtime.YouTube()
+	yt.Fetch = UseDataAPI
+	app.Runtime.youtube.Store(&yt)
+	app.Runtime.probe.reset()
+
+	_, body = app.readyz()
+	if detail := body.Checks["metadata"].Detail; body.Checks["metadata"].OK || strings.Contains(detail, yt.APIKey) || strings.Contains(detail, "key=") {
+		t.Errorf("metadata detail %q, want a failure without the request URL", detail)
+	}
+}
+
+func TestRedactURLError(t *testing.T) {
+	yt, fake := newFakeYouTube(t, UseDataAPI)
+	fake.Close()
+
+	_, err := yt.fetchVideoMetaDataAPI(context.Background(), ytfake.VideoNormal)
+	if err == nil || strings.Contains(err.Error(), yt.APIKey) {
+		t.Fatalf("err = %v, want a failure without the API key", err)
+	}
+	if !strings.Contains(err.Error(), "key="+config.Redacted) {
+		t.Errorf("err = %v, want the key redacted in place", err)
+	}
+
+	if err := yt.getJSON(context.Background(), fake.URL+"/youtube/v3/search?key=test-key", nil); err == nil || strings.Contains(err.Error(), yt.APIKey) {
+		t.Errorf("getJSON err = %v, want a failure without the API key", err)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/metrics"
+	"github.com/btnmasher/testdj/internal/ytfake"
+)
+
+// counter reads a metric now and returns a func reporting how much it grew.
+func counter(c prometheus.Collector) func() float64 {
+	start := testutil.ToFloat64(c)
+	return func() float64 { return testutil.ToFloat64(c) - start }
+}
+
+func TestMetricsInstrumentation(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	bob := app.newClient("192.0.2.2")
+
+	queued := counter(metrics.VideosQueued)
+	played := counter(metrics.VideosPlayed)
+	ageRestricted := counter(metrics.AgeRestrictedRejections)
+	skipStarts := counter(metrics.VoteStarts.WithLabelValues(metrics.VoteSkip))
+	skipPassed := counter(metrics.VoteOutcomes.WithLabelValues(metrics.VoteSkip, metrics.VotePassed))
+	scrapeErrors := counter(metrics.FetchErrors.WithLabelValues(metrics.FetchScrape))
+	sse := counter(metrics.SSEConnections)
+
+	id := alice.createLobby("alice", nil)
+	bob.join(id, "bob")
+	events := alice.openSSE(id)
+
+	if sse() != 1 {
+		t.Errorf("sse connections grew by %v, want 1", sse())
+	}
+
+	alice.post("/lobby/"+id+"/add", url.Values{"url": {"https://youtu.be/" + ytfake.VideoNormal}})
+	events.waitFor(dj.UpdateVideo)
+	bob.post("/lobby/"+id+"/add", url.Values{"url": {"https://youtu.be/" + ytfake.VideoAgeRestricted}})
+
+	alice.post("/lobby/"+id+"/vote/skip/start", nil)
+	bob.post("/lobby/"+id+"/vote/skip/submit", url.Values{"vote": {"yes"}})
+	events.waitFor(dj.UpdateVoteSkipEnd)
+
+	for name, got := range map[string]float64{
+		"queued":         queued(),
+		"played":         played(),
+		"age restricted": ageRestricted(),
+		"skip starts":    skipStarts(),
+		"skip passed":    skipPassed(),
+	} {
+		if got != 1 {
+			t.Errorf("%s grew by %v, want 1", name, got)
+		}
+	}
+	if scrapeErrors() != 0 {
+		t.Error("an age restricted video was counted as a fetch error")
+	}
+
+	lobbies, users := app.Manager.Counts()
+	if lobbies != 1 || users != 2 {
+		t.Errorf("Counts = %d lobbies, %d users, want 1 and 2", lobbies, users)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	for _, name := range []string{"testdj_videos_queued_total", "testdj_sse_connections", "go_goroutines"} {
+		if !strings.Contains(rec.Body.String(), name) {
+			t.Errorf("%s missing from the exposition", name)
+		}
+	}
+}
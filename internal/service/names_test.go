@@ -0,0 +1,79 @@
+package service
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/btnmasher/testdj/internal/dj"
+)
+
+func TestLobbyTitle(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+
+	long := alice.post("/create", url.Values{"name": {"alice"}, "title": {strings.Repeat("x", dj.MaxTitleLength+1)}})
+	if long.StatusCode != http.StatusBadRequest {
+		t.Errorf("long title: status %d, want 400", long.StatusCode)
+	}
+	id := alice.createLobby("alice", url.Values{"title": {"Friday Jams"}})
+	events := alice.openSSE(id)
+
+	bob := app.newClient("192.0.2.2")
+	if _, body := bob.page("/invite/" + id); !strings.Contains(body, "Joining Friday Jams") {
+		t.Error("join page does not show the title")
+	}
+	bob.join(id, "bob")
+	if resp := bob.post("/lobby/"+id+"/name", url.Values{"title": {"Bob's"}}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("rename by a guest: status %d, want 403", resp.StatusCode)
+	}
+
+	if resp := alice.post("/lobby/"+id+"/name", url.Values{"title": {"Fish & Chips"}}); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("rename: status %d", resp.StatusCode)
+	}
+	if ev := events.waitFor(dj.UpdateTitle); ev.Data != html.EscapeString("Fish & Chips") {
+		t.Errorf("title update %q, want it escaped", ev.Data)
+	}
+}
+
+func TestLobbySlug(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+
+	for _, slug := range []string{"no", "admin", "friday jams", "-friday-"} {
+		if resp := alice.post("/lobby/"+id+"/name", url.Values{"slug": {slug}}); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("slug %q: status %d, want 400", slug, resp.StatusCode)
+		}
+	}
+	if resp := alice.post("/lobby/"+id+"/name", url.Values{"slug": {"Friday-Jams"}}); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("claiming a slug: status %d", resp.StatusCode)
+	}
+
+	if resp := alice.get("/lobby/friday-jams/users"); resp.StatusCode != http.StatusOK {
+		t.Errorf("lobby by slug: status %d", resp.StatusCode)
+	}
+	bob := app.newClient("192.0.2.2")
+	if resp := bob.post("/join", url.Values{"code": {"Friday-Jams"}, "name": {"bob"}}); resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("join by slug: status %d, want a redirect", resp.StatusCode)
+	}
+	if app.Manager.Lobbies.Length() != 1 || app.lobby(id).Users.Length() != 2 {
+		t.Error("joining by slug did not land in the lobby")
+	}
+
+	carol := app.newClient("192.0.2.3")
+	other := carol.createLobby("carol", nil)
+	if resp := carol.post("/lobby/"+other+"/name", url.Values{"slug": {"friday-jams"}}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("taken slug: status %d, want 409", resp.StatusCode)
+	}
+
+	app.lobby(id).Expire()
+	if _, ok := app.Manager.GetLobby("friday-jams"); ok {
+		t.Error("slug still resolves after the lobby expired")
+	}
+	if resp := carol.post("/lobby/"+other+"/name", url.Values{"slug": {"friday-jams"}}); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("released slug: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/templates"
+)
+
+const (
+	MaxPolicyListEntries = 50
+	MaxPolicyEntryLength = 100
+)
+
+// parsePolicyList splits a textarea on newlines and commas, dropping blanks
+// and duplicates.
+func parsePolicyList(raw string) ([]string, bool) {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ','
+	})
+
+	list := make([]string, 0, len(fields))
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		key := strings.ToLower(f)
+		if f == "" || seen[key] {
+			continue
+		}
+		if len(f) > MaxPolicyEntryLength {
+			return nil, false
+		}
+		seen[key] = true
+		list = append(list, f)
+	}
+
+	return list, len(list) <= MaxPolicyListEntries
+}
+
+func parsePolicyMinutes(raw string) (time.Duration, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, true
+	}
+
+	minutes, err := strconv.Atoi(raw)
+	if err != nil || minutes < 0 || minutes > dj.MaxPolicyDurationMinutes {
+		return 0, false
+	}
+
+	return time.Duration(minutes) * time.Minute, true
+}
+
+func parseContentPolicy(r *http.Request) (dj.ContentPolicy, string) {
+	var policy dj.ContentPolicy
+	var ok bool
+
+	if policy.AllowChannels, ok = parsePolicyList(r.FormValue("allow_channels")); !ok {
+		return policy, "Too many allowed channels"
+	}
+	if policy.DenyChannels, ok = parsePolicyList(r.FormValue("deny_channels")); !ok {
+		return policy, "Too many blocked channels"
+	}
+	if policy.BlockedKeywords, ok = parsePolicyList(r.FormValue("blocked_keywords")); !ok {
+		return policy, "Too many blocked words"
+	}
+	if policy.MinDuration, ok = parsePolicyMinutes(r.FormValue("min_duration")); !ok {
+		return policy, "Invalid minimum length"
+	}
+	if policy.MaxDuration, ok = parsePolicyMinutes(r.FormValue("max_duration")); !ok {
+		return policy, "Invalid maximum length"
+	}
+	if policy.MaxDuration > 0 && policy.MinDuration > policy.MaxDuration {
+		return policy, "Minimum length is longer than the maximum"
+	}
+
+	policy.RejectLive = r.FormValue("reject_live") == "true"
+	policy.RejectEmbedDisabled = r.FormValue("reject_embed_disabled") == "true"
+	policy.RejectRegionBlocked = r.FormValue("reject_region_blocked") == "true"
+
+	return policy, ""
+}
+
+func HandleLobbyPolicy(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	policy := lobby.ContentPolicy()
+
+	if wantsJSON(r) {
+		respondJSON(w, policy)
+		return
+	}
+
+	setContentTypeHTML(w)
+	templates.PolicyPartial(lobby, policy, lobby.IsOwner(user)).Render(r.Context(), w)
+}
+
+func HandleUpdatePolicy(lobby *dj.Lobby, user *dj.User, w http.ResponseWriter, r *http.Request) {
+	if !lobby.IsOwner(user) {
+		respondWithToast("Only the lobby owner can change the rules", "error", w)
+		http.Error(w, "not lobby owner", http.StatusForbidden)
+		return
+	}
+
+	policy, problem := parseContentPolicy(r)
+	if problem != "" {
+		respondWithToast(problem, "error", w)
+		http.Error(w, "invalid policy", http.StatusBadRequest)
+		return
+	}
+
+	lobby.SetContentPolicy(policy)
+
+	respondWithToast("Rules updated", "success", w)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/dj"
+)
+
+func TestParsePolicyList(t *testing.T) {
+	many := make([]string, MaxPolicyListEntries+1)
+	for i := range many {
+		many[i] = "channel" + strconv.Itoa(i)
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+		ok   bool
+	}{
+		{"empty", "", []string{}, true},
+		{"lines and commas", "one\r\ntwo, three\n\n, ,", []string{"one", "two", "three"}, true},
+		{"duplicates", "Lofi\nlofi\nLOFI ", []string{"Lofi"}, true},
+		{"longest entry", strings.Repeat("a", MaxPolicyEntryLength), []string{strings.Repeat("a", MaxPolicyEntryLength)}, true},
+		{"entry too long", "ok\n" + strings.Repeat("a", MaxPolicyEntryLength+1), nil, false},
+		{"most entries", strings.Join(many[:MaxPolicyListEntries], "\n"), many[:MaxPolicyListEntries], true},
+		{"too many entries", strings.Join(many, "\n"), nil, false},
+		{"duplicates don't count", strings.Join(many[:MaxPolicyListEntries], "\n") + "\nchannel0", many[:MaxPolicyListEntries], true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parsePolicyList(tt.raw)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !slices.Equal(got, tt.want) {
+				t.Errorf("list = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseContentPolicy(t *testing.T) {
+	parse := func(form url.Values) (dj.ContentPolicy, string) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return parseContentPolicy(r)
+	}
+
+	maxMinutes := strconv.Itoa(dj.MaxPolicyDurationMinutes)
+	var tooMany string
+	for i := range MaxPolicyListEntries + 1 {
+		tooMany += "entry" + strconv.Itoa(i) + ","
+	}
+
+	refused := []struct {
+		name string
+		form url.Values
+		msg  string
+	}{
+		{"allow list", url.Values{"allow_channels": {tooMany}}, "Too many allowed channels"},
+		{"deny list", url.Values{"deny_channels": {tooMany}}, "Too many blocked channels"},
+		{"keywords", url.Values{"blocked_keywords": {tooMany}}, "Too many blocked words"},
+		{"min not a number", url.Values{"min_duration": {"ten"}}, "Invalid minimum length"},
+		{"min negative", url.Values{"min_duration": {"-1"}}, "Invalid minimum length"},
+		{"max over the limit", url.Values{"max_duration": {strconv.Itoa(dj.MaxPolicyDurationMinutes + 1)}}, "Invalid maximum length"},
+		{"min over max", url.Values{"min_duration": {"10"}, "max_duration": {"5"}}, "Minimum length is longer than the maximum"},
+	}
+	for _, tt := range refused {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, msg := parse(tt.form); msg != tt.msg {
+				t.Errorf("message = %q, want %q", msg, tt.msg)
+			}
+		})
+	}
+
+	policy, msg := parse(url.Values{
+		"allow_channels":        {"Chill Channel"},
+		"min_duration":          {" 2 "},
+		"max_duration":          {maxMinutes},
+		"reject_live":           {"true"},
+		"reject_region_blocked": {"on"},
+	})
+	if msg != "" {
+		t.Fatalf("refused: %s", msg)
+	}
+	if !slices.Equal(policy.AllowChannels, []string{"Chill Channel"}) {
+		t.Errorf("AllowChannels = %q", policy.AllowChannels)
+	}
+	if policy.MinDuration != 2*time.Minute || policy.MaxDuration != time.Duration(dj.MaxPolicyDurationMinutes)*time.Minute {
+		t.Errorf("durations = %v to %v", policy.MinDuration, policy.MaxDuration)
+	}
+	if !policy.RejectLive || policy.RejectEmbedDisabled || policy.RejectRegionBlocked {
+		t.Errorf("checkboxes = live %v, embed %v, region %v", policy.RejectLive, policy.RejectEmbedDisabled, policy.RejectRegionBlocked)
+	}
+
+	// Blank lengths mean no limit, so any minimum goes with a blank maximum
+	policy, msg = parse(url.Values{"min_duration": {"30"}, "max_duration": {""}})
+	if msg != "" {
+		t.Fatalf("blank max refused: %s", msg)
+	}
+	if policy.MaxDuration != 0 {
+		t.Errorf("MaxDuration = %v, want no limit", policy.MaxDuration)
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/btnmasher/testdj/internal/config"
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/metrics"
+	"github.com/btnmasher/testdj/internal/shared"
+)
+
+// How often idle buckets are dropped. A bucket that has refilled is the same
+// as a new one, so forgetting it changes nothing for the client.
+const rateLimitSweepInterval = time.Minute
+
+// buckets holds one token bucket per key for a single limit.
+type buckets struct {
+	limit config.Limit
+
+	mu      sync.Mutex
+	byKey   map[string]*rate.Limiter
+	sweptAt time.Time
+}
+
+func newBuckets(limit config.Limit) *buckets {
+	if !limit.Enabled() {
+		return nil
+	}
+	return &buckets{
+		limit: limit,
+		byKey: make(map[string]*rate.Limiter),
+	}
+}
+
+// allow takes a token from the bucket of key, or reports how long until one
+// is available. A nil set allows everything.
+func (b *buckets) allow(key string, now time.Time) (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Sub(b.sweptAt) >= rateLimitSweepInterval {
+		b.sweep(now)
+	}
+
+	limiter, ok := b.byKey[key]
+	if !ok {
+		every := rate.Every(b.limit.Period / time.Duration(b.limit.Requests))
+		limiter = rate.NewLimiter(every, b.limit.Burst)
+		b.byKey[key] = limiter
+	}
+
+	if limiter.AllowN(now, 1) {
+		return true, 0
+	}
+
+	reservation := limiter.ReserveN(now, 1)
+	wait := reservation.DelayFrom(now)
+	reservation.CancelAt(now)
+	return false, wait
+}
+
+func (b *buckets) sweep(now time.Time) {
+	for key, limiter := range b.byKey {
+		if limiter.TokensAt(now) >= float64(b.limit.Burst) {
+			delete(b.byKey, key)
+		}
+	}
+	b.sweptAt = now
+}
+
+type routeLimiter struct {
+	perIP   *buckets
+	perUser *buckets
+}
+
+// rateLimits holds the buckets of every route, built from the config and
+// replaced when the limits are reloaded.
+type rateLimits map[string]routeLimiter
+
+func newRateLimits(cfg config.RateLimit) rateLimits {
+	limits := make(rateLimits)
+	for route, limit := range cfg.Routes() {
+		limits[route] = routeLimiter{
+			perIP:   newBuckets(limit.PerIP),
+			perUser: newBuckets(limit.PerUser),
+		}
+	}
+	return limits
+}
+
+// RateLimit throttles route per client address and, for requests from
+// someone in a lobby, per user. Refused requests get a 429 with Retry-After
+// and a toast.
+func RateLimit(rt *Runtime, route string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limiter := (*rt.limits.Load())[route]
+			now := time.Now()
+
+			ip, err := shared.ParseHost(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+			if ok, wait := limiter.perIP.allow(ip, now); !ok {
+				refuseRateLimited(w, r, route, metrics.ScopeIP, wait)
+				return
+			}
+
+			if user, ok := r.Context().Value(ContextUser).(*dj.User); ok {
+				if ok, wait := limiter.perUser.allow(user.ID, now); !ok {
+					refuseRateLimited(w, r, route, metrics.ScopeUser, wait)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func refuseRateLimited(w http.ResponseWriter, r *http.Request, route, scope string, wait time.Duration) {
+	seconds := max(1, int(math.Ceil(wait.Seconds())))
+
+	metrics.RateLimited.WithLabelValues(route, scope).Inc()
+	mustGetLogger(r).Warn("Rate limited",
+		slog.String("route", route),
+		slog.String("scope", scope),
+		slog.String("ip", r.RemoteAddr),
+		slog.Int("retry_after", seconds),
+	)
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	respondWithToast(fmt.Sprintf("Slow down, try again in %ds", seconds), "error", w)
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/config"
+	"github.com/btnmasher/testdj/internal/metrics"
+	"github.com/btnmasher/testdj/internal/ytfake"
+)
+
+func TestRateLimitPerUser(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	bob := app.newClient("192.0.2.2")
+
+	id := alice.createLobby("alice", nil)
+	bob.join(id, "bob")
+
+	app.Runtime.setRateLimits(config.RateLimit{
+		Add: config.RouteLimit{PerUser: config.Limit{Requests: 1, Period: time.Hour, Burst: 1}},
+	})
+	limited := counter(metrics.RateLimited.WithLabelValues(config.RouteAdd, metrics.ScopeUser))
+
+	add := url.Values{"url": {"https://youtu.be/" + ytfake.VideoNormal}}
+	if resp := alice.post("/lobby/"+id+"/add", add); resp.StatusCode == http.StatusTooManyRequests {
+		t.Fatal("first add was rate limited")
+	}
+
+	resp := alice.post("/lobby/"+id+"/add", add)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second add: status %d, want 429", resp.StatusCode)
+	}
+	if retry, _ := strconv.Atoi(resp.Header.Get("Retry-After")); retry < 3500 || retry > 3600 {
+		t.Errorf("Retry-After = %q, want about an hour", resp.Header.Get("Retry-After"))
+	}
+	if !strings.Contains(resp.Header.Get("HX-Trigger"), "Slow down") {
+		t.Errorf("no toast: %q", resp.Header.Get("HX-Trigger"))
+	}
+	if got := limited(); got != 1 {
+		t.Errorf("rate_limited_total{add,user} = %v, want 1", got)
+	}
+
+	// Buckets are per user
+	if resp := bob.post("/lobby/"+id+"/add", add); resp.StatusCode == http.StatusTooManyRequests {
+		t.Error("bob was limited by alice's adds")
+	}
+}
+
+func TestRateLimitPerIP(t *testing.T) {
+	app := newTestApp(t)
+	app.Runtime.setRateLimits(config.RateLimit{
+		Create: config.RouteLimit{PerIP: config.Limit{Requests: 1, Period: time.Minute, Burst: 1}},
+	})
+	limited := counter(metrics.RateLimited.WithLabelValues(config.RouteCreate, metrics.ScopeIP))
+
+	alice := app.newClient("192.0.2.1")
+	alice.createLobby("alice", nil)
+
+	// A new cookie jar does not get around the address limit
+	again := app.newClient("192.0.2.1")
+	if resp := again.post("/create", url.Values{"name": {"mallory"}}); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second create: status %d, want 429", resp.StatusCode)
+	}
+	if got := limited(); got != 1 {
+		t.Errorf("rate_limited_total{create,ip} = %v, want 1", got)
+	}
+
+	// Other routes and addresses are unaffected
+	app.newClient("192.0.2.2").createLobby("bob", nil)
+	if resp := app.newClient("192.0.2.1").get("/healthz"); resp.StatusCode != http.StatusOK {
+		t.Errorf("healthz: status %d", resp.StatusCode)
+	}
+}
+
+func TestBucketsSweep(t *testing.T) {
+	b := newBuckets(config.Limit{Requests: 1, Period: time.Second, Burst: 1})
+	now := time.Now()
+
+	b.allow("a", now)
+	b.allow("b", now.Add(rateLimitSweepInterval/2))
+	b.allow("c", now.Add(rateLimitSweepInterval))
+
+	// a and b refilled long ago, c was just used
+	if len(b.byKey) != 1 || b.byKey["c"] == nil {
+		t.Errorf("after sweep: %d buckets, want only c", len(b.byKey))
+	}
+	if ok, _ := newBuckets(config.Limit{}).allow("a", now); !ok {
+		t.Error("a disabled limit refused a request")
+	}
+}
+
+func TestRateLimitAdminLogin(t *testing.T) {
+	app := newTestApp(t)
+	app.enableAdmin()
+	limited := counter(metrics.RateLimited.WithLabelValues(config.RouteAdminLogin, metrics.ScopeIP))
+
+	// The default limit allows a few guesses, then not even the right token
+	mallory := app.newClient("192.0.2.1")
+	burst := config.Default().RateLimit.AdminLogin.PerIP.Burst
+	for range burst {
+		if resp := mallory.post("/admin/login", url.Values{"token": {"wrong-token-0123456"}}); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("guess: status %d, want 401", resp.StatusCode)
+		}
+	}
+	if resp := mallory.post("/admin/login", url.Values{"token": {testAdminToken}}); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("sign in after %d guesses: status %d, want 429", burst, resp.StatusCode)
+	}
+	if got := limited(); got != 1 {
+		t.Errorf("rate_limited_total{admin_login,ip} = %v, want 1", got)
+	}
+
+	if resp := app.newClient("192.0.2.2").post("/admin/login", url.Values{"token": {testAdminToken}}); resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("sign in from another address: status %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimitSearch(t *testing.T) {
+	app := newTestApp(t)
+	app.Runtime.setRateLimits(config.RateLimit{
+		Search: config.RouteLimit{PerUser: config.Limit{Requests: 1, Period: time.Minute, Burst: 1}},
+	})
+
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+
+	// Typing the first letters and pasting a link don't use up searches
+	for _, q := range []string{"f", "fa", url.QueryEscape("https://youtu.be/" + ytfake.VideoNormal)} {
+		if resp := alice.get("/lobby/" + id + "/search?q=" + q); resp.StatusCode != http.StatusOK {
+			t.Fatalf("search %q: status %d", q, resp.StatusCode)
+		}
+	}
+
+	if resp := alice.get("/lobby/" + id + "/search?q=fake"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first search: status %d", resp.StatusCode)
+	}
+	if resp := alice.get("/lobby/" + id + "/search?q=fake"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("second search: status %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestRateLimitPlayerErrorSeparate(t *testing.T) {
+	app := newTestApp(t)
+	app.Runtime.setRateLimits(config.RateLimit{
+		React:       config.RouteLimit{PerUser: config.Limit{Requests: 1, Period: time.Minute, Burst: 1}},
+		PlayerError: config.RouteLimit{PerUser: config.Limit{Requests: 1, Period: time.Minute, Burst: 1}},
+	})
+
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+
+	react := url.Values{"reaction": {"fire"}}
+	alice.post("/lobby/"+id+"/react", react)
+	if resp := alice.post("/lobby/"+id+"/react", react); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("react: status %d, want 429", resp.StatusCode)
+	}
+
+	report := url.Values{"video": {ytfake.VideoNormal}, "code": {"100"}}
+	if resp := alice.post("/lobby/"+id+"/player-error", report); resp.StatusCode == http.StatusTooManyRequests {
+		t.Error("player error report was limited by reactions")
+	}
+	if resp := alice.post("/lobby/"+id+"/player-error", report); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("second player error report: status %d, want 429", resp.StatusCode)
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"compress/flate"
+	"io/fs"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	slogchi "github.com/samber/slog-chi"
+
+	"github.com/btnmasher/testdj/internal/config"
+	"github.com/btnmasher/testdj/internal/shared"
+	"github.com/btnmasher/testdj/internal/tracing"
+)
+
+// NewRouter builds the HTTP routes for the app, serving static assets from
+// staticFS with the live configuration from rt. The client address settings
+// are read once, they need a restart to change.
+func NewRouter(rt *Runtime, staticFS fs.FS) *chi.Mux {
+	logger := rt.Logger
+	server := rt.Config().Server
+
+	r := chi.NewRouter()
+	r.Use(
+		middleware.Recoverer,
+		shared.RealIP(server.ClientIPHeader, server.TrustedPrefixes()),
+		tracing.Middleware,
+		middleware.Compress(flate.DefaultCompression),
+		slogchi.NewWithConfig(logger.With("service", "http"), slogchi.Config{
+			DefaultLevel:     slog.LevelInfo,
+			ClientErrorLevel: slog.LevelWarn,
+			ServerErrorLevel: slog.LevelError,
+			WithRequestID:    true,
+			WithTraceID:      true,
+			WithSpanID:       true,
+			Filters: []slogchi.Filter{
+				slogchi.IgnoreStatus(http.StatusNoContent),
+				slogchi.IgnorePath("/healthz", "/readyz"),
+			},
+		}),
+		InjectLogger(logger),
+		InjectRuntime(rt),
+		InjectManager(rt.Manager),
+	)
+
+	r.Get("/*", func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(http.FS(staticFS)).ServeHTTP(w, r)
+	})
+
+	r.Get("/healthz", HandleHealthz)
+	r.Get("/readyz", HandleReadyz(rt))
+
+	r.Get("/", HandleLanding)
+	r.Get("/lobbies", HandleDirectory)
+	r.Get("/lobbies/list", HandleDirectoryList)
+	r.Get("/lobbies/sse", HandleDirectorySSE)
+	r.With(RateLimit(rt, config.RouteCreate)).Post("/create", HandleCreateLobby)
+
+	r.Get("/admin/login", HandleAdminLoginPage(rt))
+	r.With(RateLimit(rt, config.RouteAdminLogin)).Post("/admin/login", HandleAdminLogin(rt))
+	r.Post("/admin/logout", HandleAdminLogout(rt))
+
+	r.Route("/admin", func(admin chi.Router) {
+		admin.Use(RequireAdmin(rt))
+
+		admin.Get("/", HandleAdminConsole(rt))
+		admin.Post("/reload", HandleAdminReload(rt))
+		admin.Post("/announce", HandleAdminAnnounce(rt))
+		admin.Route("/lobbies/{lobbyId}", func(lobby chi.Router) {
+			lobby.Post("/expire", HandleAdminExpireLobby(rt))
+			lobby.Post("/mutes/clear", HandleAdminClearMutes(rt))
+			lobby.Post("/users/{userId}/kick", HandleAdminKickUser(rt))
+		})
+	})
+
+	r.Group(func(session chi.Router) {
+		session.Use(InjectSession())
+
+		session.With(RateLimit(rt, config.RouteJoin)).Post("/join", HandleJoinLobby)
+		session.With(RateLimit(rt, config.RouteJoin)).Post("/join/{lobbyId}", HandleJoinLobby)
+		session.Get("/invite/{lobbyId}", HandleInviteLink)
+		session.Get("/sse/{lobbyId}", HandleSSE)
+		session.Get("/logout", WithLobbyAndUser(HandleLogout))
+		session.Post("/logout", WithLobbyAndUser(HandleLogout))
+
+		session.Route("/lobby/{lobbyId}", func(lobby chi.Router) {
+			lobby.Use(RequireMember)
+
+			lobby.Get("/", WithLobbyAndUser(HandleLobbyPage))
+			lobby.Get("/video", HandleLobbyVideo)
+			lobby.Get("/playlist", HandleLobbyPlaylist)
+			lobby.Get("/history", HandleLobbyHistory)
+			lobby.Get("/history/export", HandleLobbyHistoryExport)
+			lobby.Post("/heartbeat", WithLobbyAndUser(HandleHeartbeat))
+			lobby.With(RateLimit(rt, config.RouteAdd)).Post("/add", WithLobbyAndUser(HandleAddVideo))
+			lobby.With(RateLimit(rt, config.RouteReact)).Post("/react", WithLobbyAndUser(HandleReact))
+			lobby.With(RateLimit(rt, config.RoutePlayerError)).Post("/player-error", WithLobbyAndUser(HandlePlayerError))
+			lobby.With(SkipUnsearchable, RateLimit(rt, config.RouteSearch)).Get("/search", WithLobbyAndUser(HandleSearch))
+			lobby.Get("/policy", WithLobbyAndUser(HandleLobbyPolicy))
+			lobby.Post("/policy", WithLobbyAndUser(HandleUpdatePolicy))
+			lobby.Get("/access", WithLobbyAndUser(HandleLobbyAccess))
+			lobby.Post("/access", WithLobbyAndUser(HandleUpdateAccess))
+			lobby.Post("/invites", WithLobbyAndUser(HandleCreateInvite))
+			lobby.Post("/invites/revoke", WithLobbyAndUser(HandleRevokeInvites))
+			lobby.Post("/invites/{inviteId}/revoke", WithLobbyAndUser(HandleRevokeInvite))
+			lobby.Get("/name", WithLobbyAndUser(HandleLobbyName))
+			lobby.Post("/name", WithLobbyAndUser(HandleUpdateName))
+			lobby.Get("/listing", WithLobbyAndUser(HandleLobbyListing))
+			lobby.Post("/listing", WithLobbyAndUser(HandleUpdateListing))
+			lobby.Get("/users", WithLobbyAndUser(HandleLobbyUsers))
+			lobby.Get("/votes", WithLobbyAndUser(HandleLobbyVotes))
+			lobby.Route("/vote", func(vote chi.Router) {
+				vote.Use(RateLimit(rt, config.RouteVote))
+				vote.Post("/skip/start", WithLobbyAndUser(HandleVoteSkipStart))
+				vote.Post("/skip/submit", WithLobbyAndUser(HandleVoteSkipSubmit))
+				vote.Post("/mute/start", WithLobbyAndUser(HandleVoteMuteStart))
+				vote.Post("/mute/submit", WithLobbyAndUser(HandleVoteMuteSubmit))
+			})
+		})
+	})
+
+	return r
+}
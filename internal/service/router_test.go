@@ -0,0 +1,166 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/ytfake"
+)
+
+func (a *testApp) lobby(id string) *dj.Lobby {
+	a.t.Helper()
+
+	lobby, ok := a.Manager.GetLobby(id)
+	if !ok {
+		a.t.Fatalf("lobby %s not found", id)
+	}
+	return lobby
+}
+
+func TestJoinBroadcastsUsers(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	bob := app.newClient("192.0.2.2")
+
+	id := alice.createLobby("alice", nil)
+	events := alice.openSSE(id)
+
+	bob.join(id, "bob")
+
+	ev := events.waitFor(dj.UpdateUsers)
+	if !strings.Contains(ev.Data, `"bob"`) {
+		t.Errorf("users update %s does not list bob", ev.Data)
+	}
+
+	if resp := bob.get("/lobby/" + id + "/"); resp.StatusCode != http.StatusOK {
+		t.Errorf("lobby page for bob: status %d", resp.StatusCode)
+	}
+}
+
+func TestVoteSkipEndToEnd(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	bob := app.newClient("192.0.2.2")
+	carol := app.newClient("192.0.2.3")
+
+	id := alice.createLobby("alice", nil)
+	bob.join(id, "bob")
+	carol.join(id, "carol")
+
+	aliceEvents := alice.openSSE(id)
+	carolEvents := carol.openSSE(id)
+
+	add := alice.post("/lobby/"+id+"/add", url.Values{"url": {"https://youtu.be/" + ytfake.VideoNormal}})
+	if add.StatusCode != http.StatusCreated {
+		t.Fatalf("add video: status %d", add.StatusCode)
+	}
+	carolEvents.waitFor(dj.UpdateVideo)
+
+	if resp := alice.post("/lobby/"+id+"/vote/skip/start", nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("start vote: status %d", resp.StatusCode)
+	}
+	carolEvents.waitFor(dj.UpdateVoteSkip)
+
+	if resp := bob.post("/lobby/"+id+"/vote/skip/submit", url.Values{"vote": {"yes"}}); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("submit vote: status %d", resp.StatusCode)
+	}
+
+	ev := aliceEvents.waitFor(dj.UpdateVoteSkipEnd)
+	if !strings.Contains(ev.Data, "passed") {
+		t.Errorf("vote end %s, want the vote to pass", ev.Data)
+	}
+
+	history := app.lobby(id).PlayHistory()
+	if len(history) != 1 || !history[0].Skipped {
+		t.Errorf("history = %+v, want the video skipped", history)
+	}
+}
+
+func TestVoteSkipTimesOut(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	bob := app.newClient("192.0.2.2")
+
+	id := alice.createLobby("alice", nil)
+	bob.join(id, "bob")
+	events := bob.openSSE(id)
+
+	alice.post("/lobby/"+id+"/add", url.Values{"url": {"https://youtu.be/" + ytfake.VideoNormal}})
+	alice.post("/lobby/"+id+"/vote/skip/start", nil)
+	events.waitFor(dj.UpdateVoteSkip)
+
+	app.Clock.Advance(dj.VoteDuration)
+
+	ev := events.waitFor(dj.UpdateVoteSkipEnd)
+	if !strings.Contains(ev.Data, "failed") {
+		t.Errorf("vote end %s, want the vote to fail", ev.Data)
+	}
+}
+
+func TestSessionOnePerIP(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+
+	// A second browser on the same address takes over the session
+	other := app.newClient(alice.IP)
+	other.join(id, "other")
+
+	resp := alice.get("/lobby/" + id + "/")
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/" {
+		t.Errorf("stale session: got %d to %q, want a redirect home", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if alice.sessionCookie() != "" {
+		t.Error("stale session cookie was not cleared")
+	}
+
+	if resp := other.get("/lobby/" + id + "/"); resp.StatusCode != http.StatusOK {
+		t.Errorf("new session: status %d", resp.StatusCode)
+	}
+}
+
+func TestSessionSecondDeviceRefused(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+	alice.openSSE(id)
+
+	other := app.newClient(alice.IP)
+	resp := other.post("/join/"+id, url.Values{"name": {"other"}})
+	if resp.StatusCode != http.StatusOK || other.sessionCookie() != "" {
+		t.Errorf("second device: got %d with session %q, want the error page", resp.StatusCode, other.sessionCookie())
+	}
+
+	if resp := alice.get("/lobby/" + id + "/users"); resp.StatusCode != http.StatusOK {
+		t.Errorf("first device: status %d", resp.StatusCode)
+	}
+}
+
+func TestSessionUnknownCookie(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+
+	u, _ := url.Parse(app.URL)
+	stranger := app.newClient("192.0.2.9")
+	stranger.HTTP.Jar.SetCookies(u, []*http.Cookie{{Name: "session_id", Value: "forged"}})
+
+	resp := stranger.get("/lobby/" + id + "/")
+	if resp.StatusCode != http.StatusSeeOther || stranger.sessionCookie() != "" {
+		t.Errorf("forged session: got %d with cookie %q, want a redirect and no cookie", resp.StatusCode, stranger.sessionCookie())
+	}
+}
+
+func TestUnknownLobby(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	alice.createLobby("alice", nil)
+
+	resp := alice.get("/lobby/nolobby/")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d, want the error page", resp.StatusCode)
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lmittmann/tint"
+
+	"github.com/btnmasher/testdj/internal/config"
+	"github.com/btnmasher/testdj/internal/dj"
+)
+
+var ErrReloadDisabled = errors.New("config reload is not enabled")
+
+// Runtime holds the live configuration and the YouTube client built from it,
+// both are swapped by Reload while requests are being served.
+type Runtime struct {
+	Manager *dj.LobbyManager
+	Logger  *slog.Logger
+
+	// LogLevel is adjusted on reload when set.
+	LogLevel *slog.LevelVar
+	// Load reads the configuration again, reloading is disabled when nil.
+	Load func() (*config.Config, error)
+
+	reloadMu sync.Mutex
+	config   atomic.Pointer[config.Config]
+	youtube  atomic.Pointer[YouTubeClient]
+	limits   atomic.Pointer[rateLimits]
+	admin    adminSessions
+	probe    fetchProbe
+	draining atomic.Bool
+}
+
+func NewRuntime(cfg *config.Config, yt *YouTubeClient, manager *dj.LobbyManager, logger *slog.Logger) *Runtime {
+	rt := &Runtime{
+		Manager: manager,
+		Logger:  logger,
+	}
+	rt.config.Store(cfg)
+	rt.youtube.Store(yt)
+	rt.setRateLimits(cfg.RateLimit)
+	return rt
+}
+
+func (rt *Runtime) Config() *config.Config {
+	return rt.config.Load()
+}
+
+func (rt *Runtime) YouTube() *YouTubeClient {
+	return rt.youtube.Load()
+}
+
+// setRateLimits replaces the rate limit buckets, clients start over with
+// full buckets.
+func (rt *Runtime) setRateLimits(cfg config.RateLimit) {
+	limits := newRateLimits(cfg)
+	rt.limits.Store(&limits)
+}
+
+// Drain marks the server as shutting down, readiness fails from then on.
+func (rt *Runtime) Drain() {
+	rt.draining.Store(true)
+}
+
+func (rt *Runtime) Draining() bool {
+	return rt.draining.Load()
+}
+
+// Reload loads the configuration again and applies it: the log level, the
+// lobby manager settings, the YouTube client and the rate limits. Settings that are only read
+// at startup are logged as needing a restart. It returns the changes made.
+func (rt *Runtime) Reload() ([]config.Change, error) {
+	log := rt.Logger.With("func", "Reload")
+
+	if rt.Load == nil {
+		return nil, ErrReloadDisabled
+	}
+
+	rt.reloadMu.Lock()
+	defer rt.reloadMu.Unlock()
+
+	next, err := rt.Load()
+	if err != nil {
+		log.Error("Config reload failed, keeping the current config", tint.Err(err))
+		return nil, err
+	}
+
+	prev := rt.Config()
+	changes := config.Diff(prev, next)
+	if len(changes) == 0 {
+		log.Info("Config reloaded, nothing changed")
+		return changes, nil
+	}
+
+	// Startup only settings keep reporting what the process runs with
+	next.Server = prev.Server
+	next.Metrics = prev.Metrics
+	next.Tracing = prev.Tracing
+	next.Session.Key = prev.Session.Key
+
+	if rt.LogLevel != nil {
+		rt.LogLevel.Set(next.Log.SlogLevel())
+	}
+
+	rt.Manager.SetSettings(next.ManagerSettings())
+
+	if prev.YouTube != next.YouTube {
+		rt.youtube.Store(rt.YouTube().withConfig(next.YouTube))
+		rt.probe.reset()
+	}
+
+	if prev.RateLimit != next.RateLimit {
+		rt.setRateLimits(next.RateLimit)
+	}
+
+	// A new admin token signs out everyone who signed in with the old one
+	if prev.Admin.Token != next.Admin.Token {
+		rt.admin.endAll()
+	}
+
+	rt.config.Store(next)
+
+	attrs := make([]any, 0, len(changes))
+	for _, c := range changes {
+		attrs = append(attrs, slog.Any(c.Key, c))
+		if c.NeedsRestart() {
+			log.Warn("Config change needs a restart to take effect", slog.String("key", c.Key))
+		}
+	}
+	log.Info("Config reloaded", slog.Group("changes", attrs...))
+
+	return changes, nil
+}
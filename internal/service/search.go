@@ -0,0 +1,422 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lmittmann/tint"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/templates"
+)
+
+const (
+	MinSearchQueryLength = 3
+	MaxSearchQueryLength = 100
+	MaxSearchResults     = 8
+)
+
+type iosSearchRequest struct {
+	Query   string         `json:"query"`
+	Context requestContext `json:"context"`
+}
+
+type ytSearchAPIResp struct {
+	Items []struct {
+		ID struct {
+			VideoID string `json:"videoId"`
+		} `json:"id"`
+	} `json:"items"`
+}
+
+// searchVideos finds videos matching the query that can be added to a lobby.
+// The Data API is used when YT_API_KEY is set, otherwise (or if it fails) the
+// iOS client search is used. Age restricted videos and videos the lobby
+// content policy would reject are dropped.
+func (yt *YouTubeClient) searchVideos(ctx context.Context, query string, policy dj.ContentPolicy) ([]dj.SearchResult, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	var apiErr error
+	if yt.APIKey != "" {
+		var results []dj.SearchResult
+		results, apiErr = yt.searchVideosDataAPI(timeoutCtx, query, policy)
+		if apiErr == nil {
+			return results, nil
+		}
+	}
+
+	results, scrapeErr := yt.searchVideosMobileScrape(timeoutCtx, query, policy)
+	if scrapeErr != nil {
+		if apiErr != nil {
+			return nil, fmt.Errorf("official data api: %w; mobile scrape path: %w", apiErr, scrapeErr)
+		}
+		return nil, scrapeErr
+	}
+
+	return results, nil
+}
+
+func (yt *YouTubeClient) searchVideosDataAPI(ctx context.Context, query string, policy dj.ContentPolicy) ([]dj.SearchResult, error) {
+	params := url.Values{}
+	params.Set("part", "id")
+	params.Set("type", "video")
+	params.Set("safeSearch", "strict")
+	params.Set("maxResults", fmt.Sprintf("%d", MaxSearchResults*2))
+	params.Set("q", query)
+	params.Set("key", yt.APIKey)
+
+	var found ytSearchAPIResp
+	if err := yt.getJSON(ctx, yt.DataAPIBaseURL+dataAPISearchPath+"?"+params.Encode(), &found); err != nil {
+		return nil, fmt.Errorf("data api search: %w", err)
+	}
+
+	ids := make([]string, 0, len(found.Items))
+	for _, it := range found.Items {
+		if it.ID.VideoID != "" {
+			ids = append(ids, it.ID.VideoID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return []dj.SearchResult{}, nil
+	}
+
+	params = url.Values{}
+	params.Set("part", "snippet,contentDetails,status")
+	params.Set("id", strings.Join(ids, ","))
+	params.Set("key", yt.APIKey)
+
+	var details ytDataAPIResp
+	if err := yt.getJSON(ctx, yt.DataAPIBaseURL+dataAPIVideosPath+"?"+params.Encode(), &details); err != nil {
+		return nil, fmt.Errorf("data api videos: %w", err)
+	}
+
+	results := make([]dj.SearchResult, 0, MaxSearchResults)
+	for _, it := range details.Items {
+		if len(results) >= MaxSearchResults {
+			break
+		}
+
+		if it.IsAgeRestricted() {
+			continue
+		}
+
+		playability := it.Playability()
+		if !playability.Playable() {
+			continue
+		}
+
+		dur, err := parseISO8601(it.ContentDetails.Duration)
+		if err != nil || (dur <= 0 && playability != PlayabilityLive) {
+			continue
+		}
+
+		candidate := dj.PolicyCandidate{
+			Title:         strings.TrimSpace(it.Snippet.Title),
+			Channel:       strings.TrimSpace(it.Snippet.ChannelTitle),
+			ChannelID:     it.Snippet.ChannelID,
+			Duration:      dur,
+			Live:          playability == PlayabilityLive,
+			EmbedDisabled: playability == PlayabilityEmbedDisabled,
+			RegionBlocked: it.IsRegionBlocked(yt.Region),
+		}
+		if policy.Evaluate(candidate) != nil {
+			continue
+		}
+
+		results = append(results, dj.SearchResult{
+			ID:        it.ID,
+			Title:     candidate.Title,
+			Channel:   candidate.Channel,
+			Duration:  dur,
+			Thumbnail: bestThumbnail(it.Snippet.Thumbnails),
+		})
+	}
+
+	return results, nil
+}
+
+// searchVideosMobileScrape searches with the iOS client, then checks each hit
+// with a player request, since search results carry no age rating.
+func (yt *YouTubeClient) searchVideosMobileScrape(ctx context.Context, query string, policy dj.ContentPolicy) ([]dj.SearchResult, error) {
+	visitorData, visitorErr := yt.resolveVisitorData(ctx)
+	if visitorErr != nil {
+		return nil, visitorErr
+	}
+
+	reqPayload := iosSearchRequest{
+		Query:   query,
+		Context: iosReqTemplate.Context,
+	}
+	reqPayload.Context.Client.VisitorData = visitorData
+
+	raw, payloadErr := json.Marshal(reqPayload)
+	if payloadErr != nil {
+		return nil, payloadErr
+	}
+
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, yt.BaseURL+searchPath, bytes.NewBuffer(raw))
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, respErr := yt.HTTPClient.Do(req)
+	if respErr != nil {
+		return nil, respErr
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("mobile search response %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	var tree any
+	if err := json.NewDecoder(resp.Body).Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	candidates := collectSearchRenderers(tree, MaxSearchResults*2)
+
+	// Fill in duration and age gating from the player response, concurrently
+	checked := make([]*dj.SearchResult, len(candidates))
+	var wg sync.WaitGroup
+	for i, c := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			pr, err := yt.fetchPlayerResponse(ctx, visitorData, c.ID)
+			if err != nil || pr.IsAgeRestricted() || !pr.Playability().Playable() {
+				return
+			}
+
+			meta := pr.Meta()
+			if meta.Duration <= 0 || policy.Evaluate(meta.PolicyCandidate()) != nil {
+				return
+			}
+
+			c.Duration = meta.Duration
+			c.Channel = meta.Channel
+			if c.Title == "" {
+				c.Title = meta.Title
+			}
+			checked[i] = &c
+		}()
+	}
+	wg.Wait()
+
+	results := make([]dj.SearchResult, 0, MaxSearchResults)
+	for _, c := range checked {
+		if c != nil && len(results) < MaxSearchResults {
+			results = append(results, *c)
+		}
+	}
+
+	return results, nil
+}
+
+// Video renderer variants the youtubei search can return, depending on client.
+var searchRendererKeys = []string{"videoRenderer", "compactVideoRenderer", "videoWithContextRenderer"}
+
+// collectSearchRenderers walks the search response tree and returns up to
+// limit unique videos found in any of the known video renderers.
+func collectSearchRenderers(tree any, limit int) []dj.SearchResult {
+	results := make([]dj.SearchResult, 0, limit)
+	seen := make(map[string]bool)
+
+	var walk func(node any)
+	walk = func(node any) {
+		if len(results) >= limit {
+			return
+		}
+
+		switch n := node.(type) {
+		case []any:
+			for _, child := range n {
+				walk(child)
+			}
+		case map[string]any:
+			for _, key := range searchRendererKeys {
+				renderer, ok := n[key].(map[string]any)
+				if !ok {
+					continue
+				}
+				id, _ := renderer["videoId"].(string)
+				if id == "" || seen[id] || len(results) >= limit {
+					continue
+				}
+				seen[id] = true
+				results = append(results, dj.SearchResult{
+					ID:        id,
+					Title:     rendererText(renderer["title"], renderer["headline"]),
+					Thumbnail: rendererThumbnail(renderer["thumbnail"]),
+				})
+			}
+			// Sorted keys keep the result order stable between calls
+			for _, key := range slices.Sorted(maps.Keys(n)) {
+				walk(n[key])
+			}
+		}
+	}
+
+	walk(tree)
+	return results
+}
+
+// rendererText reads the first non-empty youtubei text node, which is either
+// {"simpleText": "..."} or {"runs": [{"text": "..."}]}.
+func rendererText(nodes ...any) string {
+	for _, node := range nodes {
+		text, ok := node.(map[string]any)
+		if !ok {
+			continue
+		}
+		if s, ok := text["simpleText"].(string); ok && s != "" {
+			return strings.TrimSpace(s)
+		}
+		runs, _ := text["runs"].([]any)
+		var sb strings.Builder
+		for _, run := range runs {
+			if r, ok := run.(map[string]any); ok {
+				s, _ := r["text"].(string)
+				sb.WriteString(s)
+			}
+		}
+		if sb.Len() > 0 {
+			return strings.TrimSpace(sb.String())
+		}
+	}
+	return ""
+}
+
+func rendererThumbnail(node any) string {
+	thumb, _ := node.(map[string]any)
+	list, _ := thumb["thumbnails"].([]any)
+	if len(list) == 0 {
+		return ""
+	}
+	// Thumbnails are ordered smallest to largest, the first is plenty for a dropdown
+	first, _ := list[0].(map[string]any)
+	u, _ := first["url"].(string)
+	return u
+}
+
+func bestThumbnail(thumbs map[string]ytThumbnail) string {
+	for _, size := range []string{"medium", "default", "high"} {
+		if t, ok := thumbs[size]; ok && t.URL != "" {
+			return t.URL
+		}
+	}
+	return ""
+}
+
+func (yt *YouTubeClient) getJSON(ctx context.Context, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := yt.HTTPClient.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	return nil
+}
+
+var errSearchQuery = errors.New("invalid search query")
+
+func parseSearchQuery(r *http.Request) (string, error) {
+	query := strings.TrimSpace(r.FormValue("q"))
+	if query == "" {
+		// The add box doubles as the search box
+		query = strings.TrimSpace(r.FormValue("url"))
+	}
+
+	n := utf8.RuneCountInString(query)
+	if n < MinSearchQueryLength || n > MaxSearchQueryLength {
+		return "", errSearchQuery
+	}
+
+	return query, nil
+}
+
+// searchableQuery returns the query to search for, or false when it is too
+// short to search yet or is a link.
+func searchableQuery(r *http.Request) (string, bool) {
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		return "", false
+	}
+	if _, isLink := validateYTUrl(query); isLink {
+		return "", false
+	}
+	return query, true
+}
+
+// SkipUnsearchable clears the dropdown for queries that won't be searched
+// before they reach the rate limiter, so typing and pasting links don't use
+// up a user's searches.
+func SkipUnsearchable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lobby, ok := r.Context().Value(ContextLobby).(*dj.Lobby)
+		if _, searchable := searchableQuery(r); ok && !searchable {
+			setContentTypeHTML(w)
+			templates.SearchResultsPartial(lobby.ID, nil).Render(r.Context(), w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func HandleSearch(lobby *dj.Lobby, _ *dj.User, w http.ResponseWriter, r *http.Request) {
+	yt := mustGetYouTube(r)
+
+	setContentTypeHTML(w)
+
+	query, ok := searchableQuery(r)
+	if !ok {
+		// Too short to search yet, or a link: clear the dropdown
+		templates.SearchResultsPartial(lobby.ID, nil).Render(r.Context(), w)
+		return
+	}
+
+	results, err := yt.searchVideos(r.Context(), query, lobby.ContentPolicy())
+	if err != nil {
+		mustGetLogger(r).Error("Error searching videos", tint.Err(err))
+		respondWithToast("Search failed", "error", w)
+		templates.SearchResultsPartial(lobby.ID, nil).Render(r.Context(), w)
+		return
+	}
+
+	templates.SearchResultsPartial(lobby.ID, results).Render(r.Context(), w)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/shared"
+)
+
+// withCookie returns a new client on the same address holding only the given
+// session cookie value.
+func (c *testClient) withCookie(value string) *testClient {
+	u, _ := url.Parse(c.app.URL)
+	other := c.app.newClient(c.IP)
+	other.HTTP.Jar.SetCookies(u, []*http.Cookie{{Name: SessionCookieName, Value: value}})
+	return other
+}
+
+// refused reports whether the client's session was turned away and its
+// cookie cleared.
+func (c *testClient) refused(path string) bool {
+	resp := c.get(path)
+	return resp.StatusCode == http.StatusSeeOther && c.sessionCookie() == ""
+}
+
+func TestSessionCookieSigned(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+	lobby := "/lobby/" + id + "/users"
+
+	value := alice.sessionCookie()
+	token, ok := shared.VerifySessionCookie([]byte(testSessionKey), value)
+	if !ok || !shared.ValidSessionToken(token) {
+		t.Fatalf("session cookie %q is not a signed current token", value)
+	}
+	if app.Manager.UsersBySessionHash.Exists(token) || !app.Manager.UsersBySessionHash.Exists(shared.HashSessionToken(token)) {
+		t.Error("sessions are not keyed by the token hash")
+	}
+
+	forged := value[:len(value)-1] + "A"
+	if forged == value {
+		forged = value[:len(value)-1] + "B"
+	}
+	if !alice.withCookie(forged).refused(lobby) {
+		t.Error("a cookie with a bad signature was accepted")
+	}
+	if !alice.withCookie(token).refused(lobby) {
+		t.Error("an unsigned token was accepted")
+	}
+
+	// A cookie from the old 12 character scheme is dropped, even if it matches
+	user, _ := app.Manager.UserBySession(value)
+	app.Manager.UsersBySessionHash.Set("abcdefGHIJ12", user)
+	if !alice.withCookie("abcdefGHIJ12").refused(lobby) {
+		t.Error("an old style session ID was accepted")
+	}
+
+	if resp := alice.get(lobby); resp.StatusCode != http.StatusOK {
+		t.Errorf("own session: status %d", resp.StatusCode)
+	}
+}
+
+func TestSessionRotation(t *testing.T) {
+	app := newTestApp(t)
+	settings := app.Manager.Settings()
+	settings.SessionRotateInterval = time.Minute
+	settings.UserIdleTimeout = time.Hour
+	app.Manager.SetSettings(settings)
+
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+	lobby := "/lobby/" + id + "/users"
+	first := alice.sessionCookie()
+
+	alice.get(lobby)
+	if alice.sessionCookie() != first {
+		t.Fatal("session rotated before the interval")
+	}
+
+	app.Clock.Advance(time.Minute)
+	alice.get(lobby)
+	second := alice.sessionCookie()
+	if second == first || second == "" {
+		t.Fatalf("session did not rotate: %q", second)
+	}
+
+	// Requests in flight with the old token still get through for a while
+	if resp := alice.withCookie(first).get(lobby); resp.StatusCode != http.StatusOK {
+		t.Errorf("replaced token within the grace period: status %d", resp.StatusCode)
+	}
+
+	app.Clock.Advance(time.Minute)
+	if !alice.withCookie(first).refused(lobby) {
+		t.Error("replaced token still works after the grace period")
+	}
+	if resp := alice.withCookie(second).get(lobby); resp.StatusCode != http.StatusOK {
+		t.Errorf("current token: status %d", resp.StatusCode)
+	}
+}
+
+func TestSessionExpiresOnServer(t *testing.T) {
+	app := newTestApp(t)
+	settings := app.Manager.Settings()
+	settings.SessionLifetime = 10 * time.Minute
+	settings.SessionRotateInterval = time.Minute
+	settings.UserIdleTimeout = time.Hour
+	app.Manager.SetSettings(settings)
+
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+
+	// Rotating does not extend the session
+	for range 9 {
+		app.Clock.Advance(time.Minute)
+		if resp := alice.get("/lobby/" + id + "/users"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("before expiry: status %d", resp.StatusCode)
+		}
+	}
+
+	app.Clock.Advance(time.Minute)
+	if !alice.refused("/lobby/" + id + "/users") {
+		t.Error("session outlived its lifetime")
+	}
+}
+
+func TestLogoutEndsSession(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	bob := app.newClient("192.0.2.2")
+	id := alice.createLobby("alice", nil)
+	bob.join(id, "bob")
+
+	value := bob.sessionCookie()
+	if resp := bob.post("/logout", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("logout: status %d", resp.StatusCode)
+	}
+	if bob.sessionCookie() != "" {
+		t.Error("logout did not clear the cookie")
+	}
+
+	lobby, _ := app.Manager.GetLobby(id)
+	if lobby.Users.Length() != 1 {
+		t.Errorf("%d users left in the lobby, want 1", lobby.Users.Length())
+	}
+	if !bob.withCookie(value).refused("/lobby/" + id + "/users") {
+		t.Error("session still works after logout")
+	}
+}
+
+func TestTabCloseGracePeriod(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	bob := app.newClient("192.0.2.2")
+	id := alice.createLobby("alice", nil)
+	bob.join(id, "bob")
+	lobby, _ := app.Manager.GetLobby(id)
+	tabClose := url.Values{"reason": {"tab_close"}}
+
+	// A reload sends the beacon and then carries on with the same session
+	if resp := bob.post("/logout", tabClose); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("tab close: status %d", resp.StatusCode)
+	}
+	if bob.sessionCookie() == "" {
+		t.Fatal("tab close cleared the cookie")
+	}
+	app.Clock.Advance(LeaveGrace / 2)
+	for _, c := range []*testClient{alice, bob} {
+		if resp := c.post("/lobby/"+id+"/heartbeat", nil); resp.StatusCode >= http.StatusBadRequest {
+			t.Fatalf("heartbeat: status %d", resp.StatusCode)
+		}
+	}
+	app.Clock.Advance(LeaveGrace)
+	app.Manager.CleanupUsers()
+	if lobby.Users.Length() != 2 {
+		t.Fatalf("%d users in the lobby after a reload, want 2", lobby.Users.Length())
+	}
+
+	// A real close lets the session lapse once the grace period is over
+	alice.post("/lobby/"+id+"/heartbeat", nil)
+	bob.post("/logout", tabClose)
+	app.Clock.Advance(LeaveGrace + time.Second)
+	app.Manager.CleanupUsers()
+	if lobby.Users.Length() != 1 {
+		t.Errorf("%d users left in the lobby, want 1", lobby.Users.Length())
+	}
+	if !bob.refused("/lobby/" + id + "/users") {
+		t.Error("session still works after the grace period")
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/ytfake"
+)
+
+var (
+	spanRecorder    = tracetest.NewSpanRecorder()
+	installRecorder sync.Once
+)
+
+// recordSpans installs a global tracer provider that keeps every span. The
+// package tracers bind to the first provider set, so it is set only once.
+func recordSpans() *tracetest.SpanRecorder {
+	installRecorder.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+	return spanRecorder
+}
+
+func TestTracingAddVideo(t *testing.T) {
+	recorder := recordSpans()
+
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	id := alice.createLobby("alice", nil)
+	events := alice.openSSE(id)
+
+	alice.post("/lobby/"+id+"/add", url.Values{"url": {"https://youtu.be/" + ytfake.VideoNormal}})
+	events.waitFor(dj.UpdateVideo)
+
+	var root sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "POST /lobby/{lobbyId}/add" {
+			root = span
+		}
+	}
+	if root == nil {
+		t.Fatal("no span for the add route")
+	}
+	traceID := root.SpanContext().TraceID()
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == traceID {
+			byName[span.Name()] = span
+		}
+	}
+
+	parents := map[string]string{
+		"YouTubeClient.fetchVideoMeta":             "POST /lobby/{lobbyId}/add",
+		"YouTubeClient.fetchVideoMetaMobileScrape": "YouTubeClient.fetchVideoMeta",
+		"YouTubeClient.resolveVisitorData":         "YouTubeClient.fetchVideoMetaMobileScrape",
+		"YouTubeClient.fetchPlayerResponse":        "YouTubeClient.fetchVideoMetaMobileScrape",
+		"Lobby.Lock AddVideo":                      "POST /lobby/{lobbyId}/add",
+	}
+	for name, parent := range parents {
+		span, ok := byName[name]
+		if !ok {
+			t.Errorf("no %q span in the add trace", name)
+			continue
+		}
+		if want := byName[parent]; want == nil || span.Parent().SpanID() != want.SpanContext().SpanID() {
+			t.Errorf("%q is not a child of %q", name, parent)
+		}
+	}
+
+	lock := byName["Lobby.Lock AddVideo"]
+	if lock != nil && (len(lock.Events()) != 1 || lock.Events()[0].Name != "lock acquired") {
+		t.Errorf("lock span events = %+v, want the lock acquired", lock.Events())
+	}
+	if root.SpanKind() != trace.SpanKindServer {
+		t.Errorf("route span kind = %v, want server", root.SpanKind())
+	}
+}
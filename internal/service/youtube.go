@@ -2,26 +2,41 @@ package service
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/btnmasher/testdj/internal/config"
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/metrics"
+	"github.com/btnmasher/testdj/internal/tracing"
 )
 
 // RegEx Patterns
 var (
-	youtubeRegex  = regexp.MustCompile(`^(?:https?://)?(?:www\.|m\.)?(?:youtube\.com/watch\?v=|youtu\.be/)([A-Za-z0-9_-]{11})(?:[?&].*)?$`)
-	durationRegex = regexp.MustCompile(`(?i)<meta\s+itemprop=(?:"|')duration(?:"|')\s+content=(?:"|')([^"']+)(?:"|')`)
-	titleRegex    = regexp.MustCompile(`(?i)<meta\s+(?:name|property)=(?:"|')(?:og:)?title(?:"|')\s+content=(?:"|')([^"']+)(?:"|')`)
-	iso8601Regex  = regexp.MustCompile(`PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?`)
-	nameRegex     = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9 ]{0,18}[A-Za-z0-9])?$`)
+	youtubeRegex   = regexp.MustCompile(`^(?:https?://)?(?:www\.|m\.)?(?:youtube\.com/watch\?v=|youtu\.be/)([A-Za-z0-9_-]{11})(?:[?&].*)?$`)
+	durationRegex  = regexp.MustCompile(`(?i)<meta\s+itemprop=(?:"|')duration(?:"|')\s+content=(?:"|')([^"']+)(?:"|')`)
+	titleRegex     = regexp.MustCompile(`(?i)<meta\s+(?:name|property)=(?:"|')(?:og:)?title(?:"|')\s+content=(?:"|')([^"']+)(?:"|')`)
+	iso8601Regex   = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+	authorRegex    = regexp.MustCompile(`(?i)<link\s+itemprop=(?:"|')name(?:"|')\s+content=(?:"|')([^"']+)(?:"|')`)
+	thumbnailRegex = regexp.MustCompile(`(?i)<meta\s+property=(?:"|')og:image(?:"|')\s+content=(?:"|')([^"']+)(?:"|')`)
+	publishedRegex = regexp.MustCompile(`(?i)<meta\s+itemprop=(?:"|')datePublished(?:"|')\s+content=(?:"|')([^"']+)(?:"|')`)
+	viewsRegex     = regexp.MustCompile(`(?i)<meta\s+itemprop=(?:"|')interactionCount(?:"|')\s+content=(?:"|')(\d+)(?:"|')`)
+	nameRegex      = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9 ]{0,18}[A-Za-z0-9])?$`)
 )
 
 // Errors
@@ -29,6 +44,70 @@ var (
 	ErrAgeRestircted = errors.New("age restircted")
 )
 
+// Playability classifies how a video behaves in the lobby's embedded player.
+type Playability string
+
+const (
+	PlayabilityEmbeddable    Playability = "embeddable"
+	PlayabilityEmbedDisabled Playability = "embed_disabled"
+	PlayabilityLive          Playability = "live"
+	PlayabilityPrivate       Playability = "private"
+	PlayabilityUnavailable   Playability = "unavailable"
+)
+
+// Playable reports whether the video can be played at all. Live streams and
+// videos with embedding disabled are left to the lobby content policy.
+func (p Playability) Playable() bool {
+	return p != PlayabilityPrivate && p != PlayabilityUnavailable
+}
+
+// PlayabilityDisplayReason is shown to users when a video can't be queued.
+var PlayabilityDisplayReason = map[Playability]string{
+	PlayabilityEmbedDisabled: "This video can't be played outside of YouTube",
+	PlayabilityLive:          "Live streams can't be queued",
+	PlayabilityPrivate:       "This video is private",
+	PlayabilityUnavailable:   "This video is unavailable",
+}
+
+// UnplayableError is returned by the fetch paths for videos nobody can play,
+// such as private or deleted videos.
+type UnplayableError struct {
+	Playability Playability
+	Reason      string
+}
+
+func (e *UnplayableError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("video %s: %s", e.Playability, e.Reason)
+	}
+	return fmt.Sprintf("video %s", e.Playability)
+}
+
+// isFinalFetchError reports errors that no other fetch path would change.
+func isFinalFetchError(err error) bool {
+	var unplayable *UnplayableError
+	return errors.Is(err, ErrAgeRestircted) || errors.As(err, &unplayable)
+}
+
+var tracer = otel.Tracer("github.com/btnmasher/testdj/internal/service")
+
+// traceFetch starts a span for a metadata fetch path. The returned func ends
+// it and records the latency and whether the fetch failed. Age restricted
+// and unplayable videos are answers, not failures.
+func traceFetch(ctx context.Context, name, path string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "YouTubeClient."+name, trace.WithAttributes(attribute.String("fetch.path", path)))
+
+	return ctx, func(err error) {
+		if isFinalFetchError(err) {
+			span.SetAttributes(attribute.String("fetch.rejected", err.Error()))
+			err = nil
+		}
+		tracing.End(span, err)
+		metrics.ObserveFetch(path, start, err)
+	}
+}
+
 type FetchOption int8
 
 const (
@@ -49,66 +128,194 @@ func (o FetchOption) Has(f FetchOption) bool {
 	return o&f == f
 }
 
-func fetchVideoMeta(ctx context.Context, videoID string, fetchType FetchOption) (string, time.Duration, error) {
-	var title string
-	var dur time.Duration
+const (
+	DefaultYouTubeBaseURL = "https://www.youtube.com"
+	DefaultDataAPIBaseURL = "https://www.googleapis.com"
+	DefaultFetchTimeout   = config.DefaultFetchTimeout
+)
+
+// YouTubeClient holds the endpoints and HTTP client used to look up videos.
+// The base URLs can be pointed at a fake server in tests.
+type YouTubeClient struct {
+	HTTPClient     *http.Client
+	BaseURL        string        // serves sw.js_data, youtubei and the watch page
+	DataAPIBaseURL string        // serves the YouTube Data API v3
+	APIKey         string        // the Data API paths are skipped when empty
+	Fetch          FetchOption   // metadata paths to try, in order of preference
+	Timeout        time.Duration // overall limit for a single lookup
+	Region         string        // country the server looks videos up from
+}
+
+// NewYouTubeClient returns a client for the real YouTube endpoints.
+func NewYouTubeClient(cfg config.YouTube) *YouTubeClient {
+	yt := &YouTubeClient{
+		HTTPClient:     &http.Client{Timeout: 15 * time.Second},
+		BaseURL:        DefaultYouTubeBaseURL,
+		DataAPIBaseURL: DefaultDataAPIBaseURL,
+	}
+	return yt.withConfig(cfg)
+}
+
+// withConfig returns a copy of the client with the key, fetch paths and
+// timeout from cfg, keeping its endpoints.
+func (yt *YouTubeClient) withConfig(cfg config.YouTube) *YouTubeClient {
+	fetch := UseDataAPI
+	if cfg.UseScrape {
+		fetch = fetch.Set(UseScrapeFetch)
+	}
+
+	timeout := cfg.FetchTimeout
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout
+	}
+
+	next := *yt
+	next.APIKey = strings.TrimSpace(cfg.APIKey)
+	next.Fetch = fetch
+	next.Timeout = timeout
+	next.Region = strings.ToUpper(cmp.Or(cfg.Region, config.DefaultRegion))
+	return &next
+}
+
+// VideoMeta is the metadata gathered for a video by any of the fetch paths.
+// Fields other than Title and Duration are best effort and may be empty.
+type VideoMeta struct {
+	Title        string
+	Duration     time.Duration
+	Channel      string
+	ChannelID    string
+	ThumbnailURL string
+	PublishedAt  time.Time
+	ViewCount    int64
+
+	Playability   Playability
+	RegionBlocked bool
+}
+
+// PolicyCandidate returns what the lobby content policy is checked against.
+func (m *VideoMeta) PolicyCandidate() dj.PolicyCandidate {
+	return dj.PolicyCandidate{
+		Title:         m.Title,
+		Channel:       m.Channel,
+		ChannelID:     m.ChannelID,
+		Duration:      m.Duration,
+		Live:          m.Playability == PlayabilityLive,
+		EmbedDisabled: m.Playability == PlayabilityEmbedDisabled,
+		RegionBlocked: m.RegionBlocked,
+	}
+}
+
+// complete reports whether the metadata is enough to judge the video. Live
+// streams have no duration, the content policy decides on them.
+func (m *VideoMeta) complete() bool {
+	return m != nil && m.Title != "" && (m.Duration > 0 || m.Playability == PlayabilityLive)
+}
+
+// redactURLError blanks the API key out of the URL a transport error carries,
+// so it stays out of logs, spans and responses.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	redacted := *urlErr
+	if u, parseErr := url.Parse(urlErr.URL); parseErr != nil {
+		redacted.URL = ""
+	} else if q := u.Query(); q.Has("key") {
+		q.Set("key", config.Redacted)
+		u.RawQuery = q.Encode()
+		redacted.URL = u.String()
+	}
+	return &redacted
+}
+
+// ProbeVideoID is looked up to check the Data API works when scraping is off.
+const ProbeVideoID = "jNQXAC9IVRw"
+
+// probe checks the preferred fetch path can reach YouTube, returning the path.
+func (yt *YouTubeClient) probe(ctx context.Context) (string, error) {
+	switch {
+	case yt.Fetch.Has(UseScrapeFetch):
+		_, err := yt.resolveVisitorData(ctx)
+		return metrics.FetchScrape, err
+	case yt.Fetch.Has(UseDataAPI) && yt.APIKey != "":
+		_, err := yt.fetchVideoMetaDataAPI(ctx, ProbeVideoID)
+		return metrics.FetchDataAPI, err
+	default:
+		return "", errors.New("no metadata fetch path is configured")
+	}
+}
+
+func (yt *YouTubeClient) fetchVideoMeta(ctx context.Context, videoID string) (_ *VideoMeta, err error) {
+	ctx, span := tracer.Start(ctx, "YouTubeClient.fetchVideoMeta", trace.WithAttributes(attribute.String("video.id", videoID)))
+	defer func() { tracing.End(span, err) }()
+
+	var meta *VideoMeta
 	var scrapeErr error
 	var apiErr error
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	timeoutCtx, cancel := context.WithTimeout(ctx, yt.Timeout)
 	defer cancel()
 
 	switch {
-	case fetchType.Has(UseScrapeFetch):
+	case yt.Fetch.Has(UseScrapeFetch):
 		// Primary path: mobile client emulation
-		title, dur, scrapeErr = fetchVideoMetaMobileScrape(timeoutCtx, videoID)
-		if scrapeErr == nil && dur > 0 && title != "" {
-			return title, dur, nil
+		meta, scrapeErr = yt.fetchVideoMetaMobileScrape(timeoutCtx, videoID)
+		if scrapeErr == nil && meta.complete() {
+			return meta, nil
 		}
-		if errors.Is(scrapeErr, ErrAgeRestircted) {
-			return "", dur, scrapeErr
+		if isFinalFetchError(scrapeErr) {
+			return nil, scrapeErr
 		}
 
 		fallthrough
-	case fetchType.Has(UseDataAPI):
+	case yt.Fetch.Has(UseDataAPI):
 		// Fallback: official YouTube Data API v3 (requires API key)
-		apiKey := strings.TrimSpace(os.Getenv("YT_API_KEY"))
-		if apiKey == "" {
+		if yt.APIKey == "" {
 			if scrapeErr != nil {
-				return "", 0, fmt.Errorf(
+				return nil, fmt.Errorf(
 					"scrape path failed (%v); official YouTube Data API fallback disabled (set YT_API_KEY environment variable)", scrapeErr,
 				)
 			}
-			return "", 0, errors.New("YouTube Data API disabled (set YT_API_KEY environment variable)")
+			return nil, errors.New("YouTube Data API disabled (set YT_API_KEY environment variable)")
 		}
 
-		title, dur, apiErr = fetchVideoMetaDataAPI(timeoutCtx, videoID, apiKey)
-		if apiErr == nil && dur > 0 && title != "" {
-			return title, dur, nil
+		meta, apiErr = yt.fetchVideoMetaDataAPI(timeoutCtx, videoID)
+		if apiErr == nil && meta.complete() {
+			return meta, nil
 		}
 
-		if errors.Is(scrapeErr, ErrAgeRestircted) {
-			return "", dur, scrapeErr
+		if isFinalFetchError(scrapeErr) {
+			return nil, scrapeErr
 		}
 	}
 
 	// Both failed; surface both contexts for logs.
 	switch {
 	case scrapeErr != nil && apiErr != nil:
-		return "", 0, fmt.Errorf("mobile scrape path: %w; official data api: %w", scrapeErr, apiErr)
+		return nil, fmt.Errorf("mobile scrape path: %w; official data api: %w", scrapeErr, apiErr)
 	case apiErr != nil:
-		return "", 0, fmt.Errorf("official data api fallback failed: %w", apiErr)
+		return nil, fmt.Errorf("official data api fallback failed: %w", apiErr)
 	default:
-		return "", 0, errors.New("failed to obtain metadata")
+		return nil, errors.New("failed to obtain metadata")
 	}
 }
 
 // --- mobile scrape types ---
 
+const (
+	swDataPath = "/sw.js_data"
+	playerPath = "/youtubei/v1/player"
+	searchPath = "/youtubei/v1/search"
+	watchPath  = "/watch"
+
+	dataAPIVideosPath = "/youtube/v3/videos"
+	dataAPISearchPath = "/youtube/v3/search"
+)
+
 var (
 	userAgent = "com.google.ios.youtube/20.32.4 (iPhone16,2; U; CPU iOS 18_6_0 like Mac OS X; US)"
-	swDataURL = "https://www.youtube.com/sw.js_data"
-	playerURL = "https://www.youtube.com/youtubei/v1/player"
 
 	iosReqTemplate = iosPlayerRequest{
 		ContentCheckOk: true,
@@ -157,6 +364,14 @@ type playerResponse struct {
 	VideoDetails struct {
 		Title         string `json:"title"`
 		LengthSeconds string `json:"lengthSeconds"`
+		Author        string `json:"author"`
+		ChannelID     string `json:"channelId"`
+		ViewCount     string `json:"viewCount"`
+		IsLive        bool   `json:"isLive"`
+		IsUpcoming    bool   `json:"isUpcoming"`
+		Thumbnail     struct {
+			Thumbnails []ytThumbnail `json:"thumbnails"`
+		} `json:"thumbnail"`
 		// Sometimes present (not guaranteed on all variants):
 		AgeRestricted bool `json:"ageRestricted"`
 	} `json:"videoDetails"`
@@ -171,6 +386,7 @@ type playerResponse struct {
 		Status                     string `json:"status"` // e.g. "OK", "UNPLAYABLE", "LOGIN_REQUIRED", "AGE_VERIFICATION_REQUIRED"
 		Reason                     string `json:"reason"`
 		DesktopLegacyAgeGateReason int    `json:"desktopLegacyAgeGateReason"`
+		PlayableInEmbed            *bool  `json:"playableInEmbed"` // absent on some variants
 	} `json:"playabilityStatus"`
 
 	// Microformat rating flags:
@@ -178,6 +394,7 @@ type playerResponse struct {
 		PlayerMicroformatRenderer struct {
 			IsFamilySafe bool   `json:"isFamilySafe"` // often false for age-restricted
 			YTRating     string `json:"ytRating"`     // e.g. "ytAgeRestricted"
+			PublishDate  string `json:"publishDate"`  // "2009-10-24" or RFC 3339
 		} `json:"playerMicroformatRenderer"`
 	} `json:"microformat"`
 }
@@ -185,77 +402,176 @@ type playerResponse struct {
 // --- Data API types ---
 
 type ytDataAPIResp struct {
-	Items []struct {
-		Snippet struct {
-			Title string `json:"title"`
-		} `json:"snippet"`
-		ContentDetails struct {
-			Duration      string `json:"duration"` // ISO 8601, e.g. "PT5M19S"
-			ContentRating struct {
-				YTRating string `json:"ytRating"` // "ytAgeRestricted" or empty
-			} `json:"contentRating"`
-		} `json:"contentDetails"`
-	} `json:"items"`
-}
-
-func fetchVideoMetaDataAPI(ctx context.Context, videoID, apiKey string) (string, time.Duration, error) {
+	Items []ytDataAPIItem `json:"items"`
+}
+
+type ytDataAPIItem struct {
+	ID      string `json:"id"`
+	Snippet struct {
+		Title        string                 `json:"title"`
+		ChannelTitle string                 `json:"channelTitle"`
+		ChannelID    string                 `json:"channelId"`
+		PublishedAt  string                 `json:"publishedAt"`
+		Thumbnails   map[string]ytThumbnail `json:"thumbnails"`
+		LiveContent  string                 `json:"liveBroadcastContent"` // "none", "live" or "upcoming"
+	} `json:"snippet"`
+	Status struct {
+		UploadStatus  string `json:"uploadStatus"`  // "processed" once playable
+		PrivacyStatus string `json:"privacyStatus"` // "public", "unlisted" or "private"
+		Embeddable    *bool  `json:"embeddable"`
+	} `json:"status"`
+	Statistics struct {
+		ViewCount string `json:"viewCount"`
+	} `json:"statistics"`
+	ContentDetails struct {
+		Duration      string `json:"duration"` // ISO 8601, e.g. "PT5M19S"
+		ContentRating struct {
+			YTRating string `json:"ytRating"` // "ytAgeRestricted" or empty
+		} `json:"contentRating"`
+		RegionRestriction struct {
+			Allowed []string `json:"allowed"`
+			Blocked []string `json:"blocked"`
+		} `json:"regionRestriction"`
+	} `json:"contentDetails"`
+}
+
+type ytThumbnail struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func (yt *YouTubeClient) fetchVideoMetaDataAPI(ctx context.Context, videoID string) (_ *VideoMeta, err error) {
+	ctx, done := traceFetch(ctx, "fetchVideoMetaDataAPI", metrics.FetchDataAPI)
+	defer func() { done(err) }()
+
 	ctx, cancel := context.WithTimeout(ctx, 12*time.Second)
 	defer cancel()
 
-	u := "https://www.googleapis.com/youtube/v3/videos" +
-		"?part=snippet,contentDetails&id=" + videoID + "&key=" + apiKey
+	params := url.Values{}
+	params.Set("part", "snippet,contentDetails,statistics,status")
+	params.Set("id", videoID)
+	params.Set("key", yt.APIKey)
+	u := yt.DataAPIBaseURL + dataAPIVideosPath + "?" + params.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
-		return "", 0, fmt.Errorf("data api build request: %w", err)
+		return nil, fmt.Errorf("data api build request: %w", err)
 	}
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	resp, err := yt.HTTPClient.Do(req)
 	if err != nil {
-		return "", 0, fmt.Errorf("data api request: %w", err)
+		return nil, fmt.Errorf("data api request: %w", redactURLError(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return "", 0, fmt.Errorf("data api %s: %s", resp.Status, strings.TrimSpace(string(b)))
+		return nil, fmt.Errorf("data api %s: %s", resp.Status, strings.TrimSpace(string(b)))
 	}
 
 	var out ytDataAPIResp
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return "", 0, fmt.Errorf("data api decode: %w", err)
+		return nil, fmt.Errorf("data api decode: %w", err)
 	}
 
 	if len(out.Items) == 0 {
-		return "", 0, fmt.Errorf("data api: no items for id %q", videoID)
+		// Private and deleted videos are left out of the response entirely
+		return nil, fmt.Errorf("data api: %w", &UnplayableError{Playability: PlayabilityUnavailable, Reason: "no items for id " + videoID})
 	}
 
 	if out.AnyAgeRestricted() {
-		return "", 0, fmt.Errorf("data api: %w", ErrAgeRestircted)
+		return nil, fmt.Errorf("data api: %w", ErrAgeRestircted)
 	}
 
-	title := strings.TrimSpace(out.Items[0].Snippet.Title)
-	iso := strings.TrimSpace(out.Items[0].ContentDetails.Duration)
+	item := out.Items[0]
+	if p := item.Playability(); !p.Playable() {
+		return nil, fmt.Errorf("data api: %w", &UnplayableError{Playability: p, Reason: item.Status.UploadStatus})
+	}
 
-	dur, err := parseISO8601(iso)
+	meta := &VideoMeta{
+		Title:        strings.TrimSpace(item.Snippet.Title),
+		Channel:      strings.TrimSpace(item.Snippet.ChannelTitle),
+		ChannelID:    item.Snippet.ChannelID,
+		ThumbnailURL: bestThumbnail(item.Snippet.Thumbnails),
+		PublishedAt:  parsePublishDate(item.Snippet.PublishedAt),
+		ViewCount:    parseViewCount(item.Statistics.ViewCount),
+
+		Playability:   item.Playability(),
+		RegionBlocked: item.IsRegionBlocked(yt.Region),
+	}
+
+	iso := strings.TrimSpace(item.ContentDetails.Duration)
+	meta.Duration, err = parseISO8601(iso)
 	if err != nil {
-		return title, 0, fmt.Errorf("data api parse duration %q: %w", iso, err)
+		return meta, fmt.Errorf("data api parse duration %q: %w", iso, err)
 	}
-	return title, dur, nil
+	return meta, nil
 }
 
-// fetchVideoMetaMobileScrape returns the video title and duration by emulating the iOS client.
-func fetchVideoMetaMobileScrape(ctx context.Context, videoID string) (string, time.Duration, error) {
+// fetchVideoMetaMobileScrape returns the video metadata by emulating the iOS client.
+func (yt *YouTubeClient) fetchVideoMetaMobileScrape(ctx context.Context, videoID string) (_ *VideoMeta, err error) {
+	ctx, done := traceFetch(ctx, "fetchVideoMetaMobileScrape", metrics.FetchScrape)
+	defer func() { done(err) }()
+
 	ctx, cancel := context.WithTimeout(ctx, 25*time.Second)
 	defer cancel()
-	hc := &http.Client{Timeout: 15 * time.Second}
 
-	visitorData, visitorErr := resolveVisitorData(ctx, hc)
+	visitorData, visitorErr := yt.resolveVisitorData(ctx)
 	if visitorErr != nil {
-		return "", 0, visitorErr
+		return nil, visitorErr
+	}
+
+	pr, prErr := yt.fetchPlayerResponse(ctx, visitorData, videoID)
+	if prErr != nil {
+		return nil, prErr
+	}
+
+	if pr.IsAgeRestricted() {
+		return nil, fmt.Errorf("mobile scrape: %w", ErrAgeRestircted)
+	}
+
+	if p := pr.Playability(); !p.Playable() {
+		return nil, fmt.Errorf("mobile scrape: %w", &UnplayableError{Playability: p, Reason: pr.PlayabilityStatus.Reason})
+	}
+
+	meta := pr.Meta()
+	if meta.Duration == 0 && meta.Playability != PlayabilityLive {
+		return nil, errors.New("duration not found")
+	}
+
+	return meta, nil
+}
+
+// Meta collects the video metadata from the player response.
+func (pr *playerResponse) Meta() *VideoMeta {
+	vd := pr.VideoDetails
+	meta := &VideoMeta{
+		Title:       strings.TrimSpace(vd.Title),
+		Duration:    pr.Duration(),
+		Channel:     strings.TrimSpace(vd.Author),
+		ChannelID:   vd.ChannelID,
+		PublishedAt: parsePublishDate(pr.Microformat.PlayerMicroformatRenderer.PublishDate),
+		ViewCount:   parseViewCount(vd.ViewCount),
+
+		Playability:   pr.Playability(),
+		RegionBlocked: pr.IsRegionBlocked(),
+	}
+
+	// Thumbnails are ordered smallest to largest, prefer a mid-sized one
+	if thumbs := vd.Thumbnail.Thumbnails; len(thumbs) > 0 {
+		meta.ThumbnailURL = thumbs[len(thumbs)/2].URL
 	}
 
+	return meta
+}
+
+// fetchPlayerResponse posts an iOS client player request for the video.
+func (yt *YouTubeClient) fetchPlayerResponse(ctx context.Context, visitorData, videoID string) (_ *playerResponse, err error) {
+	ctx, span := tracer.Start(ctx, "YouTubeClient.fetchPlayerResponse")
+	defer func() { tracing.End(span, err) }()
+
 	// Clone the template, fill in per-call fields
 	reqPayload := iosReqTemplate
 	reqPayload.VideoID = videoID
@@ -263,45 +579,45 @@ func fetchVideoMetaMobileScrape(ctx context.Context, videoID string) (string, ti
 
 	raw, payloadErr := json.Marshal(reqPayload)
 	if payloadErr != nil {
-		return "", 0, payloadErr
+		return nil, payloadErr
 	}
 
-	req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, playerURL, bytes.NewBuffer(raw))
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, yt.BaseURL+playerPath, bytes.NewBuffer(raw))
 	if reqErr != nil {
-		return "", 0, reqErr
+		return nil, reqErr
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", userAgent)
 
-	resp, respErr := hc.Do(req)
+	resp, respErr := yt.HTTPClient.Do(req)
 	if respErr != nil {
-		return "", 0, respErr
+		return nil, respErr
 	}
 
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return "", 0, fmt.Errorf("mobile scrape response %s: %s", resp.Status, strings.TrimSpace(string(b)))
+		return nil, fmt.Errorf("mobile scrape response %s: %s", resp.Status, strings.TrimSpace(string(b)))
 	}
 
 	body, readErr := io.ReadAll(resp.Body)
 	if readErr != nil {
-		return "", 0, readErr
+		return nil, readErr
 	}
 
 	var pr playerResponse
 	if jsErr := json.Unmarshal(body, &pr); jsErr != nil {
-		return "", 0, jsErr
+		return nil, jsErr
 	}
 
-	if pr.IsAgeRestricted() {
-		return "", 0, fmt.Errorf("mobile scrape: %w", ErrAgeRestircted)
-	}
-
-	title := strings.TrimSpace(pr.VideoDetails.Title)
+	return &pr, nil
+}
 
+// Duration returns the video length from the video details, falling back to
+// the streaming formats. Zero means the duration could not be determined.
+func (pr *playerResponse) Duration() time.Duration {
 	secs := int64(0)
 	if s := strings.TrimSpace(pr.VideoDetails.LengthSeconds); s != "" {
 		if n, e := strconv.ParseInt(s, 10, 64); e == nil {
@@ -322,22 +638,21 @@ func fetchVideoMetaMobileScrape(ctx context.Context, videoID string) (string, ti
 		}
 	}
 
-	if secs == 0 {
-		return "", 0, errors.New("duration not found")
-	}
-
-	return title, time.Duration(secs) * time.Second, nil
+	return time.Duration(secs) * time.Second
 }
 
-func resolveVisitorData(ctx context.Context, hc *http.Client) (string, error) {
-	req, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, swDataURL, nil)
+func (yt *YouTubeClient) resolveVisitorData(ctx context.Context) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "YouTubeClient.resolveVisitorData")
+	defer func() { tracing.End(span, err) }()
+
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, yt.BaseURL+swDataPath, nil)
 	if reqErr != nil {
 		return "", reqErr
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", userAgent)
 
-	resp, respErr := hc.Do(req)
+	resp, respErr := yt.HTTPClient.Do(req)
 	if respErr != nil {
 		return "", respErr
 	}
@@ -398,58 +713,104 @@ func resolveVisitorData(ctx context.Context, hc *http.Client) (string, error) {
 	return val, nil
 }
 
-func fetchVideoMetaBrowserScrape(ctx context.Context, videoID string) (string, time.Duration, error) {
-	req, _ := http.NewRequestWithContext(ctx, "GET", "https://www.youtube.com/watch?v="+videoID, nil)
+func (yt *YouTubeClient) fetchVideoMetaBrowserScrape(ctx context.Context, videoID string) (_ *VideoMeta, err error) {
+	ctx, done := traceFetch(ctx, "fetchVideoMetaBrowserScrape", metrics.FetchBrowser)
+	defer func() { done(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, yt.BaseURL+watchPath+"?v="+url.QueryEscape(videoID), nil)
+	if err != nil {
+		return nil, err
+	}
 	// Headers help avoid consent/AB variants
 	req.Header.Set("User-Agent", "Mozilla/5.0")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 	req.Header.Set("Cookie", "CONSENT=YES+cb.20210328-17-p0.en+FX+123;")
-	resp, err := http.Get("https://www.youtube.com/watch?v=" + videoID)
+	resp, err := yt.HTTPClient.Do(req)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
 
 	sub := durationRegex.FindSubmatch(body)
 	if sub == nil {
-		return "", 0, errors.New("failed to parse video duration: regex match failed")
+		return nil, errors.New("failed to parse video duration: regex match failed")
 	}
 	iso := string(sub[1])
 
 	dur, err := parseISO8601(iso)
 	if err != nil {
-		return "", 0, errors.New("failed to parse video duration: invalid iso format")
+		return nil, errors.New("failed to parse video duration: invalid iso format")
 	}
 
 	parts := titleRegex.FindSubmatch(body)
 	if parts == nil {
-		return "", 0, errors.New("failed to parse video title: regex match failed")
+		return nil, errors.New("failed to parse video title: regex match failed")
+	}
+
+	meta := &VideoMeta{
+		Title:    string(parts[1]),
+		Duration: dur,
+	}
+
+	// The rest is best effort
+	if m := authorRegex.FindSubmatch(body); m != nil {
+		meta.Channel = string(m[1])
+	}
+	if m := thumbnailRegex.FindSubmatch(body); m != nil {
+		meta.ThumbnailURL = string(m[1])
+	}
+	if m := publishedRegex.FindSubmatch(body); m != nil {
+		meta.PublishedAt = parsePublishDate(string(m[1]))
+	}
+	if m := viewsRegex.FindSubmatch(body); m != nil {
+		meta.ViewCount = parseViewCount(string(m[1]))
 	}
-	title := string(parts[1])
 
-	return title, dur, nil
+	return meta, nil
+}
+
+// parsePublishDate accepts the RFC 3339 timestamps of the Data API and the
+// plain or timestamped dates of the player microformat.
+func parsePublishDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func parseViewCount(s string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return n
 }
 
 func parseISO8601(s string) (time.Duration, error) {
-	m := iso8601Regex.FindStringSubmatch(s)
+	// Live streams report "P0D", long videos can run into days
+	m := iso8601Regex.FindStringSubmatch(strings.TrimSpace(s))
 	if m == nil {
 		return 0, fmt.Errorf("invalid ISO 8601 duration: %q", s)
 	}
 	var d time.Duration
-	if h := m[1]; h != "" {
+	if dd := m[1]; dd != "" {
+		days, _ := strconv.Atoi(dd)
+		d += time.Duration(days) * 24 * time.Hour
+	}
+	if h := m[2]; h != "" {
 		hh, _ := strconv.Atoi(h)
 		d += time.Duration(hh) * time.Hour
 	}
-	if m := m[2]; m != "" {
+	if m := m[3]; m != "" {
 		mm, _ := strconv.Atoi(m)
 		d += time.Duration(mm) * time.Minute
 	}
-	if s := m[3]; s != "" {
+	if s := m[4]; s != "" {
 		ss, _ := strconv.Atoi(s)
 		d += time.Duration(ss) * time.Second
 	}
@@ -493,9 +854,73 @@ func (pr *playerResponse) IsAgeRestricted() bool {
 
 func (r *ytDataAPIResp) AnyAgeRestricted() bool {
 	for _, it := range r.Items {
-		if strings.EqualFold(it.ContentDetails.ContentRating.YTRating, "ytAgeRestricted") {
+		if it.IsAgeRestricted() {
 			return true
 		}
 	}
 	return false
 }
+
+// Playability classifies the player response. Age gating is checked
+// separately by IsAgeRestricted, and region blocks by IsRegionBlocked.
+func (pr *playerResponse) Playability() Playability {
+	ps := pr.PlayabilityStatus
+	reason := strings.ToLower(ps.Reason)
+
+	switch {
+	case pr.VideoDetails.IsLive || pr.VideoDetails.IsUpcoming || ps.Status == "LIVE_STREAM_OFFLINE":
+		return PlayabilityLive
+	case ps.Status == "LOGIN_REQUIRED" && strings.Contains(reason, "private"):
+		return PlayabilityPrivate
+	case ps.Status == "ERROR":
+		// Removed, terminated account or a bad ID
+		return PlayabilityUnavailable
+	case ps.Status == "UNPLAYABLE" && (strings.Contains(reason, "other websites") || strings.Contains(reason, "embed")):
+		return PlayabilityEmbedDisabled
+	case ps.Status == "UNPLAYABLE" && !pr.IsRegionBlocked():
+		return PlayabilityUnavailable
+	case ps.PlayableInEmbed != nil && !*ps.PlayableInEmbed:
+		return PlayabilityEmbedDisabled
+	}
+
+	return PlayabilityEmbeddable
+}
+
+// Playability classifies the Data API item from its status and snippet.
+func (it *ytDataAPIItem) Playability() Playability {
+	switch {
+	case it.Status.PrivacyStatus == "private":
+		return PlayabilityPrivate
+	case it.Status.UploadStatus != "" && it.Status.UploadStatus != "processed":
+		// "deleted", "failed", "rejected" or still "uploaded"
+		return PlayabilityUnavailable
+	case it.Snippet.LiveContent == "live" || it.Snippet.LiveContent == "upcoming":
+		return PlayabilityLive
+	case it.Status.Embeddable != nil && !*it.Status.Embeddable:
+		return PlayabilityEmbedDisabled
+	}
+
+	return PlayabilityEmbeddable
+}
+
+// IsRegionBlocked reports a player response refused because of the country
+// the request came from.
+func (pr *playerResponse) IsRegionBlocked() bool {
+	ps := pr.PlayabilityStatus
+	return ps.Status == "UNPLAYABLE" && strings.Contains(strings.ToLower(ps.Reason), "country")
+}
+
+// IsRegionBlocked reports whether the video can't be watched from region,
+// either through the blocklist or by being left off the allowlist. Videos
+// blocked only elsewhere are not, as the scrape path would not see them.
+func (it *ytDataAPIItem) IsRegionBlocked(region string) bool {
+	rr := it.ContentDetails.RegionRestriction
+	if rr.Allowed != nil && !slices.Contains(rr.Allowed, region) {
+		return true
+	}
+	return slices.Contains(rr.Blocked, region)
+}
+
+func (it *ytDataAPIItem) IsAgeRestricted() bool {
+	return strings.EqualFold(it.ContentDetails.ContentRating.YTRating, "ytAgeRestricted")
+}
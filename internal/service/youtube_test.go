@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/ytfake"
+)
+
+func newFakeYouTube(t *testing.T, fetch FetchOption) (*YouTubeClient, *ytfake.Server) {
+	t.Helper()
+
+	fake := ytfake.New()
+	t.Cleanup(fake.Close)
+
+	return &YouTubeClient{
+		HTTPClient:     fake.Client(),
+		BaseURL:        fake.URL,
+		DataAPIBaseURL: fake.URL,
+		APIKey:         "test-key",
+		Fetch:          fetch,
+		Timeout:        DefaultFetchTimeout,
+		Region:         "US",
+	}, fake
+}
+
+func TestFetchVideoMeta(t *testing.T) {
+	tests := []struct {
+		name  string
+		fetch FetchOption
+	}{
+		{"mobile scrape", UseScrapeFetch},
+		{"data api", UseDataAPI},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yt, _ := newFakeYouTube(t, tt.fetch)
+
+			meta, err := yt.fetchVideoMeta(context.Background(), ytfake.VideoNormal)
+			if err != nil {
+				t.Fatalf("fetchVideoMeta: %v", err)
+			}
+
+			if meta.Title != ytfake.NormalTitle {
+				t.Errorf("Title = %q, want %q", meta.Title, ytfake.NormalTitle)
+			}
+			if meta.Channel != ytfake.NormalChannel {
+				t.Errorf("Channel = %q, want %q", meta.Channel, ytfake.NormalChannel)
+			}
+			if meta.Duration != ytfake.NormalDuration {
+				t.Errorf("Duration = %v, want %v", meta.Duration, ytfake.NormalDuration)
+			}
+			if meta.Playability != PlayabilityEmbeddable {
+				t.Errorf("Playability = %q, want %q", meta.Playability, PlayabilityEmbeddable)
+			}
+			if meta.ViewCount != 123456 {
+				t.Errorf("ViewCount = %d, want 123456", meta.ViewCount)
+			}
+			if meta.ThumbnailURL == "" {
+				t.Error("ThumbnailURL is empty")
+			}
+		})
+	}
+}
+
+func TestFetchVideoMetaAgeRestricted(t *testing.T) {
+	for _, fetch := range []FetchOption{UseScrapeFetch, UseDataAPI, UseScrapeFetch | UseDataAPI} {
+		yt, _ := newFakeYouTube(t, fetch)
+
+		_, err := yt.fetchVideoMeta(context.Background(), ytfake.VideoAgeRestricted)
+		if !errors.Is(err, ErrAgeRestircted) {
+			t.Errorf("fetch %b: err = %v, want ErrAgeRestircted", fetch, err)
+		}
+	}
+}
+
+func TestFetchVideoMetaUnavailable(t *testing.T) {
+	for _, fetch := range []FetchOption{UseScrapeFetch, UseDataAPI} {
+		yt, _ := newFakeYouTube(t, fetch)
+
+		_, err := yt.fetchVideoMeta(context.Background(), "doesNotExis")
+
+		var unplayable *UnplayableError
+		if !errors.As(err, &unplayable) {
+			t.Fatalf("fetch %b: err = %v, want *UnplayableError", fetch, err)
+		}
+		if unplayable.Playability != PlayabilityUnavailable {
+			t.Errorf("fetch %b: Playability = %q, want %q", fetch, unplayable.Playability, PlayabilityUnavailable)
+		}
+	}
+}
+
+func TestFetchVideoMetaLive(t *testing.T) {
+	for _, fetch := range []FetchOption{UseScrapeFetch, UseDataAPI} {
+		yt, _ := newFakeYouTube(t, fetch)
+
+		meta, err := yt.fetchVideoMeta(context.Background(), ytfake.VideoLive)
+		if err != nil {
+			t.Fatalf("fetch %b: %v", fetch, err)
+		}
+		if meta.Playability != PlayabilityLive || meta.Duration != 0 {
+			t.Errorf("fetch %b: Playability = %q, Duration = %v, want live without a duration", fetch, meta.Playability, meta.Duration)
+		}
+
+		policy := dj.DefaultContentPolicy()
+		violation := policy.Evaluate(meta.PolicyCandidate())
+		if violation == nil || violation.Rule != dj.RuleLive {
+			t.Errorf("fetch %b: violation = %v, want the live rule", fetch, violation)
+		}
+	}
+}
+
+func TestParseISO8601(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT3M33S":  3*time.Minute + 33*time.Second,
+		"PT1H":     time.Hour,
+		"P1DT2H":   26 * time.Hour,
+		"P0D":      0,
+		" PT10S\n": 10 * time.Second,
+	}
+	for iso, want := range tests {
+		if got, err := parseISO8601(iso); err != nil || got != want {
+			t.Errorf("parseISO8601(%q) = %v, %v, want %v", iso, got, err, want)
+		}
+	}
+
+	for _, iso := range []string{"", "3M33S", "PT3M33SX", "1:00"} {
+		if _, err := parseISO8601(iso); err == nil {
+			t.Errorf("parseISO8601(%q) accepted a malformed duration", iso)
+		}
+	}
+}
+
+func TestFetchVideoMetaRegionBlocked(t *testing.T) {
+	yt, _ := newFakeYouTube(t, UseDataAPI)
+	policy := dj.DefaultContentPolicy()
+
+	tests := []struct {
+		id      string
+		blocked bool
+	}{
+		{ytfake.VideoBlockedElsewhere, false},
+		{ytfake.VideoBlockedHere, true},
+	}
+
+	for _, tt := range tests {
+		meta, err := yt.fetchVideoMeta(context.Background(), tt.id)
+		if err != nil {
+			t.Fatalf("%s: fetchVideoMeta: %v", tt.id, err)
+		}
+		if meta.RegionBlocked != tt.blocked {
+			t.Errorf("%s: RegionBlocked = %v, want %v", tt.id, meta.RegionBlocked, tt.blocked)
+		}
+		if rejected := policy.Evaluate(meta.PolicyCandidate()) != nil; rejected != tt.blocked {
+			t.Errorf("%s: rejected = %v, want %v", tt.id, rejected, tt.blocked)
+		}
+	}
+}
+
+func TestFetchVideoMetaMalformed(t *testing.T) {
+	yt, fake := newFakeYouTube(t, UseScrapeFetch|UseDataAPI)
+
+	_, err := yt.fetchVideoMeta(context.Background(), ytfake.VideoMalformed)
+	if err == nil {
+		t.Fatal("expected an error for malformed responses")
+	}
+
+	// Both paths must have been tried
+	if fake.Requests(ytfake.PlayerPath) != 1 || fake.Requests(ytfake.DataAPIVideosPath) != 1 {
+		t.Errorf("requests: player %d, videos %d, want 1 each",
+			fake.Requests(ytfake.PlayerPath), fake.Requests(ytfake.DataAPIVideosPath))
+	}
+}
+
+func TestFetchVideoMetaScrapeFallback(t *testing.T) {
+	yt, fake := newFakeYouTube(t, UseScrapeFetch|UseDataAPI)
+	yt.BaseURL = "http://127.0.0.1:1" // nothing listens here
+
+	meta, err := yt.fetchVideoMeta(context.Background(), ytfake.VideoNormal)
+	if err != nil {
+		t.Fatalf("fetchVideoMeta: %v", err)
+	}
+	if meta.Title != ytfake.NormalTitle {
+		t.Errorf("Title = %q, want %q", meta.Title, ytfake.NormalTitle)
+	}
+	if fake.Requests(ytfake.DataAPIVideosPath) != 1 {
+		t.Errorf("data api requests = %d, want 1", fake.Requests(ytfake.DataAPIVideosPath))
+	}
+}
+
+func TestFetchVideoMetaSlow(t *testing.T) {
+	yt, _ := newFakeYouTube(t, UseScrapeFetch)
+	yt.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err := yt.fetchVideoMeta(context.Background(), ytfake.VideoSlow)
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > ytfake.DefaultSlowLatency/2 {
+		t.Errorf("fetch took %v, the timeout was not applied", elapsed)
+	}
+}
+
+func TestSearchVideos(t *testing.T) {
+	tests := []struct {
+		name   string
+		apiKey string
+		path   string
+	}{
+		{"mobile scrape", "", ytfake.SearchPath},
+		{"data api", "test-key", ytfake.DataAPISearchPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yt, fake := newFakeYouTube(t, UseScrapeFetch)
+			yt.APIKey = tt.apiKey
+
+			results, err := yt.searchVideos(context.Background(), "fake", dj.DefaultContentPolicy())
+			if err != nil {
+				t.Fatalf("searchVideos: %v", err)
+			}
+
+			// The age restricted hit is dropped
+			if len(results) != 1 || results[0].ID != ytfake.VideoNormal {
+				t.Fatalf("results = %+v, want only %s", results, ytfake.VideoNormal)
+			}
+			if results[0].Duration != ytfake.NormalDuration {
+				t.Errorf("Duration = %v, want %v", results[0].Duration, ytfake.NormalDuration)
+			}
+			if fake.Requests(tt.path) != 1 {
+				t.Errorf("requests to %s = %d, want 1", tt.path, fake.Requests(tt.path))
+			}
+		})
+	}
+}
+
+func TestHandleAddVideo(t *testing.T) {
+	yt, _ := newFakeYouTube(t, UseScrapeFetch|UseDataAPI)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	manager := dj.NewLobbyManager(ctx, logger, dj.ManagerOptions{})
+	user, _ := manager.NewUser("tester", "192.0.2.1")
+	lobby := manager.NewLobby(dj.LobbyOptions{
+		Mode:           dj.LobbyModeLinear,
+		UserQueueLimit: 5,
+		CreatorIP:      user.IP,
+	})
+	lobby.AddUser(user)
+
+	add := func(videoId string) *httptest.ResponseRecorder {
+		form := url.Values{"url": {"https://youtu.be/" + videoId}}
+		req := httptest.NewRequest(http.MethodPost, "/lobby/"+lobby.ID+"/add", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(context.WithValue(req.Context(), ContextYouTube, yt))
+		req = req.WithContext(context.WithValue(req.Context(), ContextLogger, logger))
+
+		rec := httptest.NewRecorder()
+		HandleAddVideo(lobby, user, rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name    string
+		videoId string
+		status  int
+	}{
+		{"normal", ytfake.VideoNormal, http.StatusCreated},
+		{"age restricted", ytfake.VideoAgeRestricted, http.StatusForbidden},
+		{"live", ytfake.VideoLive, http.StatusForbidden},
+		{"unavailable", "doesNotExis", http.StatusUnprocessableEntity},
+		{"malformed", ytfake.VideoMalformed, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := add(tt.videoId)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.status, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+
+	lobby.Lock()
+	defer lobby.Unlock()
+
+	if lobby.CurrentVideo == nil || lobby.CurrentVideo.ID != ytfake.VideoNormal {
+		t.Fatalf("CurrentVideo = %+v, want %s", lobby.CurrentVideo, ytfake.VideoNormal)
+	}
+	if lobby.CurrentVideo.Title != ytfake.NormalTitle {
+		t.Errorf("CurrentVideo.Title = %q, want %q", lobby.CurrentVideo.Title, ytfake.NormalTitle)
+	}
+}
+
+func TestRespondWithToast(t *testing.T) {
+	message := `Videos from Fake "Channel" \ {"refresh":1} are not allowed`
+
+	rec := httptest.NewRecorder()
+	respondWithToast(message, "error", rec)
+
+	var trigger map[string]toast
+	if err := json.Unmarshal([]byte(rec.Header().Get("HX-Trigger")), &trigger); err != nil {
+		t.Fatalf("HX-Trigger is not JSON: %v", err)
+	}
+	if len(trigger) != 1 || trigger["toast"] != (toast{message, "error"}) {
+		t.Errorf("HX-Trigger = %+v, want only the toast", trigger)
+	}
+}
+
+func TestPlayerErrorRecheck(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.newClient("192.0.2.1")
+	bob := app.newClient("192.0.2.2")
+
+	id := alice.createLobby("alice", nil)
+	bob.join(id, "bob")
+	lobby := app.lobby(id)
+
+	// The first still plays fine on the server, the second is gone
+	for _, videoId := range []string{ytfake.VideoNormal, "doesNotExis"} {
+		lobby.AddVideo(context.Background(), &dj.Video{ID: videoId, Title: videoId, Duration: time.Minute})
+	}
+
+	report := func(c *testClient, videoId string) int {
+		return c.post("/lobby/"+id+"/player-error", url.Values{"video": {videoId}, "code": {"100"}}).StatusCode
+	}
+
+	for _, videoId := range []string{ytfake.VideoNormal, "doesNotExis"} {
+		if status := report(alice, videoId); status != http.StatusNoContent {
+			t.Fatalf("report %s: status %d", videoId, status)
+		}
+		if history := lobby.PlayHistory(); history[len(history)-1].VideoID != videoId {
+			t.Fatalf("one report skipped %s", videoId)
+		}
+		report(bob, videoId)
+	}
+
+	if lobby.CheckVideoFailed(ytfake.VideoNormal) {
+		t.Error("a video the server can play was marked failed")
+	}
+	if !lobby.CheckVideoFailed("doesNotExis") {
+		t.Error("an unavailable video was not marked failed")
+	}
+}
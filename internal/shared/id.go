@@ -1,13 +1,93 @@
 package shared
 
-import "math/rand"
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"strings"
+)
 
 const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
+// GenerateID builds an ID from crypto/rand.
 func GenerateID(size int) string {
+	return GenerateIDFrom(SecureIntn, size)
+}
+
+// GenerateIDFrom builds an ID using intn as the random source.
+func GenerateIDFrom(intn func(n int) int, size int) string {
 	b := make([]byte, size)
 	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+		b[i] = charset[intn(len(charset))]
 	}
 	return string(b)
 }
+
+// SecureIntn returns a uniform number in [0, n) from crypto/rand.
+func SecureIntn(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic("crypto/rand failed: " + err.Error()) // never returns an error on supported platforms
+	}
+	return int(v.Int64())
+}
+
+// SessionTokenVersion prefixes session tokens. It changes with the token
+// scheme, so cookies issued under an older one are refused instead of looked up.
+const SessionTokenVersion = "v1"
+
+// SessionTokenBytes is the entropy of a session token.
+const SessionTokenBytes = 32
+
+// NewSessionToken returns a random session token, only its hash is kept on
+// the server.
+func NewSessionToken() string {
+	secret := make([]byte, SessionTokenBytes)
+	rand.Read(secret) // never returns an error, it crashes the program instead
+	return SessionTokenVersion + "." + base64.RawURLEncoding.EncodeToString(secret)
+}
+
+// ValidSessionToken reports whether token was issued under the current scheme.
+func ValidSessionToken(token string) bool {
+	version, secret, ok := strings.Cut(token, ".")
+	if !ok || version != SessionTokenVersion {
+		return false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(secret)
+	return err == nil && len(raw) == SessionTokenBytes
+}
+
+// HashSessionToken returns the key a session is stored under, so a leak of
+// the session table does not hand out working cookies.
+func HashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// SignSessionToken returns the cookie value for token, the token followed by
+// its HMAC under key.
+func SignSessionToken(key []byte, token string) string {
+	return token + "." + signature(key, token)
+}
+
+// VerifySessionCookie checks the signature of a cookie value made by
+// SignSessionToken and returns the token in it.
+func VerifySessionCookie(key []byte, value string) (string, bool) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return "", false
+	}
+	token, sig := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(signature(key, token))) {
+		return "", false
+	}
+	return token, true
+}
+
+func signature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
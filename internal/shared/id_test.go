@@ -0,0 +1,46 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSessionToken(t *testing.T) {
+	token := NewSessionToken()
+	if !ValidSessionToken(token) {
+		t.Fatalf("new token %q is not valid", token)
+	}
+	if !strings.HasPrefix(token, SessionTokenVersion+".") {
+		t.Errorf("token %q has no version", token)
+	}
+	if NewSessionToken() == token {
+		t.Error("two tokens are the same")
+	}
+	if HashSessionToken(token) == token || HashSessionToken(token) != HashSessionToken(token) {
+		t.Error("hash is not a stable digest of the token")
+	}
+
+	for _, bad := range []string{"", "abcdefGHIJ12", "v0." + token[3:], "v1.short", token + "x"} {
+		if ValidSessionToken(bad) {
+			t.Errorf("ValidSessionToken(%q) = true", bad)
+		}
+	}
+}
+
+func TestSignSessionToken(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	token := NewSessionToken()
+	value := SignSessionToken(key, token)
+
+	if got, ok := VerifySessionCookie(key, value); !ok || got != token {
+		t.Fatalf("VerifySessionCookie = %q, %v, want the token back", got, ok)
+	}
+	if _, ok := VerifySessionCookie([]byte("another key, just as long as it"), value); ok {
+		t.Error("verified under the wrong key")
+	}
+	for _, bad := range []string{"", token, value + "x", "x" + value} {
+		if _, ok := VerifySessionCookie(key, bad); ok {
+			t.Errorf("VerifySessionCookie(%q) passed", bad)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package shared
+
+import (
+	"crypto/hmac"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignInviteToken returns the token of an invite to a lobby. It carries the
+// invite ID and expiry, signed under key together with the lobby ID so it
+// cannot be altered or used for another lobby.
+func SignInviteToken(key []byte, lobbyID, inviteID string, expires time.Time) string {
+	payload := inviteID + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + signature(key, inviteSigningPayload(lobbyID, payload))
+}
+
+// VerifyInviteToken checks the signature of an invite token for the lobby and
+// returns the invite ID and expiry in it.
+func VerifyInviteToken(key []byte, lobbyID, token string) (string, time.Time, bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", time.Time{}, false
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(signature(key, inviteSigningPayload(lobbyID, payload)))) {
+		return "", time.Time{}, false
+	}
+
+	inviteID, unix, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return inviteID, time.Unix(seconds, 0), true
+}
+
+// inviteSigningPayload keeps invite signatures apart from session cookie
+// signatures made under the same key.
+func inviteSigningPayload(lobbyID, payload string) string {
+	return "invite:" + lobbyID + ":" + payload
+}
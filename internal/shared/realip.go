@@ -4,41 +4,122 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 )
 
 var cfConnectingIP = http.CanonicalHeaderKey("Cf-Connecting-IP")
-var trueClientIP = http.CanonicalHeaderKey("True-Client-IP")
 var xForwardedFor = http.CanonicalHeaderKey("X-Forwarded-For")
-var xRealIP = http.CanonicalHeaderKey("X-Real-IP")
 
-func RealIP(h http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		if rip := realIP(r); rip != "" {
-			r.RemoteAddr = rip
+// Client IP modes, which header names the client address when the request
+// comes from a trusted proxy.
+const (
+	// ClientIPNone ignores forwarding headers, the TCP peer is the client.
+	ClientIPNone = "none"
+	// ClientIPCloudflare reads Cf-Connecting-IP.
+	ClientIPCloudflare = "cloudflare"
+	// ClientIPForwardedFor reads X-Forwarded-For from the right, taking the
+	// first hop that is not a trusted proxy.
+	ClientIPForwardedFor = "xff"
+)
+
+var ClientIPModes = []string{ClientIPNone, ClientIPCloudflare, ClientIPForwardedFor}
+
+// RealIP sets RemoteAddr to the client address. Forwarding headers are only
+// read, as chosen by mode, when the TCP peer is in trusted. Otherwise the
+// peer address is used, so clients connecting directly cannot spoof theirs.
+func RealIP(mode string, trusted []netip.Prefix) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if rip := realIP(r, mode, trusted); rip.IsValid() {
+				r.RemoteAddr = rip.String()
+			}
+			h.ServeHTTP(w, r)
 		}
-		h.ServeHTTP(w, r)
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func realIP(r *http.Request, mode string, trusted []netip.Prefix) netip.Addr {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || mode == ClientIPNone || !isTrusted(peer.Addr(), trusted) {
+		return netip.Addr{}
+	}
+
+	switch mode {
+	case ClientIPCloudflare:
+		ip, _ := parseAddr(r.Header.Get(cfConnectingIP))
+		return ip
+	case ClientIPForwardedFor:
+		return rightmostUntrusted(r.Header.Values(xForwardedFor), trusted)
+	default:
+		return netip.Addr{}
+	}
+}
+
+// rightmostUntrusted walks the X-Forwarded-For hops from the nearest one,
+// skipping trusted proxies. Hops further left were added by whoever sent the
+// request and cannot be believed. If every hop is trusted the furthest is used.
+func rightmostUntrusted(headers []string, trusted []netip.Prefix) netip.Addr {
+	var hops []string
+	for _, header := range headers {
+		hops = append(hops, strings.Split(header, ",")...)
 	}
 
-	return http.HandlerFunc(fn)
+	var furthest netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, ok := parseAddr(hops[i])
+		if !ok {
+			return netip.Addr{}
+		}
+		if !isTrusted(ip, trusted) {
+			return ip
+		}
+		furthest = ip
+	}
+	return furthest
 }
 
-func realIP(r *http.Request) string {
-	var ip string
+func parseAddr(s string) (netip.Addr, bool) {
+	ip, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
 
-	if cfip := r.Header.Get(cfConnectingIP); cfip != "" {
-		ip = cfip
-	} else if tcip := r.Header.Get(trueClientIP); tcip != "" {
-		ip = tcip
-	} else if xrip := r.Header.Get(xRealIP); xrip != "" {
-		ip = xrip
-	} else if xff := r.Header.Get(xForwardedFor); xff != "" {
-		ip, _, _ = strings.Cut(xff, ",")
+func isTrusted(ip netip.Addr, trusted []netip.Prefix) bool {
+	ip = ip.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(ip) {
+			return true
+		}
 	}
-	if ip == "" || net.ParseIP(ip) == nil {
-		return ""
+	return false
+}
+
+// ParsePrefixes parses CIDR ranges, a bare address is taken as a range of one.
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			ip, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address or CIDR %q", v)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address or CIDR %q", v)
+		}
+		prefixes = append(prefixes, prefix.Masked())
 	}
-	return ip
+	return prefixes, nil
 }
 
 func ParseHost(host string) (string, error) {
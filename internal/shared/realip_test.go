@@ -0,0 +1,80 @@
+package shared
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParsePrefixes([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		peer    string
+		headers map[string][]string
+		want    string
+	}{
+		{"no headers", ClientIPForwardedFor, "10.0.0.1:1234", nil, "10.0.0.1:1234"},
+		{"mode none", ClientIPNone, "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"192.0.2.1"}}, "10.0.0.1:1234"},
+		{"untrusted peer", ClientIPForwardedFor, "198.51.100.7:1234", map[string][]string{"X-Forwarded-For": {"192.0.2.1"}}, "198.51.100.7:1234"},
+		{"untrusted cloudflare", ClientIPCloudflare, "198.51.100.7:1234", map[string][]string{"Cf-Connecting-IP": {"192.0.2.1"}}, "198.51.100.7:1234"},
+		{"cloudflare", ClientIPCloudflare, "10.1.2.3:1234", map[string][]string{"Cf-Connecting-IP": {"192.0.2.1"}}, "192.0.2.1"},
+		{"cloudflare ignores xff", ClientIPCloudflare, "10.1.2.3:1234", map[string][]string{"X-Forwarded-For": {"192.0.2.1"}}, "10.1.2.3:1234"},
+		{"xff ignores cloudflare", ClientIPForwardedFor, "10.1.2.3:1234", map[string][]string{"Cf-Connecting-IP": {"192.0.2.1"}}, "10.1.2.3:1234"},
+		{"xff single", ClientIPForwardedFor, "10.1.2.3:1234", map[string][]string{"X-Forwarded-For": {"192.0.2.1"}}, "192.0.2.1"},
+		{"xff spoofed hop", ClientIPForwardedFor, "10.1.2.3:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.9, 192.0.2.1"}}, "192.0.2.1"},
+		{"xff skips proxies", ClientIPForwardedFor, "10.1.2.3:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.9, 192.0.2.1, 10.9.9.9"}}, "192.0.2.1"},
+		{"xff split headers", ClientIPForwardedFor, "10.1.2.3:1234", map[string][]string{"X-Forwarded-For": {"192.0.2.1", "10.9.9.9"}}, "192.0.2.1"},
+		{"xff all trusted", ClientIPForwardedFor, "10.1.2.3:1234", map[string][]string{"X-Forwarded-For": {"10.8.8.8, 10.9.9.9"}}, "10.8.8.8"},
+		{"xff garbage", ClientIPForwardedFor, "10.1.2.3:1234", map[string][]string{"X-Forwarded-For": {"192.0.2.1, nope"}}, "10.1.2.3:1234"},
+		{"ipv6 peer", ClientIPForwardedFor, "[2001:db8::1]:1234", map[string][]string{"X-Forwarded-For": {"2001:db8::99"}}, "2001:db8::99"},
+		{"mapped peer", ClientIPForwardedFor, "[::ffff:10.0.0.1]:1234", map[string][]string{"X-Forwarded-For": {"192.0.2.1"}}, "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := RealIP(tt.mode, trusted)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			for k, v := range tt.headers {
+				req.Header[http.CanonicalHeaderKey(k)] = v
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	got, err := ParsePrefixes([]string{"192.0.2.7", " 10.1.2.3/8 ", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.7/32"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("prefix %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if _, err := ParsePrefixes([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("no error for a bad prefix")
+	}
+}
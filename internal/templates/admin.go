@@ -0,0 +1,48 @@
+package templates
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/metrics"
+)
+
+// AdminConsole is a snapshot of the server for the admin console.
+type AdminConsole struct {
+	Now        time.Time
+	MaxLobbies int
+	Lobbies    []AdminLobby
+	Fetch      []metrics.FetchPathHealth
+	// Notice reports the outcome of the last action.
+	Notice string
+}
+
+type AdminLobby struct {
+	ID      string
+	Fields  []slog.Attr
+	Users   []AdminUser
+	Current *dj.Video
+	Queue   []*dj.Video
+	Mutes   int
+}
+
+type AdminUser struct {
+	ID           string
+	Name         string
+	IP           string
+	Connected    bool
+	MutedUntil   time.Time
+	LastActivity time.Time
+}
+
+// AnnouncementMaxLength limits server-wide announcements.
+const AnnouncementMaxLength = 200
+
+// formatAgo renders how long before now t was, or "never".
+func formatAgo(now, t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return now.Sub(t).Round(time.Second).String() + " ago"
+}
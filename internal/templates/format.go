@@ -0,0 +1,26 @@
+package templates
+
+import (
+	"fmt"
+	"time"
+)
+
+// formatViews renders a view count the way YouTube abbreviates it.
+func formatViews(n int64) string {
+	switch {
+	case n >= 1_000_000_000:
+		return fmt.Sprintf("%.1fB views", float64(n)/1_000_000_000)
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM views", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fK views", float64(n)/1_000)
+	case n == 1:
+		return "1 view"
+	default:
+		return fmt.Sprintf("%d views", n)
+	}
+}
+
+func formatPublished(t time.Time) string {
+	return t.Format("Jan 2, 2006")
+}
@@ -8,6 +8,12 @@ package templates
 import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
+import (
+	"fmt"
+
+	"github.com/btnmasher/testdj/internal/dj"
+)
+
 func Index() templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
@@ -41,7 +47,82 @@ func Index() templ.Component {
 				}()
 			}
 			ctx = templ.InitializeContext(ctx)
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<main class=\"p-4 max-w-4xl mx-auto\"><img class=\"mx-auto\" src=\"/img/android-chrome-192x192.png\"><h1 class=\"text-3xl font-bold mt-2 mb-4 text-center text-rainbow anim-dir-reverse text-rainbow-size-20 dark:text-gray-200\">TEST DJ</h1><form id=\"landingForm\" method=\"POST\" class=\"flex flex-col gap-4\"><div class=\"panel opacity-90 shadow-rainbow space-y-4 md:mx-auto md:min-w-lg\"><h2 class=\"text-xl text-shadow-md font-semibold\">Username</h2><input type=\"text\" name=\"name\" pattern=\"[A-Za-z0-9](?:[A-Za-z0-9 ]{0,18}[A-Za-z0-9])?\" maxlength=\"20\" title=\"1–20 letters/numbers; spaces allowed only between characters\" class=\"input mt-1 w-full\" required autofocus></div><div class=\"grid grid-cols-1 md:grid-cols-2 gap-4\"><div id=\"createPanel\" class=\"panel space-y-4\"><h2 class=\"text-xl font-semibold\">Create Lobby</h2><label class=\"block\">Playlist Mode: <select name=\"mode\" class=\"input mt-1 w-full\" title=\"How the playlist will select the next video (Shuffle: Random Order - Round Robin: Play one video per user in a rotation by who joined first - Linear: First in first out\"><option value=\"shuffle\">Shuffle</option> <option value=\"round_robin\">Round Robin</option> <option value=\"linear\">Linear</option></select></label> <label class=\"block\">Per-user Video Submission Limit: <input type=\"number\" name=\"limit\" value=\"10\" min=\"1\" max=\"20\" class=\"input mt-1 w-full\" title=\"How many videos a user can have submitted to the pending playlist at once\"></label> <button id=\"createButton\" formaction=\"/create\" class=\"btn-primary w-full\">Create Lobby</button></div><div id=\"joinPanel\" class=\"panel space-y-4\"><h2 class=\"text-xl font-semibold\">Join Lobby</h2><label class=\"block\">Invite Code: <input type=\"text\" name=\"code\" pattern=\"[A-Za-z0-9]+\" title=\"Alphanumeric only, no spaces\" class=\"input mt-1 w-full font-mono font-bold font-lg tracking-widest\"></label> <button id=\"joinButton\" formaction=\"/join\" class=\"btn-primary w-full\">Join Lobby</button></div></div></form></main>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<main class=\"p-4 max-w-4xl mx-auto\"><img class=\"mx-auto\" src=\"/img/android-chrome-192x192.png\"><h1 class=\"text-3xl font-bold mt-2 mb-4 text-center text-rainbow anim-dir-reverse text-rainbow-size-20 dark:text-gray-200\">TEST DJ</h1><form id=\"landingForm\" method=\"POST\" class=\"flex flex-col gap-4\"><div class=\"panel opacity-90 shadow-rainbow space-y-4 md:mx-auto md:min-w-lg\"><h2 class=\"text-xl text-shadow-md font-semibold\">Username</h2><input type=\"text\" name=\"name\" pattern=\"[A-Za-z0-9](?:[A-Za-z0-9 ]{0,18}[A-Za-z0-9])?\" maxlength=\"20\" title=\"1–20 letters/numbers; spaces allowed only between characters\" class=\"input mt-1 w-full\" required autofocus></div><div class=\"grid grid-cols-1 md:grid-cols-2 gap-4\"><div id=\"createPanel\" class=\"panel space-y-4\"><h2 class=\"text-xl font-semibold\">Create Lobby</h2><label class=\"block\">Lobby Name: <input type=\"text\" name=\"title\" maxlength=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var3 string
+			templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(dj.MaxTitleLength))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/index.templ`, Line: 37, Col: 75}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\" class=\"input mt-1 w-full\" title=\"Optional, shown on the lobby and join pages\"></label> <label class=\"block\">Playlist Mode: <select name=\"mode\" class=\"input mt-1 w-full\" title=\"How the playlist will select the next video (Shuffle: Random Order - Round Robin: Play one video per user in a rotation by who joined first - Linear: First in first out\"><option value=\"shuffle\">Shuffle</option> <option value=\"round_robin\">Round Robin</option> <option value=\"linear\">Linear</option></select></label> <label class=\"block\">Per-user Video Submission Limit: <input type=\"number\" name=\"limit\" value=\"10\" min=\"1\" max=\"20\" class=\"input mt-1 w-full\" title=\"How many videos a user can have submitted to the pending playlist at once\"></label> <label class=\"block\">Replay Cooldown: <select name=\"cooldown\" class=\"input mt-1 w-full\" title=\"How long after a video was played before it can be added again\"><option value=\"0\">Off</option> <option value=\"15\">15 minutes</option> <option value=\"30\">30 minutes</option> <option value=\"60\" selected>1 hour</option> <option value=\"120\">2 hours</option> <option value=\"1440\">Whole session</option></select></label> <label class=\"block\">Replay Cooldown Applies To: <select name=\"cooldown_scope\" class=\"input mt-1 w-full\" title=\"Anyone: nobody can re-add a recently played video - Same submitter: only the user who submitted it is blocked\"><option value=\"global\">Anyone</option> <option value=\"submitter\">Same submitter</option></select></label> <label class=\"block\">Skip Broken Videos After Reports From: <select name=\"error_share\" class=\"input mt-1 w-full\" title=\"How many of the connected users must report that the player failed before the video is skipped\"><option value=\"1\">Anyone</option> <option value=\"25\">A quarter of the lobby</option> <option value=\"50\" selected>Half of the lobby</option> <option value=\"100\">Everyone</option></select></label> <label class=\"block\">Who Can Join: <select name=\"access\" class=\"input mt-1 w-full\" title=\"Anyone with the code: the code is enough - Password or invite: joining asks for the password unless the link has an invite - Invite only: invite links made from the lobby page\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			for _, m := range dj.AccessModes {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<option value=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var4 string
+				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(m)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/index.templ`, Line: 111, Col: 52}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\">")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var5 string
+				templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(dj.AccessDisplayName[m])
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/index.templ`, Line: 111, Col: 78}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</option>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</select></label> <label class=\"block\">Lobby Password: <input type=\"password\" name=\"password\" maxlength=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var6 string
+			templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(dj.MaxPasswordLength))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/index.templ`, Line: 120, Col: 78}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "\" autocomplete=\"new-password\" class=\"input mt-1 w-full\" title=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var7 string
+			templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("Only used when joining asks for a password, %d to %d characters", dj.MinPasswordLength, dj.MaxPasswordLength))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/index.templ`, Line: 123, Col: 164}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "\"></label> <button id=\"createButton\" formaction=\"/create\" class=\"btn-primary w-full\">Create Lobby</button></div><div id=\"joinPanel\" class=\"panel space-y-4\"><h2 class=\"text-xl font-semibold\">Join Lobby</h2><label class=\"block\">Invite Code: <input type=\"text\" name=\"code\" pattern=\"[A-Za-z0-9\\-]+\" title=\"Letters, numbers and dashes, no spaces\" class=\"input mt-1 w-full font-mono font-bold font-lg tracking-widest\"></label> <button id=\"joinButton\" formaction=\"/join\" class=\"btn-primary w-full\">Join Lobby</button> <a href=\"/lobbies\" class=\"block text-center underline\">Browse public lobbies</a></div></div></form></main>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
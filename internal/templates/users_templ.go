@@ -56,13 +56,13 @@ func UsersPartial(lobby *dj.Lobby, self *dj.User) templ.Component {
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			if lobby.MutesByIP.Exists(u.IP) {
+			if lobby.Mutes.Exists(lobby.MuteKey(u)) {
 				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "<button class=\"px-1 text-red-600 rounded border border-red-500 bg-red-300 disabled:opacity-60\" title=\"User muted\" disabled>&#x1F507;&#xFE0E;</button>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			} else if self != nil && self.ID != u.ID && !lobby.VoteMute.Active {
-				if cd, ok := lobby.MuteCooldownsByIP.Get(self.IP); ok && time.Now().Before(cd) {
+				if cd, ok := lobby.MuteCooldowns.Get(lobby.MuteKey(self)); ok && time.Now().Before(cd) {
 					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<button class=\"hidden group-hover/user:block px-1 text-gray-400 rounded border border-gray-500 bg-gray-200 ml-2 disabled:opacity-60 cursor-not-allowed\" title=\"You're on cooldown\" disabled>&#x1F507;&#xFE0E;</button>")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
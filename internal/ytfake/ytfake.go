@@ -0,0 +1,194 @@
+// Package ytfake is an in-process stand in for the YouTube endpoints used to
+// look up and search videos, so the fetch paths can be tested offline.
+package ytfake
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Video IDs with canned responses. Any other ID is reported as unavailable.
+const (
+	VideoNormal        = "normalVid01"
+	VideoAgeRestricted = "ageRestrict"
+	VideoMalformed     = "malformed01"
+	VideoSlow          = "slowVideo01"
+	VideoLive          = "liveStream1"
+	// Region restricted videos, only served by the Data API. The first is
+	// blocked in Germany alone, the second only allowed in Germany and Japan.
+	VideoBlockedElsewhere = "regionSome1"
+	VideoBlockedHere      = "regionHome1"
+)
+
+// Details of the VideoNormal fixture, for assertions.
+const (
+	NormalTitle    = "Fake Normal Video"
+	NormalChannel  = "Fake Channel"
+	NormalDuration = 213 * time.Second
+)
+
+// VisitorData is the value served by sw.js_data.
+const VisitorData = "CgtmYWtlVmlzaXRvcg%3D%3D"
+
+const DefaultSlowLatency = 5 * time.Second
+
+const (
+	SWDataPath        = "/sw.js_data"
+	PlayerPath        = "/youtubei/v1/player"
+	SearchPath        = "/youtubei/v1/search"
+	DataAPIVideosPath = "/youtube/v3/videos"
+	DataAPISearchPath = "/youtube/v3/search"
+)
+
+//go:embed fixtures/*
+var fixtures embed.FS
+
+func fixture(name string) []byte {
+	data, err := fixtures.ReadFile("fixtures/" + name)
+	if err != nil {
+		panic("ytfake: missing fixture " + name)
+	}
+	return data
+}
+
+var playerFixtures = map[string]string{
+	VideoNormal:        "player_normal.json",
+	VideoAgeRestricted: "player_age_restricted.json",
+	VideoMalformed:     "player_malformed.json",
+	VideoSlow:          "player_normal.json",
+	VideoLive:          "player_live.json",
+}
+
+var videoFixtures = map[string]string{
+	VideoNormal:        "videos_normal.json",
+	VideoAgeRestricted: "videos_age_restricted.json",
+	VideoMalformed:     "videos_malformed.json",
+	VideoSlow:          "videos_normal.json",
+	VideoLive:          "videos_live.json",
+
+	VideoBlockedElsewhere: "videos_region_elsewhere.json",
+	VideoBlockedHere:      "videos_region_blocked.json",
+}
+
+// Server serves the fixtures over HTTP. Both the www.youtube.com and the
+// googleapis.com paths are served from the same address.
+type Server struct {
+	*httptest.Server
+
+	// SlowLatency is how long VideoSlow takes to answer, unless the request
+	// is cancelled first.
+	SlowLatency time.Duration
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+// New starts a fake server, close it with Close when done.
+func New() *Server {
+	s := &Server{
+		SlowLatency: DefaultSlowLatency,
+		requests:    make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+SWDataPath, s.handleSWData)
+	mux.HandleFunc("POST "+PlayerPath, s.handlePlayer)
+	mux.HandleFunc("POST "+SearchPath, s.handleSearch)
+	mux.HandleFunc("GET "+DataAPIVideosPath, s.handleDataAPIVideos)
+	mux.HandleFunc("GET "+DataAPISearchPath, s.handleDataAPISearch)
+
+	s.Server = httptest.NewServer(s.count(mux))
+	return s
+}
+
+// Requests returns how many requests were made to the path.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func (s *Server) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		s.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// delay holds the response for slow videos, it returns false if the client
+// gave up waiting.
+func (s *Server) delay(r *http.Request, id string) bool {
+	if id != VideoSlow {
+		return true
+	}
+
+	select {
+	case <-time.After(s.SlowLatency):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func writeJSON(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func (s *Server) handleSWData(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript")
+	w.Write(fixture("sw_js_data.txt"))
+}
+
+func (s *Server) handlePlayer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		VideoID string `json:"videoId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if !s.delay(r, req.VideoID) {
+		return
+	}
+
+	name, ok := playerFixtures[req.VideoID]
+	if !ok {
+		name = "player_unavailable.json"
+	}
+	writeJSON(w, fixture(name))
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, fixture("search.json"))
+}
+
+// handleDataAPIVideos builds a videos.list response from the fixture of each
+// requested ID. Unknown IDs are left out, as the real API does.
+func (s *Server) handleDataAPIVideos(w http.ResponseWriter, r *http.Request) {
+	ids := strings.Split(r.URL.Query().Get("id"), ",")
+
+	items := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !s.delay(r, id) {
+			return
+		}
+		if name, ok := videoFixtures[id]; ok {
+			items = append(items, string(fixture(name)))
+		}
+	}
+
+	writeJSON(w, []byte(`{"items":[`+strings.Join(items, ",")+`]}`))
+}
+
+func (s *Server) handleDataAPISearch(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, []byte(`{"items":[{"id":{"videoId":"`+VideoNormal+`"}},{"id":{"videoId":"`+VideoAgeRestricted+`"}}]}`))
+}
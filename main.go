@@ -1,31 +1,28 @@
 package main
 
 import (
-	"compress/flate"
 	"context"
 	"embed"
 	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
 	"log/slog"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/dpotapov/slogpfx"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/lmittmann/tint"
-	slogchi "github.com/samber/slog-chi"
 	"gitlab.com/greyxor/slogor"
 
+	"github.com/btnmasher/testdj/internal/config"
 	"github.com/btnmasher/testdj/internal/dj"
+	"github.com/btnmasher/testdj/internal/metrics"
 	"github.com/btnmasher/testdj/internal/service"
-	"github.com/btnmasher/testdj/internal/shared"
+	"github.com/btnmasher/testdj/internal/tracing"
 )
 
 //go:embed static/*
@@ -40,37 +37,34 @@ var CommitHash = "unknown"
 var Branch = "unknown"
 var BuildDate = "unknown"
 
-const defaultPort = "8080"
-const defaultLogLevel = slog.LevelInfo
-
-var levelMap = map[string]slog.Level{
-	"debug": slog.LevelDebug,
-	"info":  slog.LevelInfo,
-	"warn":  slog.LevelWarn,
-	"error": slog.LevelError,
-}
-
 func init() {
 	os.Setenv("env", ReleaseType)
 	os.Setenv("githash", CommitHash)
 }
 
-func getLogLevel() slog.Level {
-	if level, set := os.LookupEnv("LOG_LEVEL"); set {
-		if l, ok := levelMap[strings.ToLower(level)]; ok {
-			return l
-		}
+func main() {
+	cfg, opts, cfgErr := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(cfgErr, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if cfgErr != nil {
+		fmt.Fprintln(os.Stderr, cfgErr)
+		os.Exit(2)
 	}
 
-	return defaultLogLevel
-}
+	if opts.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-func main() {
 	mainCtx, cancelMain := context.WithCancel(context.Background())
 	defer cancelMain()
 
 	logLevel := new(slog.LevelVar)
-	logLevel.Set(getLogLevel())
+	logLevel.Set(cfg.Log.SlogLevel())
 
 	// Use the prefix based on the attribute "service"
 	prefixed := slogpfx.NewHandler(
@@ -82,60 +76,30 @@ func main() {
 
 	logger := slog.New(prefixed)
 
-	manager := dj.NewLobbyManager(mainCtx, logger)
+	shutdownTracing, err := tracing.Setup(mainCtx, cfg.Tracing, Version)
+	if err != nil {
+		logger.Error("could not set up tracing", tint.Err(err))
+		os.Exit(1)
+	}
+
+	manager := dj.NewLobbyManager(mainCtx, logger, dj.ManagerOptions{
+		Settings:   cfg.ManagerSettings(),
+		SessionKey: []byte(cfg.Session.Key),
+	})
 	staticFiles, fileErr := fs.Sub(content, "static")
 	if fileErr != nil {
 		logger.Error("could not read embedded static assets", tint.Err(fileErr))
 		os.Exit(1)
 	}
 
-	r := chi.NewRouter()
-	r.Use(
-		middleware.Recoverer,
-		shared.RealIP,
-		middleware.Compress(flate.DefaultCompression),
-		slogchi.NewWithFilters(
-			logger.With("service", "http"),
-			slogchi.IgnoreStatus(http.StatusNoContent),
-		),
-		service.InjectLogger(logger),
-		service.InjectManager(manager),
-	)
-
-	r.Get("/*", func(w http.ResponseWriter, r *http.Request) {
-		http.FileServer(http.FS(staticFiles)).ServeHTTP(w, r)
-	})
+	rt := service.NewRuntime(cfg, service.NewYouTubeClient(cfg.YouTube), manager, logger)
+	rt.LogLevel = logLevel
+	rt.Load = func() (*config.Config, error) {
+		cfg, _, err := config.Load(os.Args[1:], os.LookupEnv)
+		return cfg, err
+	}
 
-	r.Get("/", service.HandleLanding)
-	r.Post("/create", service.HandleCreateLobby)
-
-	r.Group(func(session chi.Router) {
-		session.Use(service.InjectSession())
-
-		session.Post("/join", service.HandleJoinLobby)
-		session.Post("/join/{lobbyId}", service.HandleJoinLobby)
-		session.Get("/invite/{lobbyId}", service.HandleInviteLink)
-		session.Get("/sse/{lobbyId}", service.HandleSSE)
-		session.Get("/logout", service.WithLobbyAndUser(service.HandleLogout))
-		session.Post("/logout", service.WithLobbyAndUser(service.HandleLogout))
-
-		session.Route("/lobby/{lobbyId}", func(lobby chi.Router) {
-			lobby.Get("/", service.WithLobbyAndUser(service.HandleLobbyPage))
-			lobby.Get("/video", service.HandleLobbyVideo)
-			lobby.Get("/playlist", service.HandleLobbyPlaylist)
-			lobby.Get("/history", service.HandleLobbyHistory)
-			lobby.Post("/heartbeat", service.WithLobbyAndUser(service.HandleHeartbeat))
-			lobby.Post("/add", service.WithLobbyAndUser(service.HandleAddVideo))
-			lobby.Get("/users", service.WithLobbyAndUser(service.HandleLobbyUsers))
-			lobby.Get("/votes", service.WithLobbyAndUser(service.HandleLobbyVotes))
-			lobby.Route("/vote", func(vote chi.Router) {
-				vote.Post("/skip/start", service.WithLobbyAndUser(service.HandleVoteSkipStart))
-				vote.Post("/skip/submit", service.WithLobbyAndUser(service.HandleVoteSkipSubmit))
-				vote.Post("/mute/start", service.WithLobbyAndUser(service.HandleVoteMuteStart))
-				vote.Post("/mute/submit", service.WithLobbyAndUser(service.HandleVoteMuteSubmit))
-			})
-		})
-	})
+	r := service.NewRouter(rt, staticFiles)
 
 	logger = logger.With("service", "main")
 
@@ -143,12 +107,21 @@ func main() {
 
 	signal.Notify(killSig, os.Interrupt, syscall.SIGTERM)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	hupSig := make(chan os.Signal, 1)
+	signal.Notify(hupSig, syscall.SIGHUP)
+
+	go func() {
+		for range hupSig {
+			logger.Info("Received SIGHUP, reloading config")
+			_, _ = rt.Reload() // the outcome is logged by Reload
+		}
+	}()
+
+	if opts.File != "" {
+		logger.Info("Loaded config file", slog.String("path", opts.File))
 	}
 
-	listenAddr := net.JoinHostPort(os.Getenv("LISTEN_ADDR"), port)
+	listenAddr := cfg.ListenAddress()
 	srv := &http.Server{
 		Addr:    listenAddr,
 		Handler: r,
@@ -167,8 +140,34 @@ func main() {
 
 	logger.Info(fmt.Sprintf("Listening on %s - env: %s", listenAddr, ReleaseType))
 
+	var metricsSrv *http.Server
+	if addr := cfg.Metrics.ListenAddr; addr != "" {
+		if err := metrics.RegisterActive(manager.Counts); err != nil {
+			logger.Error("could not register lobby metrics", tint.Err(err))
+			os.Exit(1)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metrics.Handler())
+		metricsSrv = &http.Server{Addr: addr, Handler: mux}
+
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("Metrics server shutdown with error", tint.Err(err))
+			}
+		}()
+
+		logger.Info("Serving metrics", slog.String("addr", addr))
+	}
+
 	<-killSig
 
+	rt.Drain()
+	if delay := cfg.Server.DrainDelay; delay > 0 {
+		logger.Info("Draining before shutdown", slog.Duration("delay", delay))
+		time.Sleep(delay)
+	}
+
 	logger.Info("Shutting down server")
 	cancelMain()
 
@@ -178,4 +177,19 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		logger.Error("Server shutdown with error", tint.Err(err))
 	}
+
+	// mainCtx is cancelled by now, give the exporter its own time to flush
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("Trace exporter shutdown with error", tint.Err(err))
+	}
+
+	if metricsSrv != nil {
+		metricsCtx, cancelMetrics := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelMetrics()
+		if err := metricsSrv.Shutdown(metricsCtx); err != nil {
+			logger.Error("Metrics server shutdown with error", tint.Err(err))
+		}
+	}
 }
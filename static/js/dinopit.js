@@ -1501,6 +1501,33 @@
             }
         },
 
+        /**
+         * Make a share of the crowd hop in response to lobby reactions.
+         * The DJ stays at the desk.
+         * @param {number} intensity - Number of reactions in the burst.
+         */
+        cheer: function cheer(intensity) {
+            const crowd = [];
+            const it = DinoPit.dinosById.values();
+            let stepIt = it.next();
+            while (!stepIt.done) {
+                const d = stepIt.value;
+                if (d.id !== DinoPit.currentDJ && !d.grabbed) {
+                    crowd.push(d);
+                }
+                stepIt = it.next();
+            }
+            let hops = Math.min(crowd.length, Math.max(1, intensity | 0));
+            while (hops > 0) {
+                const idx = (Math.random() * crowd.length) | 0;
+                const d = crowd.splice(idx, 1)[0];
+                const v = d.body.getLinearVelocity();
+                d.body.setLinearVelocity(pl.Vec2(v.x, Vpx_toMs(-rand(JUMP_MIN, JUMP_MAX))));
+                hops -= 1;
+            }
+            return true;
+        },
+
         /** Internal: single spawn with auto id. */
         _spawnOne: function _spawnOne(opts) {
             let dinoId = !!opts.id ? opts.id : DinoPit._nextId();
@@ -76,13 +76,15 @@ function showToast(message, type = "info") {
  *
  * @param {Event} [e] - Optional click event from the triggering button.
  * @param {string} code - Invite code to embed in the URL path.
+ * @param {string} [token] - Optional invite token for private lobbies.
  * @returns {void}
  */
-function copyInviteURL(e, code) {
+function copyInviteURL(e, code, token) {
     /** @type {HTMLElement|undefined} */
     const btn = /** @type {any} */ (e?.currentTarget);
     const url = new URL(window.location.href);
     url.pathname = `/invite/${code}`;
+    url.search = token ? `?token=${encodeURIComponent(token)}` : "";
 
     navigator.clipboard.writeText(url.toString())
         .then(() => {
@@ -311,6 +313,11 @@ document.getElementById('sse-drain')?.addEventListener("htmx:sseMessage", (/** @
             return;
         }
 
+        if (!!parsed?.reactions) {
+            updateReactions(parsed.reactions);
+            return;
+        }
+
         if (!!parsed?.users) {
             const users = parsed?.users;
             const dinos = window.DinoPit?.list();
@@ -339,6 +346,97 @@ document.getElementById('sse-drain')?.addEventListener("htmx:sseMessage", (/** @
     }
 });
 
+/* ============================================================================
+ * Reactions
+ * ==========================================================================*/
+
+/**
+ * Batched reaction payload broadcast for the currently playing video.
+ * @typedef {Object} ReactionsPayload
+ * @property {string} video - ID of the video the reactions belong to.
+ * @property {Object<string, number>} burst - Reactions received since the last batch.
+ * @property {Object<string, number>} totals - Running totals for the video.
+ */
+
+/**
+ * Refresh the reaction counters on the reaction bar and let the dino crowd
+ * respond to the size of the burst.
+ *
+ * @param {ReactionsPayload} reactions
+ * @returns {void}
+ */
+function updateReactions(reactions) {
+    const bar = document.getElementById("reaction-bar");
+    if (bar && bar.dataset.video === reactions.video) {
+        for (const el of bar.querySelectorAll("[data-reaction]")) {
+            el.textContent = String(reactions.totals?.[el.dataset.reaction] ?? 0);
+        }
+    }
+
+    const burst = Object.values(reactions.burst || {}).reduce((sum, n) => sum + n, 0);
+    if (burst > 0) {
+        window.DinoPit?.cheer(burst);
+    }
+}
+
+/* ============================================================================
+ * Player Errors
+ * ==========================================================================*/
+
+let ytApiReady = false;
+
+/**
+ * Called by the YouTube IFrame API script once it has loaded.
+ * @returns {void}
+ */
+window.onYouTubeIframeAPIReady = function () {
+    ytApiReady = true;
+    attachPlayer();
+};
+
+/**
+ * Wrap the current `#ytplayer` iframe in a YT.Player so playback errors can be
+ * reported back to the lobby. The iframe is replaced on every video change.
+ *
+ * @returns {void}
+ */
+function attachPlayer() {
+    /** @type {HTMLIFrameElement & {_player?: any}|null} */
+    const frame = /** @type {any} */ (document.getElementById("ytplayer"));
+    if (!ytApiReady || !frame || frame._player) return;
+
+    frame._player = new YT.Player(frame, {
+        events: {
+            onError: (/** @type {{data: number}} */ e) => {
+                console.debug("Player error:", e.data, "Video:", frame.dataset.video);
+                reportPlayerError(frame.dataset.lobby, frame.dataset.video, e.data);
+            },
+        },
+    });
+}
+
+/**
+ * Tell the lobby this client could not play the video.
+ *
+ * @param {string} lobby - Lobby ID.
+ * @param {string} video - ID of the video that failed.
+ * @param {number} code - YouTube IFrame API error code.
+ * @returns {void}
+ */
+function reportPlayerError(lobby, video, code) {
+    htmx.ajax("POST", `/lobby/${lobby}/player-error`, {
+        values: { video: video, code: String(code) },
+        swap: "none",
+    });
+}
+
+document.body.addEventListener("htmx:afterSettle", attachPlayer);
+
+// The API may have finished loading before this script ran
+if (window.YT?.loaded) {
+    window.onYouTubeIframeAPIReady();
+}
+
 /* ============================================================================
  * Form Keyboard Routing (Landing Form)
  * ==========================================================================*/
@@ -366,7 +464,7 @@ document.getElementById('landingForm')?.addEventListener('keydown', /** @this {H
 
     // Create panel fields -> /create
     const t = /** @type {HTMLInputElement} */ (e.target);
-    if (t.name === "limit" || t.name === "mode" || t.name === 'name') {
+    if (t.name === "limit" || t.name === "mode" || t.name === "cooldown" || t.name === "cooldown_scope" || t.name === "error_share" || t.name === 'name') {
         e.preventDefault();
         this.requestSubmit ? this.requestSubmit(createBtn) : createBtn?.click();
     }